@@ -15,6 +15,7 @@
 package main
 
 import (
+	"errors"
 	"os"
 
 	"github.com/zc2638/ink/core/command"
@@ -24,6 +25,10 @@ import (
 
 func main() {
 	if err := command.NewCtl().Execute(); err != nil {
+		var exitErr *command.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
 		os.Exit(1)
 	}
 }
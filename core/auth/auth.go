@@ -0,0 +1,187 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/zc2638/ink/core/handler/wrapper"
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+	storageV1 "github.com/zc2638/ink/pkg/api/storage/v1"
+	"github.com/zc2638/ink/pkg/database"
+)
+
+var (
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+)
+
+type Config struct {
+	// Tokens are the static tokens of the core api,
+	// the authentication of the core api is enabled if any of them is defined.
+	Tokens []StaticToken `json:"tokens,omitempty"`
+	// WorkerTokens are the tokens used by the workers to request the client api,
+	// the authentication of the client api is enabled if any of them is defined.
+	WorkerTokens []string `json:"workerTokens,omitempty"`
+	// AllowedOrigins are the origins allowed to request the api across the origins.
+	AllowedOrigins []string `json:"allowedOrigins,omitempty"`
+}
+
+type StaticToken struct {
+	Name  string           `json:"name"`
+	Token string           `json:"token"`
+	Roles []v1.RoleBinding `json:"roles,omitempty"`
+}
+
+func New(cfg Config) (*Authenticator, error) {
+	a := &Authenticator{
+		tokens:         make(map[string]*v1.Token, len(cfg.Tokens)),
+		workerTokens:   make(map[string]struct{}, len(cfg.WorkerTokens)),
+		allowedOrigins: cfg.AllowedOrigins,
+	}
+	for _, v := range cfg.Tokens {
+		if len(v.Token) == 0 {
+			return nil, fmt.Errorf("token(%s) value must be defined", v.Name)
+		}
+		token := &v1.Token{Name: v.Name, Roles: v.Roles}
+		if err := token.Validate(); err != nil {
+			return nil, err
+		}
+		a.tokens[storageV1.HashToken(v.Token)] = token
+	}
+	for _, v := range cfg.WorkerTokens {
+		if len(v) == 0 {
+			return nil, errors.New("worker token must not be empty")
+		}
+		a.workerTokens[storageV1.HashToken(v)] = struct{}{}
+	}
+	return a, nil
+}
+
+// Authenticator authenticates the requests by the bearer tokens.
+// The tokens are looked up by the hash, so that the comparison
+// does not leak the value of the tokens by timing.
+type Authenticator struct {
+	tokens         map[string]*v1.Token
+	workerTokens   map[string]struct{}
+	allowedOrigins []string
+}
+
+// Enabled returns true if the authentication of the core api is enabled.
+func (a *Authenticator) Enabled() bool {
+	return len(a.tokens) > 0
+}
+
+// WorkerEnabled returns true if the authentication of the client api is enabled.
+func (a *Authenticator) WorkerEnabled() bool {
+	return len(a.workerTokens) > 0
+}
+
+func (a *Authenticator) AllowedOrigins() []string {
+	return a.allowedOrigins
+}
+
+// Authenticate is the middleware of the core api, it finds the token
+// from the static tokens first and then from the database.
+func (a *Authenticator) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		value := tokenFromRequest(r)
+		if len(value) == 0 {
+			wrapper.ErrorCode(w, http.StatusUnauthorized, ErrUnauthorized)
+			return
+		}
+		hash := storageV1.HashToken(value)
+		token, ok := a.tokens[hash]
+		if !ok {
+			db := database.FromRequest(r)
+			var sd storageV1.Token
+			if err := db.Where(&storageV1.Token{Hash: hash}).Limit(1).Find(&sd).Error; err != nil {
+				wrapper.InternalError(w, err)
+				return
+			}
+			if sd.ID == 0 {
+				wrapper.ErrorCode(w, http.StatusUnauthorized, ErrUnauthorized)
+				return
+			}
+			var err error
+			if token, err = sd.ToAPI(); err != nil {
+				wrapper.InternalError(w, err)
+				return
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(WithContext(r.Context(), token)))
+	})
+}
+
+// AuthenticateWorker is the middleware of the client api.
+func (a *Authenticator) AuthenticateWorker(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.WorkerEnabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		value := tokenFromRequest(r)
+		if _, ok := a.workerTokens[storageV1.HashToken(value)]; !ok || len(value) == 0 {
+			wrapper.ErrorCode(w, http.StatusUnauthorized, ErrUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Authorize checks whether the token of the request is granted the role in the namespace,
+// the empty namespace means all namespaces. It always passes if the authentication is disabled.
+func Authorize(r *http.Request, namespace string, role v1.Role) error {
+	token := FromRequest(r)
+	if token == nil {
+		return nil
+	}
+	if !token.Allows(namespace, role) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Require returns the middleware that requires the role
+// in the namespace of the url parameter.
+func Require(role v1.Role) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			namespace := wrapper.URLParam(r, "namespace")
+			if err := Authorize(r, namespace, role); err != nil {
+				wrapper.ErrorCode(w, http.StatusForbidden, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func tokenFromRequest(r *http.Request) string {
+	value := r.Header.Get("Authorization")
+	if len(value) > 7 && strings.EqualFold(value[:7], "Bearer ") {
+		return strings.TrimSpace(value[7:])
+	}
+	return ""
+}
@@ -0,0 +1,42 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+)
+
+type key struct{}
+
+// WithContext returns a new context with the authenticated token.
+func WithContext(ctx context.Context, token *v1.Token) context.Context {
+	return context.WithValue(ctx, key{}, token)
+}
+
+// FromContext retrieves the authenticated token from the context. If the
+// authentication is disabled, the nil value is returned.
+func FromContext(ctx context.Context) *v1.Token {
+	v, _ := ctx.Value(key{}).(*v1.Token)
+	return v
+}
+
+// FromRequest retrieves the authenticated token from the request. If the
+// authentication is disabled, the nil value is returned.
+func FromRequest(r *http.Request) *v1.Token {
+	return FromContext(r.Context())
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"runtime"
 	"strconv"
 	"strings"
@@ -43,10 +44,14 @@ type WorkerV1 interface {
 	StepBegin(ctx context.Context, step *v1.Step) error
 	StepEnd(ctx context.Context, step *v1.Step) error
 	LogUpload(ctx context.Context, stepID uint64, lines []*livelog.Line, isAll bool) error
+	ServiceLogUpload(ctx context.Context, stageID uint64, lines []*livelog.Line, isAll bool) error
+	ArtifactUpload(ctx context.Context, stepID uint64, name string, r io.Reader) error
 	WatchCancel(ctx context.Context, buildID uint64) error
 }
 
-func NewWorker(addr, name string, worker *v1.Worker) (Worker, error) {
+// NewWorker returns the client of the client api,
+// the token is sent as the bearer token if it is not empty.
+func NewWorker(addr, name, token string, worker *v1.Worker) (Worker, error) {
 	if err := validateURI(addr); err != nil {
 		return nil, fmt.Errorf("validate uri failed: %v", err)
 	}
@@ -70,6 +75,7 @@ func NewWorker(addr, name string, worker *v1.Worker) (Worker, error) {
 	return &client{
 		Address: addr,
 		name:    name,
+		token:   token,
 		worker:  worker,
 	}, nil
 }
@@ -77,6 +83,7 @@ func NewWorker(addr, name string, worker *v1.Worker) (Worker, error) {
 type client struct {
 	Address string
 	name    string
+	token   string
 	index   int
 
 	worker *v1.Worker
@@ -85,6 +92,9 @@ type client struct {
 func (c *client) V1() WorkerV1 {
 	addr := strings.TrimSuffix(c.Address, "/")
 	rc := resty.New().SetBaseURL(addr + "/api/client/v1")
+	if len(c.token) > 0 {
+		rc.SetAuthToken(c.token)
+	}
 	name := c.name + "." + strconv.Itoa(c.index)
 	c.index++
 	return &clientV1{rc: rc, name: name, worker: c.worker}
@@ -185,6 +195,27 @@ func (c *clientV1) LogUpload(ctx context.Context, stepID uint64, lines []*livelo
 	return handleClientError(resp, err)
 }
 
+func (c *clientV1) ServiceLogUpload(ctx context.Context, stageID uint64, lines []*livelog.Line, isAll bool) error {
+	req := c.R(ctx).
+		SetBody(lines).
+		SetPathParam("stage", strconv.FormatUint(stageID, 10))
+	if isAll {
+		req = req.SetQueryParam("all", strconv.FormatBool(true))
+	}
+	resp, err := req.Post("/stage/{stage}/logs/upload")
+	return handleClientError(resp, err)
+}
+
+func (c *clientV1) ArtifactUpload(ctx context.Context, stepID uint64, name string, r io.Reader) error {
+	req := c.R(ctx).
+		SetBody(r).
+		SetHeader("Content-Type", "application/octet-stream").
+		SetPathParam("step", strconv.FormatUint(stepID, 10)).
+		SetQueryParam("name", name)
+	resp, err := req.Post("/step/{step}/artifacts")
+	return handleClientError(resp, err)
+}
+
 func (c *clientV1) WatchCancel(ctx context.Context, buildID uint64) error {
 	req := c.R(ctx).SetPathParam("build", strconv.FormatUint(buildID, 10))
 	resp, err := req.Post("/build/{build}/watch")
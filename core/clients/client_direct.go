@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"sync"
 
 	v1 "github.com/zc2638/ink/pkg/api/core/v1"
@@ -87,7 +88,7 @@ func (c *clientDirect) StageEnd(_ context.Context, stage *v1.Stage) error {
 }
 
 func (c *clientDirect) StepBegin(_ context.Context, step *v1.Step) error {
-	fmt.Printf("\x1b[1m[STEP] %s\x1b[0m\n", step.Name)
+	fmt.Printf("\x1b[1m[STEP] %s/%s\x1b[0m\n", c.stageName(step.ID), step.Name)
 	return nil
 }
 
@@ -95,17 +96,56 @@ func (c *clientDirect) StepEnd(_ context.Context, _ *v1.Step) error {
 	return nil
 }
 
-func (c *clientDirect) LogUpload(_ context.Context, _ uint64, lines []*livelog.Line, isAll bool) error {
+func (c *clientDirect) LogUpload(_ context.Context, stepID uint64, lines []*livelog.Line, isAll bool) error {
 	if isAll {
 		return nil
 	}
 
+	// the lines are prefixed by the stage name, since the stages may run in parallel.
+	prefix := "[" + c.stageName(stepID) + "] "
 	for _, line := range lines {
-		fmt.Print(line.Content)
+		fmt.Print(prefix + line.Content)
 	}
 	return nil
 }
 
+func (c *clientDirect) ServiceLogUpload(ctx context.Context, stageID uint64, lines []*livelog.Line, isAll bool) error {
+	if isAll {
+		return nil
+	}
+
+	data, err := c.Info(ctx, stageID)
+	if err != nil {
+		return err
+	}
+	prefix := "[" + data.Status.Name + ":services] "
+	for _, line := range lines {
+		fmt.Print(prefix + line.Content)
+	}
+	return nil
+}
+
+// stageName returns the name of the stage which the step belongs to.
+func (c *clientDirect) stageName(stepID uint64) string {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	for _, data := range c.ds {
+		for _, step := range data.Status.Steps {
+			if step.ID == stepID {
+				return data.Status.Name
+			}
+		}
+	}
+	return ""
+}
+
+func (c *clientDirect) ArtifactUpload(_ context.Context, _ uint64, name string, r io.Reader) error {
+	fmt.Printf("\x1b[1m[ARTIFACT] %s\x1b[0m\n", name)
+	_, err := io.Copy(io.Discard, r)
+	return err
+}
+
 func (c *clientDirect) WatchCancel(ctx context.Context, _ uint64) error {
 	<-ctx.Done()
 	return ctx.Err()
@@ -17,6 +17,7 @@ package clients
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"strconv"
 	"strings"
 	"time"
@@ -54,37 +55,73 @@ type ServerV1 interface {
 	BuildList(ctx context.Context, namespace, name string, page v1.Pagination) ([]*v1.Build, *v1.Pagination, error)
 	BuildInfo(ctx context.Context, namespace, name string, number uint64) (*v1.Build, error)
 	BuildCreate(ctx context.Context, namespace, name string, settings map[string]string) (uint64, error)
+	BuildRerun(ctx context.Context, namespace, name string, number uint64, failedOnly bool) (uint64, error)
 	BuildCancel(ctx context.Context, namespace, name string, number uint64) error
+	BuildApprove(ctx context.Context, namespace, name string, number, stage uint64, approval *v1.StageApproval) error
+	BuildReject(ctx context.Context, namespace, name string, number, stage uint64, approval *v1.StageApproval) error
+	BuildNotifications(ctx context.Context, namespace, name string, number uint64) ([]*v1.Notification, error)
+	BuildEvents(ctx context.Context, namespace, name string, number uint64) (<-chan *v1.PhaseEvent, <-chan error, error)
+	Events(ctx context.Context, namespace string) (<-chan *v1.PhaseEvent, <-chan error, error)
+
+	NotifierList(ctx context.Context, namespace string, opt v1.ListOption) ([]*v1.Notifier, *v1.Pagination, error)
+	NotifierInfo(ctx context.Context, namespace, name string) (*v1.Notifier, error)
+	NotifierCreate(ctx context.Context, data *v1.Notifier) error
+	NotifierUpdate(ctx context.Context, data *v1.Notifier) error
+	NotifierDelete(ctx context.Context, namespace, name string) error
 
 	LogInfo(ctx context.Context, namespace, name string, number, stage, step uint64) ([]*livelog.Line, error)
 	LogWatch(ctx context.Context, namespace, name string, number, stage, step uint64) (<-chan *livelog.Line, <-chan error, error)
+	ServiceLogWatch(ctx context.Context, namespace, name string, number, stage uint64) (<-chan *livelog.Line, <-chan error, error)
+
+	ArtifactList(ctx context.Context, namespace, name string, number uint64) ([]*v1.Artifact, error)
+	ArtifactDownload(ctx context.Context, namespace, name string, number, stage, step uint64, artifactName string) (io.ReadCloser, error)
+
+	TokenList(ctx context.Context) ([]*v1.Token, error)
+	TokenCreate(ctx context.Context, data *v1.Token) (*v1.Token, error)
+	TokenDelete(ctx context.Context, name string) error
 }
 
-func NewServer(addr string) (Server, error) {
+// NewServer returns the client of the core api,
+// the token is sent as the bearer token if it is not empty.
+func NewServer(addr, token string) (Server, error) {
 	if err := validateURI(addr); err != nil {
 		return nil, err
 	}
-	return &server{Address: addr}, nil
+	return &server{Address: addr, token: token}, nil
 }
 
 type server struct {
 	Address string
+	token   string
 }
 
 func (s *server) V1() ServerV1 {
 	addr := strings.TrimSuffix(s.Address, "/")
 	rc := resty.New().SetBaseURL(addr + "/api/core/v1").SetTimeout(time.Minute)
-	return &serverV1{rc: rc}
+	// the streaming responses last as long as the context.
+	sc := resty.New().SetBaseURL(addr + "/api/core/v1")
+	if len(s.token) > 0 {
+		rc.SetAuthToken(s.token)
+		sc.SetAuthToken(s.token)
+	}
+	return &serverV1{rc: rc, sc: sc}
 }
 
 type serverV1 struct {
 	rc *resty.Client
+	sc *resty.Client
 }
 
 func (c *serverV1) R(ctx context.Context) *resty.Request {
 	return c.rc.R().SetContext(ctx)
 }
 
+// Stream returns a request without the total timeout,
+// it is used for the long-lived responses, e.g. watching and downloading.
+func (c *serverV1) Stream(ctx context.Context) *resty.Request {
+	return c.sc.R().SetContext(ctx)
+}
+
 func (c *serverV1) SecretList(ctx context.Context, namespace string, opt v1.ListOption) ([]*v1.Secret, *v1.Pagination, error) {
 	type resultT struct {
 		v1.Pagination
@@ -305,6 +342,21 @@ func (c *serverV1) BuildCreate(ctx context.Context, namespace, name string, sett
 	return result, nil
 }
 
+func (c *serverV1) BuildRerun(ctx context.Context, namespace, name string, number uint64, failedOnly bool) (uint64, error) {
+	var result uint64
+	req := c.R(ctx).
+		SetPathParam("namespace", namespace).
+		SetPathParam("name", name).
+		SetPathParam("number", strconv.FormatUint(number, 10)).
+		SetQueryParam("failedOnly", strconv.FormatBool(failedOnly)).
+		SetResult(&result)
+	resp, err := req.Post("/box/{namespace}/{name}/build/{number}/rerun")
+	if err := handleClientError(resp, err); err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
 func (c *serverV1) BuildCancel(ctx context.Context, namespace, name string, number uint64) error {
 	req := c.R(ctx).
 		SetPathParam("namespace", namespace).
@@ -314,6 +366,131 @@ func (c *serverV1) BuildCancel(ctx context.Context, namespace, name string, numb
 	return handleClientError(resp, err)
 }
 
+func (c *serverV1) BuildApprove(ctx context.Context, namespace, name string, number, stage uint64, approval *v1.StageApproval) error {
+	req := c.R(ctx).
+		SetPathParam("namespace", namespace).
+		SetPathParam("name", name).
+		SetPathParam("number", strconv.FormatUint(number, 10)).
+		SetPathParam("stage", strconv.FormatUint(stage, 10)).
+		SetBody(approval)
+	resp, err := req.Post("/box/{namespace}/{name}/build/{number}/stages/{stage}/approve")
+	return handleClientError(resp, err)
+}
+
+func (c *serverV1) BuildReject(ctx context.Context, namespace, name string, number, stage uint64, approval *v1.StageApproval) error {
+	req := c.R(ctx).
+		SetPathParam("namespace", namespace).
+		SetPathParam("name", name).
+		SetPathParam("number", strconv.FormatUint(number, 10)).
+		SetPathParam("stage", strconv.FormatUint(stage, 10)).
+		SetBody(approval)
+	resp, err := req.Post("/box/{namespace}/{name}/build/{number}/stages/{stage}/reject")
+	return handleClientError(resp, err)
+}
+
+func (c *serverV1) BuildNotifications(ctx context.Context, namespace, name string, number uint64) ([]*v1.Notification, error) {
+	var result []*v1.Notification
+	req := c.R(ctx).
+		SetPathParam("namespace", namespace).
+		SetPathParam("name", name).
+		SetPathParam("number", strconv.FormatUint(number, 10)).
+		SetResult(&result)
+	resp, err := req.Get("/box/{namespace}/{name}/build/{number}/notifications")
+	if err := handleClientError(resp, err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *serverV1) BuildEvents(ctx context.Context, namespace, name string, number uint64) (<-chan *v1.PhaseEvent, <-chan error, error) {
+	req := c.Stream(ctx).
+		SetPathParam("namespace", namespace).
+		SetPathParam("name", name).
+		SetPathParam("number", strconv.FormatUint(number, 10)).
+		SetDoNotParseResponse(true)
+	resp, err := req.Get("/box/{namespace}/{name}/build/{number}/events")
+	if err := handleClientError(resp, err); err != nil {
+		return nil, nil, err
+	}
+
+	receiver := sse.NewReceiver[*v1.PhaseEvent](resp.RawBody(), nil)
+	go receiver.Run(ctx)
+	return receiver.Data(), receiver.Err(), nil
+}
+
+func (c *serverV1) Events(ctx context.Context, namespace string) (<-chan *v1.PhaseEvent, <-chan error, error) {
+	uri := "/events"
+	req := c.Stream(ctx).SetDoNotParseResponse(true)
+	if len(namespace) > 0 {
+		req.SetPathParam("namespace", namespace)
+		uri = "/events/{namespace}"
+	}
+	resp, err := req.Get(uri)
+	if err := handleClientError(resp, err); err != nil {
+		return nil, nil, err
+	}
+
+	receiver := sse.NewReceiver[*v1.PhaseEvent](resp.RawBody(), nil)
+	go receiver.Run(ctx)
+	return receiver.Data(), receiver.Err(), nil
+}
+
+func (c *serverV1) NotifierList(ctx context.Context, namespace string, opt v1.ListOption) ([]*v1.Notifier, *v1.Pagination, error) {
+	type resultT struct {
+		v1.Pagination
+		Items []*v1.Notifier `json:"items"`
+	}
+
+	var result resultT
+	uri := "/notifier"
+	req := c.R(ctx).SetResult(&result).SetQueryParamsFromValues(opt.ToValues())
+	if len(namespace) > 0 {
+		req.SetPathParam("namespace", namespace)
+		uri = "/notifier/{namespace}"
+	}
+	resp, err := req.Get(uri)
+	if err := handleClientError(resp, err); err != nil {
+		return nil, nil, err
+	}
+	return result.Items, &result.Pagination, nil
+}
+
+func (c *serverV1) NotifierInfo(ctx context.Context, namespace, name string) (*v1.Notifier, error) {
+	var result v1.Notifier
+	req := c.R(ctx).
+		SetPathParam("namespace", namespace).
+		SetPathParam("name", name).
+		SetResult(&result)
+	resp, err := req.Get("/notifier/{namespace}/{name}")
+	if err := handleClientError(resp, err); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *serverV1) NotifierCreate(ctx context.Context, data *v1.Notifier) error {
+	req := c.R(ctx).SetBody(data)
+	resp, err := req.Post("/notifier")
+	return handleClientError(resp, err)
+}
+
+func (c *serverV1) NotifierUpdate(ctx context.Context, data *v1.Notifier) error {
+	req := c.R(ctx).
+		SetBody(data).
+		SetPathParam("namespace", data.GetNamespace()).
+		SetPathParam("name", data.GetName())
+	resp, err := req.Put("/notifier/{namespace}/{name}")
+	return handleClientError(resp, err)
+}
+
+func (c *serverV1) NotifierDelete(ctx context.Context, namespace, name string) error {
+	req := c.R(ctx).
+		SetPathParam("namespace", namespace).
+		SetPathParam("name", name)
+	resp, err := req.Delete("/notifier/{namespace}/{name}")
+	return handleClientError(resp, err)
+}
+
 func (c *serverV1) LogInfo(ctx context.Context, namespace, name string, number, stage, step uint64) ([]*livelog.Line, error) {
 	req := c.R(ctx).
 		SetPathParam("namespace", namespace).
@@ -333,7 +510,7 @@ func (c *serverV1) LogInfo(ctx context.Context, namespace, name string, number,
 }
 
 func (c *serverV1) LogWatch(ctx context.Context, namespace, name string, number, stage, step uint64) (<-chan *livelog.Line, <-chan error, error) {
-	req := c.R(ctx).
+	req := c.Stream(ctx).
 		SetPathParam("namespace", namespace).
 		SetPathParam("name", name).
 		SetPathParam("number", strconv.FormatUint(number, 10)).
@@ -349,3 +526,79 @@ func (c *serverV1) LogWatch(ctx context.Context, namespace, name string, number,
 	go receiver.Run(ctx)
 	return receiver.Data(), receiver.Err(), nil
 }
+
+func (c *serverV1) ServiceLogWatch(ctx context.Context, namespace, name string, number, stage uint64) (<-chan *livelog.Line, <-chan error, error) {
+	req := c.Stream(ctx).
+		SetPathParam("namespace", namespace).
+		SetPathParam("name", name).
+		SetPathParam("number", strconv.FormatUint(number, 10)).
+		SetPathParam("stage", strconv.FormatUint(stage, 10)).
+		SetDoNotParseResponse(true)
+	resp, err := req.Post("/box/{namespace}/{name}/build/{number}/logs/{stage}/services")
+	if err := handleClientError(resp, err); err != nil {
+		return nil, nil, err
+	}
+
+	receiver := sse.NewReceiver[*livelog.Line](resp.RawBody(), nil)
+	go receiver.Run(ctx)
+	return receiver.Data(), receiver.Err(), nil
+}
+
+func (c *serverV1) ArtifactList(ctx context.Context, namespace, name string, number uint64) ([]*v1.Artifact, error) {
+	var result []*v1.Artifact
+	req := c.R(ctx).
+		SetPathParam("namespace", namespace).
+		SetPathParam("name", name).
+		SetPathParam("number", strconv.FormatUint(number, 10)).
+		SetResult(&result)
+	resp, err := req.Get("/box/{namespace}/{name}/build/{number}/artifacts")
+	if err := handleClientError(resp, err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *serverV1) ArtifactDownload(
+	ctx context.Context,
+	namespace, name string,
+	number, stage, step uint64,
+	artifactName string,
+) (io.ReadCloser, error) {
+	req := c.Stream(ctx).
+		SetPathParam("namespace", namespace).
+		SetPathParam("name", name).
+		SetPathParam("number", strconv.FormatUint(number, 10)).
+		SetPathParam("stage", strconv.FormatUint(stage, 10)).
+		SetPathParam("step", strconv.FormatUint(step, 10)).
+		SetQueryParam("name", artifactName).
+		SetDoNotParseResponse(true)
+	resp, err := req.Get("/box/{namespace}/{name}/build/{number}/artifacts/{stage}/{step}")
+	if err := handleClientError(resp, err); err != nil {
+		return nil, err
+	}
+	return resp.RawBody(), nil
+}
+
+func (c *serverV1) TokenList(ctx context.Context) ([]*v1.Token, error) {
+	var result []*v1.Token
+	resp, err := c.R(ctx).SetResult(&result).Get("/token")
+	if err := handleClientError(resp, err); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *serverV1) TokenCreate(ctx context.Context, data *v1.Token) (*v1.Token, error) {
+	var result v1.Token
+	resp, err := c.R(ctx).SetBody(data).SetResult(&result).Post("/token")
+	if err := handleClientError(resp, err); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *serverV1) TokenDelete(ctx context.Context, name string) error {
+	req := c.R(ctx).SetPathParam("name", name)
+	resp, err := req.Delete("/token/{name}")
+	return handleClientError(resp, err)
+}
@@ -16,17 +16,22 @@ package command
 
 import (
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 
 	"github.com/zc2638/ink/core/clients"
 	"github.com/zc2638/ink/core/constant"
 	v1 "github.com/zc2638/ink/pkg/api/core/v1"
 	"github.com/zc2638/ink/pkg/files"
+	"github.com/zc2638/ink/pkg/flags"
 	"github.com/zc2638/ink/pkg/utils"
 )
 
@@ -67,17 +72,83 @@ func Register(cmd *cobra.Command, name string, short string, opts ...any) *cobra
 }
 
 func newServerClient(cmd *cobra.Command) (clients.ServerV1, error) {
-	serverAddr, err := cmd.Flags().GetString("server")
+	f := cmd.Flags()
+	serverAddr, err := f.GetString("server")
+	if err != nil {
+		return nil, err
+	}
+	token, err := f.GetString("token")
+	if err != nil {
+		return nil, err
+	}
+	configPath, err := f.GetString("config")
 	if err != nil {
 		return nil, err
 	}
-	sc, err := clients.NewServer(serverAddr)
+
+	// the flags and the environment variables take precedence over the config file.
+	cfg, err := readCtlConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("read config failed: %v", err)
+	}
+	if len(cfg.Server) > 0 && !f.Changed("server") && len(flags.GetDefaultEnv(constant.Name, "server", "")) == 0 {
+		serverAddr = cfg.Server
+	}
+	if len(token) == 0 {
+		token = cfg.Token
+	}
+
+	sc, err := clients.NewServer(serverAddr, token)
 	if err != nil {
 		return nil, fmt.Errorf("init client failed: %v", err)
 	}
 	return sc.V1(), nil
 }
 
+// CtlConfig is the config file of inkctl.
+type CtlConfig struct {
+	Server string `json:"server,omitempty" yaml:"server,omitempty"`
+	Token  string `json:"token,omitempty" yaml:"token,omitempty"`
+}
+
+// DefaultCtlConfig returns the default path of the config file of inkctl.
+func DefaultCtlConfig() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, "."+constant.Name, "config.yaml")
+}
+
+// readCtlConfig reads the config file of inkctl, the empty config is returned if the file does not exist.
+func readCtlConfig(fp string) (*CtlConfig, error) {
+	var cfg CtlConfig
+	if len(fp) == 0 {
+		return &cfg, nil
+	}
+	b, err := os.ReadFile(fp)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &cfg, nil
+		}
+		return nil, err
+	}
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// ExitError makes the process exit with the code.
+type ExitError struct {
+	Code    int
+	Message string
+}
+
+func (e *ExitError) Error() string {
+	return e.Message
+}
+
 func getNN(args []string) (namespace, name string, err error) {
 	if len(args) == 0 {
 		err = constant.ErrInvalidName
@@ -175,3 +246,32 @@ func ParseFilesToObjects(items []files.Item) (map[string][]v1.UnstructuredObject
 	}
 	return objSet, nil
 }
+
+// readSecret returns the secret defined in the file,
+// the name is optional if the file contains only one secret.
+func readSecret(fp string, name string) (*v1.Secret, error) {
+	if fp == "" {
+		return nil, errors.New("secret file must be defined")
+	}
+	items, err := files.ReadFiles(fp)
+	if err != nil {
+		return nil, err
+	}
+	objSet, err := ParseFilesToObjects(items)
+	if err != nil {
+		return nil, err
+	}
+
+	objs := objSet[v1.KindSecret]
+	for _, obj := range objs {
+		if name != "" && obj.GetName() != name {
+			continue
+		}
+		var secret v1.Secret
+		if err := obj.ToObject(&secret); err != nil {
+			return nil, err
+		}
+		return &secret, nil
+	}
+	return nil, fmt.Errorf("secret %q not found in %s", name, fp)
+}
@@ -18,7 +18,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"maps"
+	"os"
+	"os/user"
+	"path/filepath"
 	"slices"
 	"strconv"
 	"strings"
@@ -26,7 +30,6 @@ import (
 
 	"github.com/99nil/gopkg/printer"
 	"github.com/spf13/cobra"
-	"golang.org/x/sync/errgroup"
 	"gopkg.in/yaml.v3"
 
 	"github.com/zc2638/ink/core/clients"
@@ -34,7 +37,10 @@ import (
 	"github.com/zc2638/ink/core/worker"
 	"github.com/zc2638/ink/core/worker/hooks"
 	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+	"github.com/zc2638/ink/pkg/artifact"
+	"github.com/zc2638/ink/pkg/cache"
 	"github.com/zc2638/ink/pkg/flags"
+	"github.com/zc2638/ink/pkg/livelog"
 )
 
 func NewCtl() *cobra.Command {
@@ -46,6 +52,14 @@ func NewCtl() *cobra.Command {
 		flags.NewStringEnvFlag(constant.Name, "server", "http://localhost:2678",
 			"the address and port of the inkd API server"),
 	)
+	persistentFlags.AddGoFlag(
+		flags.NewStringEnvFlag(constant.Name, "token", "",
+			"the token to authenticate with the inkd API server"),
+	)
+	persistentFlags.AddGoFlag(
+		flags.NewStringEnvFlag(constant.Name, "config", DefaultCtlConfig(),
+			"the config file that defines the server and token"),
+	)
 	persistentFlags.AddGoFlag(
 		flags.NewBoolEnvFlag(constant.Name, "direct", false,
 			"If true, the request will be executed directly by the built-in worker without request inkd"),
@@ -60,8 +74,25 @@ func NewCtl() *cobra.Command {
 			"that contains the configuration to exec"),
 		flags.NewStringSliceEnvFlag(constant.Name, "set", nil,
 			"set the required parameters when execute. e.g. a=1"),
+		flags.NewStringEnvFlag(constant.Name, "cache-dir", cache.DefaultDir(),
+			"the directory to store the caches"),
 	)
 
+	logsCmd := Register(cmd, "logs", "print the logs of the steps of a build", logs, logsExample)
+	logsCmd.Flags().BoolP("follow", "f", false, "follow the logs until the build is done")
+	logsCmd.Flags().BoolP("timestamps", "t", false, "show the time of each line")
+	logsCmd.Flags().Bool("services", false, "follow the output of the services of a running stage")
+
+	cacheCmd := &cobra.Command{Use: "cache", Short: "cache operation of the local worker"}
+	cacheCmd.PersistentFlags().AddGoFlag(
+		flags.NewStringEnvFlag(constant.Name, "cache-dir", cache.DefaultDir(),
+			"the directory to store the caches"),
+	)
+	Register(cacheCmd, "list", "list caches", cacheList, cacheListExample)
+	cachePurgeCmd := Register(cacheCmd, "purge", "purge caches", cachePurge, cachePurgeExample)
+	cachePurgeCmd.Flags().Bool("all", false, "purge all caches")
+	cachePurgeCmd.Flags().Duration("older-than", 0, "only purge the caches unused for longer than the duration")
+
 	secretCmd := &cobra.Command{Use: "secret", Short: "secret operation"}
 	Register(secretCmd, "list", "list secrets", secretList, secretListExample)
 	Register(secretCmd, "delete", "delete secret", secretDelete, secretDeleteExample)
@@ -71,6 +102,11 @@ func NewCtl() *cobra.Command {
 	Register(workflowCmd, "list", "list workflows", workflowList, workflowListExample)
 	Register(workflowCmd, "delete", "delete workflow", workflowDelete, workflowDeleteExample)
 
+	notifierCmd := &cobra.Command{Use: "notifier", Short: "notifier operation"}
+	Register(notifierCmd, "get", "get notifier info", notifierGet, notifierGetExample)
+	Register(notifierCmd, "list", "list notifiers", notifierList, notifierListExample)
+	Register(notifierCmd, "delete", "delete notifier", notifierDelete, notifierDeleteExample)
+
 	boxCmd := &cobra.Command{Use: "box", Short: "box operation"}
 	Register(boxCmd, "get", "get box info", boxGet, boxGetExample)
 	Register(boxCmd, "list", "list boxes", boxList, boxListExample)
@@ -82,10 +118,25 @@ func NewCtl() *cobra.Command {
 	Register(buildCmd, "get", "get build info", buildGet, buildGetExample)
 	Register(buildCmd, "list", "list builds", buildList, buildListExample)
 	Register(buildCmd, "cancel", "cancel a build", buildCancel, buildCancelExample)
+	buildRerunCmd := Register(buildCmd, "rerun", "create a new build with the settings of a build", buildRerun, buildRerunExample)
+	buildRerunCmd.Flags().Bool("failed-only", false, "only rerun the failed and canceled stages")
+	buildApproveCmd := Register(buildCmd, "approve", "approve or reject a stage waiting for approval", buildApprove, buildApproveExample)
+	buildApproveCmd.Flags().Bool("reject", false, "reject the stage instead of approving it")
+	buildApproveCmd.Flags().StringP("comment", "m", "", "the comment of the approval")
+	Register(buildCmd, "watch", "watch the phases of a build until it is done", buildWatch, buildWatchExample)
+	Register(buildCmd, "notifications", "list the notification history of a build", buildNotifications, buildNotificationsExample)
 	buildCreateCmd := Register(buildCmd, "create", "create a build", buildCreate, buildCreateExample)
 	buildCreateCmd.Flags().StringArrayP("set", "s", nil, "setting values to workflow")
+	buildArtifactsCmd := Register(buildCmd, "artifacts", "list or download build artifacts", buildArtifacts, buildArtifactsExample)
+	buildArtifactsCmd.Flags().StringP("output", "o", "", "the directory to download the artifacts into")
 
-	cmd.AddCommand(workflowCmd, boxCmd, buildCmd)
+	tokenCmd := &cobra.Command{Use: "token", Short: "token operation"}
+	Register(tokenCmd, "list", "list tokens", tokenList, tokenListExample)
+	Register(tokenCmd, "delete", "delete token", tokenDelete, tokenDeleteExample)
+	tokenCreateCmd := Register(tokenCmd, "create", "create a token", tokenCreate, tokenCreateExample)
+	tokenCreateCmd.Flags().StringArrayP("role", "r", nil, "the role granted in the namespace, e.g. default=editor")
+
+	cmd.AddCommand(workflowCmd, notifierCmd, boxCmd, buildCmd, cacheCmd, tokenCmd)
 	return cmd
 }
 
@@ -191,6 +242,68 @@ func workflowDelete(cmd *cobra.Command, args []string) error {
 	return sc.WorkflowDelete(context.Background(), namespace, name)
 }
 
+func notifierGet(cmd *cobra.Command, args []string) error {
+	namespace, name, err := getNN(args)
+	if err != nil {
+		return err
+	}
+
+	sc, err := newServerClient(cmd)
+	if err != nil {
+		return err
+	}
+	result, err := sc.NotifierInfo(context.Background(), namespace, name)
+	if err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(result)
+	if err != nil {
+		return err
+	}
+	write(b)
+	return nil
+}
+
+func notifierList(cmd *cobra.Command, _ []string) error {
+	sc, err := newServerClient(cmd)
+	if err != nil {
+		return err
+	}
+	opt := v1.ListOption{
+		Pagination: *getPage(cmd),
+	}
+	result, _, err := sc.NotifierList(context.Background(), v1.AllNamespace, opt)
+	if err != nil {
+		return err
+	}
+
+	if len(result) == 0 {
+		writeString("No resources found.")
+		return nil
+	}
+
+	t := printer.NewTab("NAMESPACE", "NAME", "TYPE", "AGE")
+	for _, v := range result {
+		since := time.Since(v.Creation).Round(time.Second)
+		t.Add(v.GetNamespace(), v.GetName(), v.Spec.Type(), since.String())
+	}
+	t.Print()
+	return nil
+}
+
+func notifierDelete(cmd *cobra.Command, args []string) error {
+	namespace, name, err := getNN(args)
+	if err != nil {
+		return err
+	}
+
+	sc, err := newServerClient(cmd)
+	if err != nil {
+		return err
+	}
+	return sc.NotifierDelete(context.Background(), namespace, name)
+}
+
 func boxGet(cmd *cobra.Command, args []string) error {
 	namespace, name, err := getNN(args)
 	if err != nil {
@@ -345,166 +458,881 @@ func buildCancel(cmd *cobra.Command, args []string) error {
 	return sc.BuildCancel(context.Background(), namespace, name, number)
 }
 
-func buildCreate(cmd *cobra.Command, args []string) error {
+func buildRerun(cmd *cobra.Command, args []string) error {
 	namespace, name, err := getNN(args)
 	if err != nil {
 		return err
 	}
-
-	values, err := cmd.Flags().GetStringArray("set")
+	if len(args) < 2 {
+		return errors.New("missing number")
+	}
+	number, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil || number < 1 {
+		return errors.New("invalid number")
+	}
+	failedOnly, err := cmd.Flags().GetBool("failed-only")
 	if err != nil {
 		return err
 	}
-	settings := make(map[string]string)
-	for _, v := range values {
-		parts := strings.SplitN(v, "=", 2)
-		if len(parts) < 2 {
-			continue
-		}
-		settings[parts[0]] = parts[1]
-	}
 
 	sc, err := newServerClient(cmd)
 	if err != nil {
 		return err
 	}
-	number, err := sc.BuildCreate(context.Background(), namespace, name, settings)
+	result, err := sc.BuildRerun(context.Background(), namespace, name, number, failedOnly)
 	if err != nil {
 		return err
 	}
-	writeString(strconv.FormatUint(number, 10))
+	writeString(strconv.FormatUint(result, 10))
 	return nil
 }
 
-func apply(cmd *cobra.Command, _ []string) error {
-	objSet, err := parseObjects(cmd)
+func buildApprove(cmd *cobra.Command, args []string) error {
+	namespace, name, err := getNN(args)
 	if err != nil {
 		return err
 	}
-	sc, err := newServerClient(cmd)
+	if len(args) < 3 {
+		return errors.New("missing number or stage")
+	}
+	number, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil || number < 1 {
+		return errors.New("invalid number")
+	}
+	reject, err := cmd.Flags().GetBool("reject")
+	if err != nil {
+		return err
+	}
+	comment, err := cmd.Flags().GetString("comment")
 	if err != nil {
 		return err
 	}
 
+	sc, err := newServerClient(cmd)
+	if err != nil {
+		return err
+	}
 	ctx := context.Background()
 
-	for _, obj := range objSet[v1.KindSecret] {
-		var data v1.Secret
-		if err := obj.ToObject(&data); err != nil {
-			return err
-		}
-		_, err := sc.SecretInfo(ctx, data.GetNamespace(), data.GetName())
-		if err == nil {
-			if err = sc.SecretUpdate(ctx, &data); err == nil {
-				writeString(fmt.Sprintf("Update: %s", data.Metadata.String()))
-			}
-		} else if errors.Is(err, constant.ErrNoRecord) {
-			if err = sc.SecretCreate(ctx, &data); err == nil {
-				writeString(fmt.Sprintf("Create: %s", data.Metadata.String()))
-			}
-		}
+	// the stage is specified by the number or the name.
+	stage, err := strconv.ParseUint(args[2], 10, 64)
+	if err != nil {
+		build, err := sc.BuildInfo(ctx, namespace, name, number)
 		if err != nil {
 			return err
 		}
-	}
-	for _, obj := range objSet[v1.KindWorkflow] {
-		var data v1.Workflow
-		if err := obj.ToObject(&data); err != nil {
-			return err
-		}
-		_, err := sc.WorkflowInfo(ctx, data.GetNamespace(), data.GetName())
-		if err == nil {
-			if err = sc.WorkflowUpdate(ctx, &data); err == nil {
-				writeString(fmt.Sprintf("Update: %s", data.Metadata.String()))
-			}
-		} else if errors.Is(err, constant.ErrNoRecord) {
-			if err = sc.WorkflowCreate(ctx, &data); err == nil {
-				writeString(fmt.Sprintf("Create: %s", data.Metadata.String()))
+		for _, v := range build.Stages {
+			if v.Name == args[2] {
+				stage = v.Number
+				break
 			}
 		}
-		if err != nil {
-			return err
+		if stage == 0 {
+			return fmt.Errorf("stage not found: %s", args[2])
 		}
 	}
 
-	for _, obj := range objSet[v1.KindBox] {
-		var data v1.Box
-		if err := obj.ToObject(&data); err != nil {
-			return err
-		}
-		_, err := sc.BoxInfo(ctx, data.GetNamespace(), data.GetName())
-		if err == nil {
-			if err = sc.BoxUpdate(ctx, &data); err == nil {
-				writeString(fmt.Sprintf("Update: %s", data.Metadata.String()))
-			}
-		} else if errors.Is(err, constant.ErrNoRecord) {
-			if err = sc.BoxCreate(ctx, &data); err == nil {
-				writeString(fmt.Sprintf("Create: %s", data.Metadata.String()))
-			}
-		}
-		if err != nil {
-			return err
-		}
+	// the approver is replaced by the token if the authentication is enabled.
+	approval := &v1.StageApproval{Comment: comment}
+	if u, err := user.Current(); err == nil {
+		approval.Approver = u.Username
 	}
-	return nil
+	if reject {
+		return sc.BuildReject(ctx, namespace, name, number, stage, approval)
+	}
+	return sc.BuildApprove(ctx, namespace, name, number, stage, approval)
 }
 
-func exec(cmd *cobra.Command, _ []string) error {
-	setValues, err := cmd.Flags().GetStringSlice("set")
+func buildWatch(cmd *cobra.Command, args []string) error {
+	namespace, name, err := getNN(args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return errors.New("missing number")
+	}
+	number, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil || number < 1 {
+		return errors.New("invalid number")
+	}
+
+	sc, err := newServerClient(cmd)
 	if err != nil {
 		return err
 	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	settings := make(map[string]string)
-	for _, v := range setValues {
-		parts := strings.SplitN(v, "=", 2)
-		if len(parts) != 2 {
-			continue
+	// watch before the build is got, so that no transition is missed.
+	eventCh, errCh, err := sc.BuildEvents(ctx, namespace, name, number)
+	if err != nil {
+		return err
+	}
+	build, err := sc.BuildInfo(ctx, namespace, name, number)
+	if err != nil {
+		return err
+	}
+	tree := newBuildTree(namespace, name, build)
+	tree.Update(build)
+	if build.Phase.IsDone() {
+		return nil
+	}
+
+	for {
+		select {
+		case err := <-errCh:
+			if err != nil && !errors.Is(err, io.EOF) {
+				return err
+			}
+			return nil
+		case event, ok := <-eventCh:
+			if !ok {
+				return nil
+			}
+			tree.Apply(event)
+			if event.Kind == v1.PhaseEventStep || !event.Phase.IsDone() {
+				continue
+			}
+			// the steps not reported by the worker are updated with the stage,
+			// e.g. skipped or canceled, so the build is got again.
+			build, err := sc.BuildInfo(ctx, namespace, name, number)
+			if err != nil {
+				return err
+			}
+			tree.Update(build)
+			if event.Kind == v1.PhaseEventBuild {
+				return nil
+			}
 		}
-		settings[parts[0]] = parts[1]
 	}
+}
 
-	objSet, err := parseObjects(cmd)
+// logInterval is the interval to check the phases of the steps when following the logs.
+const logInterval = 2 * time.Second
+
+// logTarget is the step to print the logs.
+type logTarget struct {
+	stage *v1.Stage
+	step  *v1.Step
+}
+
+func logs(cmd *cobra.Command, args []string) error {
+	namespace, name, err := getNN(args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return errors.New("missing number")
+	}
+	number, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil || number < 1 {
+		return errors.New("invalid number")
+	}
+	f := cmd.Flags()
+	follow, err := f.GetBool("follow")
+	if err != nil {
+		return err
+	}
+	timestamps, err := f.GetBool("timestamps")
 	if err != nil {
 		return err
 	}
-	dataCh := make(chan *v1.Data)
-	wc := clients.NewClientDirect(dataCh)
 
-	allSecrets := make([]*v1.Secret, 0)
-	for _, obj := range objSet[v1.KindSecret] {
-		var item v1.Secret
-		if err := obj.ToObject(&item); err != nil {
-			return err
+	sc, err := newServerClient(cmd)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	build, err := sc.BuildInfo(ctx, namespace, name, number)
+	if err != nil {
+		return err
+	}
+	targets, err := findLogTargets(build, args[2:])
+	if err != nil {
+		return err
+	}
+	services, err := f.GetBool("services")
+	if err != nil {
+		return err
+	}
+	if services {
+		if len(args) < 3 {
+			return errors.New("missing stage")
 		}
-		allSecrets = append(allSecrets, &item)
+		for _, stage := range build.Stages {
+			if stage.Name == args[2] || strconv.FormatUint(stage.Number, 10) == args[2] {
+				return printServiceLogs(ctx, sc, namespace, name, number, stage, timestamps)
+			}
+		}
+		return fmt.Errorf("stage not found: %s", args[2])
 	}
 
-	allWorkflows := make([]*v1.Workflow, 0)
-	for _, obj := range objSet[v1.KindWorkflow] {
-		var item v1.Workflow
-		if err := obj.ToObject(&item); err != nil {
+	for _, target := range targets {
+		prefix := ""
+		if len(targets) > 1 {
+			prefix = fmt.Sprintf("[%s/%s] ", target.stage.Name, target.step.Name)
+		}
+		if follow {
+			// wait for the step to begin.
+			for !target.step.Phase.IsDone() && target.step.Phase != v1.PhaseRunning {
+				if build.Phase.IsDone() {
+					break
+				}
+				time.Sleep(logInterval)
+				if build, err = sc.BuildInfo(ctx, namespace, name, number); err != nil {
+					return err
+				}
+				target = findLogTarget(build, target)
+			}
+		}
+		if err := printLogs(ctx, sc, namespace, name, number, target, prefix, timestamps, follow); err != nil {
 			return err
 		}
-		allWorkflows = append(allWorkflows, &item)
 	}
 
-	allBoxes := make([]*v1.Box, 0)
-	for _, obj := range objSet[v1.KindBox] {
-		var box v1.Box
-		if err := obj.ToObject(&box); err != nil {
-			return err
+	if follow {
+		for !build.Phase.IsDone() {
+			time.Sleep(logInterval)
+			if build, err = sc.BuildInfo(ctx, namespace, name, number); err != nil {
+				return err
+			}
 		}
-		allBoxes = append(allBoxes, &box)
 	}
-	if len(allBoxes) == 0 {
-		return execBuild(wc, dataCh, allWorkflows, allSecrets, settings)
+	if build.Phase.IsDone() && !build.Phase.IsSucceeded() {
+		code := 1
+		if build.Phase == v1.PhaseCanceled {
+			code = 2
+		}
+		return &ExitError{Code: code, Message: fmt.Sprintf("build %d %s", build.Number, build.Phase)}
 	}
+	return nil
+}
 
-	for _, box := range allBoxes {
-		currentSettings := make(map[string]string)
-		maps.Copy(currentSettings, box.Settings)
-		maps.Copy(currentSettings, settings)
+// findLogTargets returns the steps selected by the stage and step names or numbers in the args.
+func findLogTargets(build *v1.Build, args []string) ([]logTarget, error) {
+	match := func(number uint64, name, arg string) bool {
+		return name == arg || strconv.FormatUint(number, 10) == arg
+	}
+
+	var targets []logTarget
+	var stageFound bool
+	for _, stage := range build.Stages {
+		if len(args) > 0 && !match(stage.Number, stage.Name, args[0]) {
+			continue
+		}
+		stageFound = true
+		for _, step := range stage.Steps {
+			if len(args) > 1 && !match(step.Number, step.Name, args[1]) {
+				continue
+			}
+			targets = append(targets, logTarget{stage: stage, step: step})
+		}
+	}
+	if len(args) > 0 && !stageFound {
+		return nil, fmt.Errorf("stage not found: %s", args[0])
+	}
+	if len(args) > 1 && len(targets) == 0 {
+		return nil, fmt.Errorf("step not found: %s", args[1])
+	}
+	return targets, nil
+}
+
+// findLogTarget returns the target with the latest phases of the build.
+func findLogTarget(build *v1.Build, target logTarget) logTarget {
+	for _, stage := range build.Stages {
+		if stage.Number != target.stage.Number {
+			continue
+		}
+		for _, step := range stage.Steps {
+			if step.Number == target.step.Number {
+				return logTarget{stage: stage, step: step}
+			}
+		}
+	}
+	return target
+}
+
+// printServiceLogs prints the output of the stage services until the stage is done,
+// the output is not kept after the stage ends.
+func printServiceLogs(
+	ctx context.Context,
+	sc clients.ServerV1,
+	namespace, name string,
+	number uint64,
+	stage *v1.Stage,
+	timestamps bool,
+) error {
+	if stage.Phase != v1.PhaseRunning {
+		return fmt.Errorf("stage %s is not running", stage.Name)
+	}
+
+	lineCh, errCh, err := sc.ServiceLogWatch(ctx, namespace, name, number, stage.Number)
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case err := <-errCh:
+			if err != nil && !errors.Is(err, io.EOF) {
+				return err
+			}
+			return nil
+		case line, ok := <-lineCh:
+			if !ok {
+				return nil
+			}
+			content := line.Content
+			if timestamps && stage.Started > 0 {
+				content = time.Unix(stage.Started+line.Since, 0).Format(time.DateTime) + " " + content
+			}
+			fmt.Println(strings.TrimRight(content, "\n"))
+		}
+	}
+}
+
+func printLogs(
+	ctx context.Context,
+	sc clients.ServerV1,
+	namespace, name string,
+	number uint64,
+	target logTarget,
+	prefix string,
+	timestamps, follow bool,
+) error {
+	step := target.step
+	if step.Phase == v1.PhasePending || step.Phase == v1.PhaseWaiting || step.Phase == v1.PhaseSkipped {
+		return nil
+	}
+
+	printLine := func(line *livelog.Line) {
+		content := prefix + line.Content
+		if timestamps && step.Started > 0 {
+			content = time.Unix(step.Started+line.Since, 0).Format(time.DateTime) + " " + content
+		}
+		fmt.Println(strings.TrimRight(content, "\n"))
+	}
+
+	if follow && step.Phase == v1.PhaseRunning {
+		lineCh, errCh, err := sc.LogWatch(ctx, namespace, name, number, target.stage.Number, step.Number)
+		if err != nil {
+			return err
+		}
+		var count int
+	loop:
+		for {
+			select {
+			case err := <-errCh:
+				if err != nil && !errors.Is(err, io.EOF) {
+					return err
+				}
+				break loop
+			case line, ok := <-lineCh:
+				if !ok {
+					break loop
+				}
+				count++
+				printLine(line)
+			}
+		}
+		// the step ended before watching, the logs are archived.
+		if count > 0 {
+			return nil
+		}
+	}
+
+	lines, err := sc.LogInfo(ctx, namespace, name, number, target.stage.Number, step.Number)
+	if err != nil {
+		if errors.Is(err, constant.ErrNoRecord) {
+			return nil
+		}
+		return err
+	}
+	for _, line := range lines {
+		printLine(line)
+	}
+	return nil
+}
+
+func buildNotifications(cmd *cobra.Command, args []string) error {
+	namespace, name, err := getNN(args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return errors.New("missing number")
+	}
+	number, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil || number < 1 {
+		return errors.New("invalid number")
+	}
+
+	sc, err := newServerClient(cmd)
+	if err != nil {
+		return err
+	}
+	result, err := sc.BuildNotifications(context.Background(), namespace, name, number)
+	if err != nil {
+		return err
+	}
+
+	if len(result) == 0 {
+		writeString("No resources found.")
+		return nil
+	}
+
+	t := printer.NewTab("NOTIFIER", "EVENT", "PHASE", "ATTEMPTS", "CREATED", "ERROR")
+	for _, v := range result {
+		t.Add(
+			v.Notifier,
+			v.Event,
+			v.Phase.String(),
+			strconv.Itoa(v.Attempts),
+			v.Creation.Format(time.DateTime),
+			v.Error,
+		)
+	}
+	t.Print()
+	return nil
+}
+
+func buildCreate(cmd *cobra.Command, args []string) error {
+	namespace, name, err := getNN(args)
+	if err != nil {
+		return err
+	}
+
+	values, err := cmd.Flags().GetStringArray("set")
+	if err != nil {
+		return err
+	}
+	settings := make(map[string]string)
+	for _, v := range values {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) < 2 {
+			continue
+		}
+		settings[parts[0]] = parts[1]
+	}
+
+	sc, err := newServerClient(cmd)
+	if err != nil {
+		return err
+	}
+	number, err := sc.BuildCreate(context.Background(), namespace, name, settings)
+	if err != nil {
+		return err
+	}
+	writeString(strconv.FormatUint(number, 10))
+	return nil
+}
+
+func buildArtifacts(cmd *cobra.Command, args []string) error {
+	namespace, name, err := getNN(args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 {
+		return errors.New("missing number")
+	}
+	number, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil || number < 1 {
+		return errors.New("invalid number")
+	}
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+
+	sc, err := newServerClient(cmd)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	result, err := sc.ArtifactList(ctx, namespace, name, number)
+	if err != nil {
+		return err
+	}
+	if len(result) == 0 {
+		writeString("No resources found.")
+		return nil
+	}
+
+	if output == "" {
+		t := printer.NewTab("STAGE", "STEP", "NAME", "SIZE")
+		for _, v := range result {
+			t.Add(
+				strconv.FormatUint(v.Stage, 10),
+				strconv.FormatUint(v.Step, 10),
+				v.Name,
+				v1.BytesSize(v.Size).String(),
+			)
+		}
+		t.Print()
+		return nil
+	}
+
+	for _, v := range result {
+		artifactName, err := artifact.CleanName(v.Name)
+		if err != nil {
+			return err
+		}
+		fp := filepath.Join(output,
+			strconv.FormatUint(v.Stage, 10),
+			strconv.FormatUint(v.Step, 10),
+			filepath.FromSlash(artifactName),
+		)
+		if err := downloadArtifact(ctx, sc, namespace, name, number, v, fp); err != nil {
+			return fmt.Errorf("download artifact(%s) failed: %v", v.Name, err)
+		}
+		writeString(fp)
+	}
+	return nil
+}
+
+func downloadArtifact(
+	ctx context.Context,
+	sc clients.ServerV1,
+	namespace, name string,
+	number uint64,
+	data *v1.Artifact,
+	fp string,
+) error {
+	rc, err := sc.ArtifactDownload(ctx, namespace, name, number, data.Stage, data.Step, data.Name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if err := os.MkdirAll(filepath.Dir(fp), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.Create(fp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, rc); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func cacheList(cmd *cobra.Command, _ []string) error {
+	cacheDir, err := cmd.Flags().GetString("cache-dir")
+	if err != nil {
+		return err
+	}
+	caches, err := cache.New(cache.Config{Dir: cacheDir})
+	if err != nil {
+		return err
+	}
+	result, err := caches.List()
+	if err != nil {
+		return err
+	}
+
+	if len(result) == 0 {
+		writeString("No resources found.")
+		return nil
+	}
+
+	t := printer.NewTab("ID", "NAMESPACE", "BOX", "VOLUME", "KEY", "SIZE", "LAST USED")
+	for _, v := range result {
+		since := time.Since(v.LastUsed).Round(time.Second)
+		t.Add(v.ID, v.Namespace, v.Box, v.Volume, v.Key, v1.BytesSize(v.Size).String(), since.String())
+	}
+	t.Print()
+	return nil
+}
+
+func cachePurge(cmd *cobra.Command, args []string) error {
+	f := cmd.Flags()
+	cacheDir, err := f.GetString("cache-dir")
+	if err != nil {
+		return err
+	}
+	all, err := f.GetBool("all")
+	if err != nil {
+		return err
+	}
+	olderThan, err := f.GetDuration("older-than")
+	if err != nil {
+		return err
+	}
+
+	var namespace, name string
+	if len(args) > 0 {
+		namespace, name, err = getNN(args)
+		if err != nil {
+			return err
+		}
+	} else if !all && olderThan <= 0 {
+		return errors.New("the box must be specified unless --all or --older-than is set")
+	}
+
+	caches, err := cache.New(cache.Config{Dir: cacheDir})
+	if err != nil {
+		return err
+	}
+	result, err := caches.List()
+	if err != nil {
+		return err
+	}
+	for _, v := range result {
+		if len(args) > 0 && (v.Namespace != namespace || v.Box != name) {
+			continue
+		}
+		if olderThan > 0 && time.Since(v.LastUsed) < olderThan {
+			continue
+		}
+		if err := caches.Remove(v.ID); err != nil {
+			if errors.Is(err, cache.ErrInUse) {
+				writeString(fmt.Sprintf("Skip: %s %s/%s %s is in use", v.ID, v.Namespace, v.Box, v.Volume))
+				continue
+			}
+			return err
+		}
+		writeString(fmt.Sprintf("Purge: %s %s/%s %s", v.ID, v.Namespace, v.Box, v.Volume))
+	}
+	return nil
+}
+
+func tokenList(cmd *cobra.Command, _ []string) error {
+	sc, err := newServerClient(cmd)
+	if err != nil {
+		return err
+	}
+	result, err := sc.TokenList(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if len(result) == 0 {
+		writeString("No resources found.")
+		return nil
+	}
+
+	t := printer.NewTab("NAME", "ROLES", "AGE")
+	for _, v := range result {
+		roles := make([]string, 0, len(v.Roles))
+		for _, rv := range v.Roles {
+			roles = append(roles, rv.Namespace+"="+string(rv.Role))
+		}
+		since := time.Since(v.Creation).Round(time.Second)
+		t.Add(v.Name, strings.Join(roles, ","), since.String())
+	}
+	t.Print()
+	return nil
+}
+
+func tokenCreate(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return constant.ErrInvalidName
+	}
+	roleValues, err := cmd.Flags().GetStringArray("role")
+	if err != nil {
+		return err
+	}
+
+	data := &v1.Token{Name: args[0]}
+	for _, v := range roleValues {
+		namespace, role, ok := strings.Cut(v, "=")
+		if !ok {
+			return fmt.Errorf("invalid role: %s", v)
+		}
+		data.Roles = append(data.Roles, v1.RoleBinding{Namespace: namespace, Role: v1.Role(role)})
+	}
+	if err := data.Validate(); err != nil {
+		return err
+	}
+
+	sc, err := newServerClient(cmd)
+	if err != nil {
+		return err
+	}
+	result, err := sc.TokenCreate(context.Background(), data)
+	if err != nil {
+		return err
+	}
+	writeString(fmt.Sprintf("Token %s created, it will not be shown again:", result.Name))
+	writeString(result.Token)
+	return nil
+}
+
+func tokenDelete(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return constant.ErrInvalidName
+	}
+	sc, err := newServerClient(cmd)
+	if err != nil {
+		return err
+	}
+	return sc.TokenDelete(context.Background(), args[0])
+}
+
+func apply(cmd *cobra.Command, _ []string) error {
+	objSet, err := parseObjects(cmd)
+	if err != nil {
+		return err
+	}
+	sc, err := newServerClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	for _, obj := range objSet[v1.KindSecret] {
+		var data v1.Secret
+		if err := obj.ToObject(&data); err != nil {
+			return err
+		}
+		_, err := sc.SecretInfo(ctx, data.GetNamespace(), data.GetName())
+		if err == nil {
+			if err = sc.SecretUpdate(ctx, &data); err == nil {
+				writeString(fmt.Sprintf("Update: %s", data.Metadata.String()))
+			}
+		} else if errors.Is(err, constant.ErrNoRecord) {
+			if err = sc.SecretCreate(ctx, &data); err == nil {
+				writeString(fmt.Sprintf("Create: %s", data.Metadata.String()))
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, obj := range objSet[v1.KindWorkflow] {
+		var data v1.Workflow
+		if err := obj.ToObject(&data); err != nil {
+			return err
+		}
+		_, err := sc.WorkflowInfo(ctx, data.GetNamespace(), data.GetName())
+		if err == nil {
+			if err = sc.WorkflowUpdate(ctx, &data); err == nil {
+				writeString(fmt.Sprintf("Update: %s", data.Metadata.String()))
+			}
+		} else if errors.Is(err, constant.ErrNoRecord) {
+			if err = sc.WorkflowCreate(ctx, &data); err == nil {
+				writeString(fmt.Sprintf("Create: %s", data.Metadata.String()))
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, obj := range objSet[v1.KindNotifier] {
+		var data v1.Notifier
+		if err := obj.ToObject(&data); err != nil {
+			return err
+		}
+		_, err := sc.NotifierInfo(ctx, data.GetNamespace(), data.GetName())
+		if err == nil {
+			if err = sc.NotifierUpdate(ctx, &data); err == nil {
+				writeString(fmt.Sprintf("Update: %s", data.Metadata.String()))
+			}
+		} else if errors.Is(err, constant.ErrNoRecord) {
+			if err = sc.NotifierCreate(ctx, &data); err == nil {
+				writeString(fmt.Sprintf("Create: %s", data.Metadata.String()))
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, obj := range objSet[v1.KindBox] {
+		var data v1.Box
+		if err := obj.ToObject(&data); err != nil {
+			return err
+		}
+		_, err := sc.BoxInfo(ctx, data.GetNamespace(), data.GetName())
+		if err == nil {
+			if err = sc.BoxUpdate(ctx, &data); err == nil {
+				writeString(fmt.Sprintf("Update: %s", data.Metadata.String()))
+			}
+		} else if errors.Is(err, constant.ErrNoRecord) {
+			if err = sc.BoxCreate(ctx, &data); err == nil {
+				writeString(fmt.Sprintf("Create: %s", data.Metadata.String()))
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exec(cmd *cobra.Command, _ []string) error {
+	setValues, err := cmd.Flags().GetStringSlice("set")
+	if err != nil {
+		return err
+	}
+
+	settings := make(map[string]string)
+	for _, v := range setValues {
+		parts := strings.SplitN(v, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		settings[parts[0]] = parts[1]
+	}
+
+	cacheDir, err := cmd.Flags().GetString("cache-dir")
+	if err != nil {
+		return err
+	}
+	caches, err := cache.New(cache.Config{Dir: cacheDir})
+	if err != nil {
+		return err
+	}
+	ctx := cache.WithContext(context.Background(), caches)
+
+	objSet, err := parseObjects(cmd)
+	if err != nil {
+		return err
+	}
+	allSecrets := make([]*v1.Secret, 0)
+	for _, obj := range objSet[v1.KindSecret] {
+		var item v1.Secret
+		if err := obj.ToObject(&item); err != nil {
+			return err
+		}
+		allSecrets = append(allSecrets, &item)
+	}
+
+	allWorkflows := make([]*v1.Workflow, 0)
+	for _, obj := range objSet[v1.KindWorkflow] {
+		var item v1.Workflow
+		if err := obj.ToObject(&item); err != nil {
+			return err
+		}
+		allWorkflows = append(allWorkflows, &item)
+	}
+
+	allBoxes := make([]*v1.Box, 0)
+	for _, obj := range objSet[v1.KindBox] {
+		var box v1.Box
+		if err := obj.ToObject(&box); err != nil {
+			return err
+		}
+		allBoxes = append(allBoxes, &box)
+	}
+	if len(allBoxes) == 0 {
+		if phase := execBuild(ctx, nil, allWorkflows, allSecrets, settings); phase != v1.PhaseSucceeded {
+			return &ExitError{Code: 1, Message: fmt.Sprintf("build %s", phase)}
+		}
+		return nil
+	}
+
+	var failed []string
+	for _, box := range allBoxes {
+		currentSettings := make(map[string]string)
+		maps.Copy(currentSettings, box.Settings)
+		maps.Copy(currentSettings, settings)
+		if err := box.CheckSettings(currentSettings); err != nil {
+			return fmt.Errorf("box(%s/%s): %v", box.GetNamespace(), box.GetName(), err)
+		}
 
 		var workflows []*v1.Workflow
 		workflowNames, workflowSelectors := box.GetSelectors(v1.KindWorkflow, currentSettings)
@@ -554,47 +1382,47 @@ func exec(cmd *cobra.Command, _ []string) error {
 				secrets = append(secrets, item)
 			}
 		}
-		if err := execBuild(wc, dataCh, workflows, secrets, currentSettings); err != nil {
-			return err
+		if phase := execBuild(ctx, box, workflows, secrets, currentSettings); phase != v1.PhaseSucceeded {
+			failed = append(failed, fmt.Sprintf("%s/%s %s", box.GetNamespace(), box.GetName(), phase))
 		}
 	}
+	if len(failed) > 0 {
+		return &ExitError{Code: 1, Message: "build " + strings.Join(failed, ", ")}
+	}
 	return nil
 }
 
+// execBuild runs the stages of the build by the dependency graph,
+// the independent stages run in parallel, and returns the phase of the build.
 func execBuild(
-	wc clients.WorkerV1,
-	dataCh chan *v1.Data,
-	allWorkflows []*v1.Workflow,
+	ctx context.Context,
+	box *v1.Box,
+	workflows []*v1.Workflow,
 	allSecrets []*v1.Secret,
 	settings map[string]string,
-) error {
+) v1.Phase {
 	build := &v1.Build{
 		Phase:    v1.PhasePending,
 		Settings: settings,
 	}
+	stages := v1.NewStages(workflows, build.CompleteSettings(box))
 
-	// TODO 暂时仅支持 workflow 单独串行执行
-	for _, workflow := range allWorkflows {
-		var (
-			hook worker.Hook
-			err  error
-		)
-		workerObj := workflow.Worker()
-		switch workerObj.Kind {
-		case v1.WorkerKindHost:
-			hook, err = hooks.NewHost()
-			if err != nil {
-				return fmt.Errorf("init host hook failed: %v", err)
-			}
-		case v1.WorkerKindDocker:
-			hook, err = hooks.NewDocker("", "")
-			if err != nil {
-				return fmt.Errorf("init docker hook failed: %v", err)
-			}
-		default:
-			return fmt.Errorf("unsupported kind: %s", workerObj.Kind)
+	dataSet := make([]*v1.Data, 0, len(stages))
+	var stepID uint64
+	for _, stage := range stages {
+		stage.ID = stage.Number
+		// the stages requiring the approval are approved by the user running them locally.
+		if stage.Phase == v1.PhaseWaitingApproval {
+			stage.Phase = v1.PhasePending
+		}
+		for _, step := range stage.Steps {
+			stepID++
+			step.ID = stepID
+			step.StageID = stage.ID
 		}
 
+		index := slices.IndexFunc(workflows, func(w *v1.Workflow) bool { return w.Name == stage.WorkflowName() })
+		workflow := workflows[index]
 		secrets := make([]*v1.Secret, 0)
 		for _, sec := range allSecrets {
 			if sec.GetNamespace() != workflow.GetNamespace() {
@@ -602,42 +1430,129 @@ func execBuild(
 			}
 			secrets = append(secrets, sec)
 		}
-
-		data := &v1.Data{
+		dataSet = append(dataSet, &v1.Data{
+			Box:      box,
 			Build:    build,
 			Workflow: workflow,
-			Status: &v1.Stage{
-				Number:    1,
-				Phase:     v1.PhasePending,
-				Name:      workflow.GetName(),
-				Limit:     workflow.Spec.Concurrency,
-				Worker:    *workflow.Worker(),
-				DependsOn: workflow.Spec.DependsOn,
-			},
-			Secrets: secrets,
-		}
-		for sk, sv := range workflow.Spec.Steps {
-			step := &v1.Step{
-				Number: uint64(sk) + 1,
-				Phase:  v1.PhasePending,
-				Name:   sv.Name,
+			Secrets:  secrets,
+		})
+	}
+
+	done := make(chan *v1.Data)
+	var running int
+	for {
+		// the waiting stages are released once their dependencies are done,
+		// and canceled if any of the dependencies failed.
+		for released := true; released; {
+			released = false
+			for _, stage := range stages {
+				if stage.Phase != v1.PhaseWaiting || !stage.IsDepsDone(stages) {
+					continue
+				}
+				released = true
+				stage.Phase = v1.PhasePending
+				if stage.IsDepsFailed(stages) {
+					cancelStage(stage)
+				}
 			}
-			step.ID = step.Number
-			data.Status.Steps = append(data.Status.Steps, step)
 		}
 
-		eg, ctx := errgroup.WithContext(context.Background())
-		eg.Go(func() error { return worker.Run(ctx, wc, hook) })
-		eg.Go(func() error {
-			select {
-			case dataCh <- data:
-			case <-ctx.Done():
+		for k, stage := range stages {
+			if stage.Phase != v1.PhasePending {
+				continue
 			}
-			return nil
-		})
-		if err := eg.Wait(); err != nil {
-			return err
+			// the worker updates its own copy of the stage.
+			data := dataSet[k]
+			data.Status = copyStage(stage)
+			stage.Phase = v1.PhaseRunning
+			running++
+
+			go func() {
+				if err := runStage(ctx, data); err != nil {
+					status := data.Status
+					status.Phase = v1.PhaseFailed
+					status.Error = err.Error()
+					status.Stopped = time.Now().Unix()
+					if status.Started == 0 {
+						status.Started = status.Stopped
+					}
+				}
+				done <- data
+			}()
+		}
+
+		if running == 0 {
+			break
 		}
+		data := <-done
+		running--
+		stages[data.Status.Number-1] = data.Status
 	}
-	return nil
+
+	phase := v1.PhaseSucceeded
+	t := printer.NewTab("STAGE", "PHASE", "DURATION", "ERROR")
+	for _, stage := range stages {
+		// the dependencies of the stage can never be done.
+		if stage.Phase == v1.PhaseWaiting {
+			cancelStage(stage)
+		}
+		if phase == v1.PhaseSucceeded && (stage.Phase.IsFailed() || stage.Phase == v1.PhaseCanceled) {
+			phase = stage.Phase
+		}
+
+		duration := "-"
+		if stage.Started > 0 && stage.Stopped >= stage.Started {
+			duration = (time.Duration(stage.Stopped-stage.Started) * time.Second).String()
+		}
+		t.Add(stage.Name, stage.Phase.String(), duration, stage.Error)
+	}
+	fmt.Println()
+	t.Print()
+	return phase
+}
+
+// runStage runs the stage by the worker of its kind.
+func runStage(ctx context.Context, data *v1.Data) error {
+	var (
+		hook worker.Hook
+		err  error
+	)
+	switch data.Status.Worker.Kind {
+	case v1.WorkerKindHost:
+		hook, err = hooks.NewHost()
+		if err != nil {
+			return fmt.Errorf("init host hook failed: %v", err)
+		}
+	case v1.WorkerKindDocker:
+		hook, err = hooks.NewDocker("", "")
+		if err != nil {
+			return fmt.Errorf("init docker hook failed: %v", err)
+		}
+	default:
+		return fmt.Errorf("unsupported kind: %s", data.Status.Worker.Kind)
+	}
+
+	dataCh := make(chan *v1.Data, 1)
+	dataCh <- data
+	return worker.Run(ctx, clients.NewClientDirect(dataCh), hook)
+}
+
+func cancelStage(stage *v1.Stage) {
+	now := time.Now().Unix()
+	stage.Phase = v1.PhaseCanceled
+	stage.Started = now
+	stage.Stopped = now
+	for _, step := range stage.Steps {
+		step.Phase = v1.PhaseCanceled
+	}
+}
+
+func copyStage(stage *v1.Stage) *v1.Stage {
+	out := *stage
+	out.Steps = make([]*v1.Step, 0, len(stage.Steps))
+	for _, step := range stage.Steps {
+		v := *step
+		out.Steps = append(out.Steps, &v)
+	}
+	return &out
 }
@@ -16,6 +16,7 @@ package command
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -28,13 +29,21 @@ import (
 	"github.com/zc2638/wslog"
 	"gorm.io/gorm"
 
+	"github.com/zc2638/ink/core/auth"
 	"github.com/zc2638/ink/core/constant"
+	"github.com/zc2638/ink/core/cron"
 	"github.com/zc2638/ink/core/handler"
+	"github.com/zc2638/ink/core/notify"
+	"github.com/zc2638/ink/core/report"
 	"github.com/zc2638/ink/core/scheduler"
 	v1 "github.com/zc2638/ink/pkg/api/core/v1"
 	storageV1 "github.com/zc2638/ink/pkg/api/storage/v1"
+	"github.com/zc2638/ink/pkg/artifact"
 	"github.com/zc2638/ink/pkg/database"
+	"github.com/zc2638/ink/pkg/keyring"
 	"github.com/zc2638/ink/pkg/livelog"
+	"github.com/zc2638/ink/pkg/logstore"
+	"github.com/zc2638/ink/pkg/pubsub"
 	"github.com/zc2638/ink/pkg/queue"
 	"github.com/zc2638/ink/resource"
 )
@@ -47,48 +56,97 @@ func NewDaemon() *cobra.Command {
 		Use:          constant.DaemonName,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			var cfg DaemonConfig
-			if _, err := ParseConfig(opt.ConfigPath, &cfg, constant.DaemonName, opt.ConfigSubKey); err != nil {
-				if _, ok := err.(*os.PathError); !ok {
-					return err
-				}
-				wslog.Warn("Config file not found, use default.")
-			}
-			if err := cfg.Validate(); err != nil {
-				return fmt.Errorf("validate config failed: %v", err)
+			cfg, err := parseDaemonConfig(opt)
+			if err != nil {
+				return err
 			}
 			wslog.Infof("Config: %#v", cfg)
 
 			log := wslog.New(cfg.Logger)
 			ctr.SetLog(ctr.CoverKVLog(log))
 
-			if err := database.AutoDatabase(cfg.Database); err != nil {
-				return fmt.Errorf("auto database failed: %v", err)
-			}
-
-			db, err := database.New(cfg.Database)
+			db, err := initDatabase(cfg.Database)
 			if err != nil {
-				return fmt.Errorf("init database failed: %v", err)
-			}
-			if err := resource.MigrateDatabase(cfg.Database.Driver, cfg.Database.DSN); err != nil {
-				return fmt.Errorf("migrate database failed: %v", err)
+				return err
 			}
 
 			ll, err := livelog.New(cfg.Livelog)
 			if err != nil {
 				return fmt.Errorf("init livelog failed: %v", err)
 			}
+			ls, err := logstore.New(cfg.Logstore, db)
+			if err != nil {
+				return fmt.Errorf("init logstore failed: %v", err)
+			}
+			as, err := artifact.New(cfg.Artifact)
+			if err != nil {
+				return fmt.Errorf("init artifact store failed: %v", err)
+			}
+			kr, err := keyring.New(cfg.Encryption)
+			if err != nil {
+				return fmt.Errorf("init keyring failed: %v", err)
+			}
+			if kr == nil {
+				log.Warn("No encryption key is configured, the secrets are stored in plaintext.")
+			}
+			authn, err := auth.New(cfg.Auth)
+			if err != nil {
+				return fmt.Errorf("init authenticator failed: %v", err)
+			}
+			if !authn.Enabled() {
+				log.Warn("No token is configured, the core api is not protected.")
+			}
+			if !authn.WorkerEnabled() {
+				log.Warn("No worker token is configured, the client api is not protected.")
+			}
+			ctx, cancel := context.WithCancel(wslog.WithContext(context.Background(), log))
+			defer cancel()
 			sched := scheduler.New(listInCompleteStages(db))
+			nt := notify.New(ctx, db, kr)
+			rp := report.New(db, kr)
+			ps := pubsub.New()
+			go rp.Start(ctx)
+			go cron.New(db, sched).Start(report.WithContext(ctx, rp))
 
 			srv := server.New(&cfg.Server)
 			srv.ReadTimeout = 0
 			srv.WriteTimeout = 0
-			srv.Handler = handler.New(log, db, ll, sched)
+			srv.Handler = handler.New(log, db, ll, ls, as, kr, authn, sched, nt, rp, ps)
 			log.Info(fmt.Sprintf("Daemon listen on %s", srv.Addr))
 			return srv.RunAndStop(context.Background())
 		},
 	}
 
+	cmd.AddCommand(&cobra.Command{
+		Use:          "rotate-keys",
+		Short:        "re-encrypt all secrets by the primary encryption key",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := parseDaemonConfig(opt)
+			if err != nil {
+				return err
+			}
+			kr, err := keyring.New(cfg.Encryption)
+			if err != nil {
+				return fmt.Errorf("init keyring failed: %v", err)
+			}
+			if kr == nil {
+				return errors.New("no encryption key is configured")
+			}
+
+			db, err := initDatabase(cfg.Database)
+			if err != nil {
+				return err
+			}
+			count, err := rotateSecretKeys(db, kr)
+			if err != nil {
+				return fmt.Errorf("rotate keys failed: %v", err)
+			}
+			wslog.Infof("Rotated %d secrets to key %s", count, kr.Primary())
+			return nil
+		},
+	})
+
 	cmd.PersistentFlags().StringVarP(&opt.ConfigPath, "config", "c", opt.ConfigPath, "config path")
 	cmd.PersistentFlags().StringVar(&opt.ConfigSubKey, "config-sub-key", opt.ConfigSubKey, "config sub key for config data")
 	return cmd
@@ -105,6 +163,12 @@ type DaemonConfig struct {
 	Database database.Config `json:"database,omitempty"`
 	Queue    queue.Config    `json:"queue,omitempty"`
 	Livelog  livelog.Config  `json:"livelog"`
+	Logstore logstore.Config `json:"logstore,omitempty"`
+	Artifact artifact.Config `json:"artifact,omitempty"`
+	// Auth defines the tokens to authenticate the requests.
+	Auth auth.Config `json:"auth,omitempty"`
+	// Encryption defines the master keys to encrypt the secrets.
+	Encryption keyring.Config `json:"encryption,omitempty"`
 }
 
 func (c *DaemonConfig) Validate() error {
@@ -124,9 +188,69 @@ func (c *DaemonConfig) Validate() error {
 			Dir: filepath.Join(os.TempDir(), constant.Name, "cache"),
 		}
 	}
+	if c.Artifact.File == nil {
+		c.Artifact.File = &artifact.ConfigFile{
+			Dir: filepath.Join(os.TempDir(), constant.Name, "artifacts"),
+		}
+	}
 	return nil
 }
 
+func parseDaemonConfig(opt *DaemonOption) (*DaemonConfig, error) {
+	var cfg DaemonConfig
+	if _, err := ParseConfig(opt.ConfigPath, &cfg, constant.DaemonName, opt.ConfigSubKey); err != nil {
+		if _, ok := err.(*os.PathError); !ok {
+			return nil, err
+		}
+		wslog.Warn("Config file not found, use default.")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("validate config failed: %v", err)
+	}
+	return &cfg, nil
+}
+
+func initDatabase(cfg database.Config) (*gorm.DB, error) {
+	if err := database.AutoDatabase(cfg); err != nil {
+		return nil, fmt.Errorf("auto database failed: %v", err)
+	}
+
+	db, err := database.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("init database failed: %v", err)
+	}
+	if err := resource.MigrateDatabase(cfg.Driver, cfg.DSN); err != nil {
+		return nil, fmt.Errorf("migrate database failed: %v", err)
+	}
+	return db, nil
+}
+
+// rotateSecretKeys re-encrypts the secrets that are not encrypted by the primary key,
+// including the secrets stored in plaintext.
+func rotateSecretKeys(db *gorm.DB, kr *keyring.Keyring) (int, error) {
+	var count int
+	var list []storageV1.Secret
+	err := db.Where("key_id <> ?", kr.Primary()).FindInBatches(&list, 100, func(tx *gorm.DB, _ int) error {
+		for _, v := range list {
+			if err := v.Decrypt(kr); err != nil {
+				return fmt.Errorf("secret(%s/%s): %v", v.Namespace, v.Name, err)
+			}
+			if err := v.Encrypt(kr); err != nil {
+				return fmt.Errorf("secret(%s/%s): %v", v.Namespace, v.Name, err)
+			}
+			if err := db.Model(&storageV1.Secret{}).Where("id = ?", v.ID).Updates(map[string]any{
+				"data":   v.Data,
+				"key_id": v.KeyID,
+			}).Error; err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	}).Error
+	return count, err
+}
+
 func listInCompleteStages(db *gorm.DB) scheduler.StoreFunc {
 	return func(ctx context.Context) ([]*v1.Stage, error) {
 		db = db.WithContext(ctx)
@@ -0,0 +1,95 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+	storageV1 "github.com/zc2638/ink/pkg/api/storage/v1"
+	"github.com/zc2638/ink/pkg/database"
+	"github.com/zc2638/ink/pkg/keyring"
+	"github.com/zc2638/ink/resource"
+)
+
+func TestRotateSecretKeys(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "ink.db")
+	if err := resource.MigrateDatabase("sqlite3", dsn); err != nil {
+		t.Fatal(err)
+	}
+	db, err := database.New(database.Config{Driver: "sqlite3", DSN: dsn})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := &v1.Secret{Data: map[string]string{"key": "value"}}
+	secret.SetNamespace("test")
+	secret.SetName("secret")
+	secretS := new(storageV1.Secret)
+	if err := secretS.FromAPI(secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(secretS).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	v1Key := keyring.Key{ID: "v1", Secret: base64.StdEncoding.EncodeToString([]byte(strings.Repeat("1", 32)))}
+	v2Key := keyring.Key{ID: "v2", Secret: base64.StdEncoding.EncodeToString([]byte(strings.Repeat("2", 32)))}
+	tests := []struct {
+		name      string
+		keys      []keyring.Key
+		wantCount int
+	}{
+		{name: "plaintext to v1", keys: []keyring.Key{v1Key}, wantCount: 1},
+		{name: "v1 to v2", keys: []keyring.Key{v1Key, v2Key}, wantCount: 1},
+		{name: "already rotated", keys: []keyring.Key{v1Key, v2Key}, wantCount: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kr, err := keyring.New(keyring.Config{Keys: tt.keys})
+			if err != nil {
+				t.Fatal(err)
+			}
+			count, err := rotateSecretKeys(db, kr)
+			if err != nil {
+				t.Fatalf("rotateSecretKeys() error = %v", err)
+			}
+			if count != tt.wantCount {
+				t.Fatalf("rotateSecretKeys() = %d, want %d", count, tt.wantCount)
+			}
+
+			out := &storageV1.Secret{Namespace: "test", Name: "secret"}
+			if err := db.Where(out).First(out).Error; err != nil {
+				t.Fatal(err)
+			}
+			if out.KeyID != kr.Primary() {
+				t.Fatalf("rotateSecretKeys() key id = %s, want %s", out.KeyID, kr.Primary())
+			}
+			if err := out.Decrypt(kr); err != nil {
+				t.Fatalf("Decrypt() error = %v", err)
+			}
+			got, err := out.ToAPI()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Data["key"] != "value" {
+				t.Fatalf("rotateSecretKeys() data = %v, want %v", got.Data, secret.Data)
+			}
+		})
+	}
+}
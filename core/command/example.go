@@ -70,6 +70,39 @@ inkctl workflow delete default/test
 inkctl workflow delete test
 `
 
+const notifierListExample Example = `
+# List notifiers (default size: 10)
+inkctl notifier list
+
+# List all notifiers
+inkctl notifier list --size -1
+
+# List notifiers specify the page and size
+inkctl notifier list --size 15 --page 2
+`
+
+const notifierGetExample Example = `
+# Definition
+inkctl notifier get {namespace}/{name}
+
+# Get notifier info
+inkctl notifier get default/test
+
+# Get notifier info with default namespace
+inkctl notifier get test
+`
+
+const notifierDeleteExample Example = `
+# Definition
+inkctl notifier delete {namespace}/{name}
+
+# Delete a notifier
+inkctl notifier delete default/test
+
+# Delete notifier with default namespace
+inkctl notifier delete test
+`
+
 const boxListExample Example = `
 # List boxes (default size: 10)
 inkctl box list
@@ -150,6 +183,71 @@ inkctl build cancel default/test 1
 inkctl build cancel test 1
 `
 
+const logsExample Example = `
+# Definition
+inkctl logs {namespace}/{name} {number} [stage] [step]
+
+# Print the logs of all steps of a build
+inkctl logs default/test 1
+
+# Print the logs of a stage by name or number
+inkctl logs default/test 1 build
+
+# Follow the logs of a step with timestamps,
+# and exit with 1 if the build fails, or 2 if it is canceled
+inkctl logs default/test 1 build 2 -f -t
+
+# Follow the output of the services of a running stage
+inkctl logs default/test 1 build --services
+`
+
+const buildRerunExample Example = `
+# Definition
+inkctl build rerun {namespace}/{name} {number}
+
+# Rerun a build with the same settings
+inkctl build rerun default/test 1
+
+# Rerun only the failed and canceled stages of a build
+inkctl build rerun default/test 1 --failed-only
+`
+
+const buildApproveExample Example = `
+# Definition
+inkctl build approve {namespace}/{name} {number} {stage}
+
+# Approve the stage by the number
+inkctl build approve default/test 1 2
+
+# Approve the stage by the name with a comment
+inkctl build approve default/test 1 deploy -m "release v1.0.0"
+
+# Reject the stage
+inkctl build approve default/test 1 deploy --reject -m "not now"
+`
+
+const buildWatchExample Example = `
+# Definition
+inkctl build watch {namespace}/{name} {number}
+
+# Watch a build
+inkctl build watch default/test 1
+
+# Watch a build with default namespace
+inkctl build watch test 1
+`
+
+const buildNotificationsExample Example = `
+# Definition
+inkctl build notifications {namespace}/{name} {number}
+
+# List the notification history of a build
+inkctl build notifications default/test 1
+
+# List the notification history of a build with default namespace
+inkctl build notifications test 1
+`
+
 const buildCreateExample Example = `
 # Definition
 inkctl build create {namespace}/{name}
@@ -160,3 +258,60 @@ inkctl build create default/test
 # Create a build for the box with default namespace
 inkctl build create test
 `
+
+const buildArtifactsExample Example = `
+# Definition
+inkctl build artifacts {namespace}/{name} {number}
+
+# List the artifacts of a build
+inkctl build artifacts default/test 1
+
+# Download the artifacts of a build into the directory
+inkctl build artifacts default/test 1 -o ./artifacts
+`
+
+const cacheListExample Example = `
+# List the caches of the local worker
+inkctl cache list
+
+# List the caches in the directory
+inkctl cache list --cache-dir /var/lib/ink/caches
+`
+
+const cachePurgeExample Example = `
+# Definition
+inkctl cache purge {namespace}/{name}
+
+# Purge the caches of a box
+inkctl cache purge default/test
+
+# Purge the caches unused for longer than a week
+inkctl cache purge --older-than 168h
+
+# Purge all caches
+inkctl cache purge --all
+`
+
+const tokenListExample Example = `
+# List tokens
+inkctl token list
+`
+
+const tokenCreateExample Example = `
+# Definition
+inkctl token create {name} --role {namespace}={role}
+
+# Create a token to view the default namespace
+inkctl token create viewer --role default=viewer
+
+# Create a token to administer all namespaces
+inkctl token create admin --role "*=admin"
+`
+
+const tokenDeleteExample Example = `
+# Definition
+inkctl token delete {name}
+
+# Delete the token
+inkctl token delete viewer
+`
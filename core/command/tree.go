@@ -0,0 +1,124 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+)
+
+// buildTree renders the phases of the build, its stages and steps as a tree.
+// The tree is redrawn in place on the terminal, otherwise the events are printed line by line.
+type buildTree struct {
+	out       io.Writer
+	live      bool
+	namespace string
+	name      string
+	build     *v1.Build
+	// lines is the number of the lines printed by the last render.
+	lines int
+}
+
+func newBuildTree(namespace, name string, build *v1.Build) *buildTree {
+	live := false
+	if fi, err := os.Stdout.Stat(); err == nil {
+		live = fi.Mode()&os.ModeCharDevice != 0
+	}
+	return &buildTree{out: os.Stdout, live: live, namespace: namespace, name: name, build: build}
+}
+
+// Update replaces the build and renders the tree.
+func (t *buildTree) Update(build *v1.Build) {
+	t.build = build
+	if t.live || t.lines == 0 {
+		t.render()
+	}
+}
+
+// Apply applies the event to the build and renders it.
+func (t *buildTree) Apply(event *v1.PhaseEvent) {
+	switch event.Kind {
+	case v1.PhaseEventBuild:
+		t.build.Phase = event.Phase
+	case v1.PhaseEventStage, v1.PhaseEventStep:
+		for _, stage := range t.build.Stages {
+			if stage.Number != event.Stage {
+				continue
+			}
+			if event.Kind == v1.PhaseEventStage {
+				stage.Phase = event.Phase
+				break
+			}
+			for _, step := range stage.Steps {
+				if step.Number == event.Step {
+					step.Phase = event.Phase
+				}
+			}
+		}
+	}
+
+	if t.live {
+		t.render()
+		return
+	}
+	line := fmt.Sprintf("%s %s", event.Time.Local().Format("15:04:05"), event.Kind)
+	if event.Stage > 0 {
+		line += fmt.Sprintf(" %d", event.Stage)
+	}
+	if event.Step > 0 {
+		line += fmt.Sprintf(".%d", event.Step)
+	}
+	if event.Name != "" {
+		line += " " + event.Name
+	}
+	line += " " + event.Phase.String()
+	if event.Error != "" {
+		line += ": " + event.Error
+	}
+	_, _ = fmt.Fprintln(t.out, line)
+}
+
+func (t *buildTree) render() {
+	var b strings.Builder
+	if t.live && t.lines > 0 {
+		// move the cursor to the beginning of the last render and clear it.
+		fmt.Fprintf(&b, "\033[%dA\033[J", t.lines)
+	}
+
+	lines := []string{fmt.Sprintf("%s/%s #%d %s", t.namespace, t.name, t.build.Number, t.build.Phase)}
+	for i, stage := range t.build.Stages {
+		branch, indent := "├─ ", "│  "
+		if i == len(t.build.Stages)-1 {
+			branch, indent = "└─ ", "   "
+		}
+		lines = append(lines, fmt.Sprintf("%s%d. %s %s", branch, stage.Number, stage.Name, stage.Phase))
+		for j, step := range stage.Steps {
+			stepBranch := "├─ "
+			if j == len(stage.Steps)-1 {
+				stepBranch = "└─ "
+			}
+			lines = append(lines, fmt.Sprintf("%s%s%d. %s %s", indent, stepBranch, step.Number, step.Name, step.Phase))
+		}
+	}
+	for _, line := range lines {
+		b.WriteString(line + "\n")
+	}
+	_, _ = io.WriteString(t.out, b.String())
+	t.lines = len(lines)
+}
@@ -29,7 +29,10 @@ import (
 	"github.com/zc2638/ink/core/constant"
 	"github.com/zc2638/ink/core/worker"
 	"github.com/zc2638/ink/core/worker/hooks"
+	"github.com/zc2638/ink/core/worker/hooks/kubernetes"
+	"github.com/zc2638/ink/core/worker/hooks/ssh"
 	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+	"github.com/zc2638/ink/pkg/cache"
 )
 
 func NewWorker() *cobra.Command {
@@ -52,6 +55,11 @@ func NewWorker() *cobra.Command {
 
 			logger := wslog.New(cfg.Logger)
 
+			caches, err := cache.New(cfg.Cache)
+			if err != nil {
+				return fmt.Errorf("init cache manager failed: %v", err)
+			}
+
 			workers := make([]*worker.Worker, 0, len(cfg.Workers))
 			for k, v := range cfg.Workers {
 				if v.Worker == nil {
@@ -66,17 +74,36 @@ func NewWorker() *cobra.Command {
 						return fmt.Errorf("init host hook failed: %v", err)
 					}
 				case v1.WorkerKindSSH:
+					if v.SSH == nil {
+						return errors.New("ssh config must be defined")
+					}
+					secret, err := readSecret(v.SSH.SecretFile, v.SSH.SecretName)
+					if err != nil {
+						return fmt.Errorf("read ssh secret failed: %v", err)
+					}
+					hook, err = hooks.NewSSH(*v.SSH, secret)
+					if err != nil {
+						return fmt.Errorf("init ssh hook failed: %v", err)
+					}
 				case v1.WorkerKindDocker:
 					hook, err = hooks.NewDocker("", "")
 					if err != nil {
 						return fmt.Errorf("init docker hook failed: %v", err)
 					}
 				case v1.WorkerKindKubernetes:
+					var kubeCfg kubernetes.Config
+					if v.Kubernetes != nil {
+						kubeCfg = *v.Kubernetes
+					}
+					hook, err = hooks.NewKubernetes(kubeCfg)
+					if err != nil {
+						return fmt.Errorf("init kubernetes hook failed: %v", err)
+					}
 				default:
 					return fmt.Errorf("unsupported kind: %s", v.Worker.Kind)
 				}
 
-				wc, err := clients.NewWorker(v.Addr, v.Name, v.Worker)
+				wc, err := clients.NewWorker(v.Addr, v.Name, v.Token, v.Worker)
 				if err != nil {
 					return fmt.Errorf("create worker client failed: %v", err)
 				}
@@ -87,7 +114,7 @@ func NewWorker() *cobra.Command {
 				workers = append(workers, w)
 			}
 
-			eg, ctx := errgroup.WithContext(context.Background())
+			eg, ctx := errgroup.WithContext(cache.WithContext(context.Background(), caches))
 			eg.Go(func() error { return signals.Exit(ctx) })
 			for _, w := range workers {
 				wc := w
@@ -110,6 +137,7 @@ type WorkerOption struct {
 type WorkerConfig struct {
 	Logger  wslog.Config       `json:"logger,omitempty"`
 	Workers []WorkerItemConfig `json:"workers,omitempty"`
+	Cache   cache.Config       `json:"cache,omitempty"`
 }
 
 type WorkerItemConfig struct {
@@ -117,6 +145,11 @@ type WorkerItemConfig struct {
 	Addr   string     `json:"addr"`
 	Count  int        `json:"count"`
 	Worker *v1.Worker `json:"worker"`
+	// Token is one of the worker tokens of inkd to request the client api.
+	Token string `json:"token,omitempty"`
+
+	Kubernetes *kubernetes.Config `json:"kubernetes,omitempty"`
+	SSH        *ssh.Config        `json:"ssh,omitempty"`
 }
 
 func (c *WorkerConfig) Validate() error {
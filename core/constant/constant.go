@@ -39,6 +39,8 @@ var (
 	ErrAlreadyExists = errors.New("already exists")
 	ErrNoRecord      = errors.New("no record")
 	ErrInvalidName   = errors.New("invalid name")
+	// ErrNotWaitingApproval means the stage has been decided or does not require the approval.
+	ErrNotWaitingApproval = errors.New("not waiting for approval")
 )
 
 func NewHTTPError(code int, msg string) *HTTPError {
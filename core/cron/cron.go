@@ -0,0 +1,148 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	"github.com/zc2638/wslog"
+	"gorm.io/gorm"
+
+	"github.com/zc2638/ink/core/scheduler"
+	"github.com/zc2638/ink/core/service"
+	"github.com/zc2638/ink/core/service/build"
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+	storageV1 "github.com/zc2638/ink/pkg/api/storage/v1"
+	"github.com/zc2638/ink/pkg/database"
+)
+
+// DefaultInterval is the interval to check the schedules,
+// the cron expressions are accurate to the minute.
+const DefaultInterval = time.Second * 15
+
+// New creates the cron to fire the schedules of the boxes.
+func New(db *gorm.DB, sched scheduler.Interface) *Cron {
+	return &Cron{
+		db:       db,
+		sched:    sched,
+		buildSrv: build.New(),
+		interval: DefaultInterval,
+	}
+}
+
+type Cron struct {
+	db       *gorm.DB
+	sched    scheduler.Interface
+	buildSrv service.Build
+	interval time.Duration
+}
+
+// Start checks the schedules periodically until the context is done.
+func (c *Cron) Start(ctx context.Context) {
+	ctx = database.WithContext(ctx, c.db)
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.run(ctx, time.Now()); err != nil {
+			wslog.FromContext(ctx).Error("Run schedules failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Cron) run(ctx context.Context, now time.Time) error {
+	var boxes []storageV1.Box
+	if err := c.db.Where(&storageV1.Box{Enabled: true}).Find(&boxes).Error; err != nil {
+		return err
+	}
+	for _, v := range boxes {
+		log := wslog.FromContext(ctx).With("box", v.Namespace+"/"+v.Name)
+		box, err := v.ToAPI()
+		if err != nil {
+			log.Error("Convert box failed", "error", err)
+			continue
+		}
+
+		names := make([]string, 0, len(box.Schedules))
+		for _, schedule := range box.Schedules {
+			names = append(names, schedule.Name)
+			if err := c.fire(ctx, box, &schedule, now); err != nil {
+				log.Error("Fire schedule failed", "schedule", schedule.Name, "error", err)
+			}
+		}
+
+		// clear the states of the removed schedules
+		db := c.db.Where("box_id = ?", box.ID)
+		if len(names) > 0 {
+			db = db.Where("name NOT IN ?", names)
+		}
+		if err := db.Delete(&storageV1.Schedule{}).Error; err != nil {
+			log.Error("Clear schedules failed", "error", err)
+		}
+	}
+	return nil
+}
+
+// fire creates the build if the schedule is due.
+// The new schedules start from now, and the missed runs are fired only once.
+func (c *Cron) fire(ctx context.Context, box *v1.Box, schedule *v1.BoxSchedule, now time.Time) error {
+	state := &storageV1.Schedule{BoxID: box.ID, Name: schedule.Name}
+	result := c.db.Where(state).Limit(1).Find(state)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		state.LastFired = now.Unix()
+		return c.db.Create(state).Error
+	}
+
+	next, err := schedule.Next(time.Unix(state.LastFired, 0))
+	if err != nil {
+		return err
+	}
+	if next.After(now) {
+		return nil
+	}
+
+	// the last fire time is recorded before the build is created,
+	// and only one of the inkd instances succeeds in recording it.
+	result = c.db.Model(&storageV1.Schedule{}).
+		Where("id = ? AND last_fired = ?", state.ID, state.LastFired).
+		Update("last_fired", now.Unix())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	settings := map[string]string{"DYNASTY_SCHEDULE": schedule.Name}
+	maps.Copy(settings, schedule.Settings)
+	number, err := c.buildSrv.Create(ctx, box.Namespace, box.Name, settings, nil)
+	if err != nil {
+		return err
+	}
+	wslog.FromContext(ctx).Info("Scheduled build created",
+		"box", box.Namespace+"/"+box.Name, "schedule", schedule.Name, "number", number)
+	c.sched.Schedule(ctx)
+	return nil
+}
@@ -0,0 +1,167 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cron
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/zc2638/ink/core/scheduler"
+	"github.com/zc2638/ink/core/service"
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+	storageV1 "github.com/zc2638/ink/pkg/api/storage/v1"
+	"github.com/zc2638/ink/pkg/database"
+	"github.com/zc2638/ink/resource"
+)
+
+type fakeBuild struct {
+	service.Build
+	created int
+}
+
+func (s *fakeBuild) Create(context.Context, string, string, map[string]string, *v1.Commit) (uint64, error) {
+	s.created++
+	return uint64(s.created), nil
+}
+
+type fakeScheduler struct {
+	scheduler.Interface
+}
+
+func (fakeScheduler) Schedule(context.Context) {}
+
+func newTestDSN(t *testing.T) string {
+	dsn := filepath.Join(t.TempDir(), "ink.db")
+	if err := resource.MigrateDatabase("sqlite3", dsn); err != nil {
+		t.Fatal(err)
+	}
+	return dsn
+}
+
+func newTestCron(t *testing.T, dsn string) (*Cron, *fakeBuild) {
+	db, err := database.New(database.Config{Driver: "sqlite3", DSN: dsn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buildSrv := new(fakeBuild)
+	return &Cron{db: db, sched: fakeScheduler{}, buildSrv: buildSrv, interval: DefaultInterval}, buildSrv
+}
+
+func lastFired(t *testing.T, db *gorm.DB) int64 {
+	var state storageV1.Schedule
+	if err := db.Where(&storageV1.Schedule{BoxID: 1, Name: "hourly"}).First(&state).Error; err != nil {
+		t.Fatal(err)
+	}
+	return state.LastFired
+}
+
+func TestCronFire(t *testing.T) {
+	dsn := newTestDSN(t)
+	c, buildSrv := newTestCron(t, dsn)
+	ctx := context.Background()
+
+	box := &v1.Box{}
+	box.ID = 1
+	box.SetNamespace("test")
+	box.SetName("box")
+	schedule := &v1.BoxSchedule{Name: "hourly", Cron: "0 * * * *", Timezone: "UTC"}
+	start := time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		now           time.Time
+		wantCreated   int
+		wantLastFired time.Time
+	}{
+		{name: "first sight", now: start, wantCreated: 0, wantLastFired: start},
+		{name: "not due", now: start.Add(20 * time.Minute), wantCreated: 0, wantLastFired: start},
+		{name: "due", now: start.Add(35 * time.Minute), wantCreated: 1, wantLastFired: start.Add(35 * time.Minute)},
+		{name: "already fired", now: start.Add(40 * time.Minute), wantCreated: 1, wantLastFired: start.Add(35 * time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := c.fire(ctx, box, schedule, tt.now); err != nil {
+				t.Fatalf("fire() error = %v", err)
+			}
+			if buildSrv.created != tt.wantCreated {
+				t.Fatalf("fire() created %d builds, want %d", buildSrv.created, tt.wantCreated)
+			}
+			if got := lastFired(t, c.db); got != tt.wantLastFired.Unix() {
+				t.Fatalf("fire() last fired = %v, want %v", time.Unix(got, 0).UTC(), tt.wantLastFired)
+			}
+		})
+	}
+
+	t.Run("concurrent instances", func(t *testing.T) {
+		other, otherBuildSrv := newTestCron(t, dsn)
+		now := start.Add(95 * time.Minute)
+		// the first instance fires after the other one has read the state,
+		// but before it records the last fire time.
+		err := other.db.Callback().Update().Before("gorm:begin_transaction").Register("test:race", func(*gorm.DB) {
+			if err := c.fire(ctx, box, schedule, now); err != nil {
+				t.Errorf("fire() error = %v", err)
+			}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := other.fire(ctx, box, schedule, now.Add(time.Second)); err != nil {
+			t.Fatalf("fire() error = %v", err)
+		}
+		if buildSrv.created != 2 {
+			t.Fatalf("fire() created %d builds, want 2", buildSrv.created)
+		}
+		if otherBuildSrv.created != 0 {
+			t.Fatalf("fire() of the other instance created %d builds, want 0", otherBuildSrv.created)
+		}
+		if got := lastFired(t, c.db); got != now.Unix() {
+			t.Fatalf("fire() last fired = %v, want %v", time.Unix(got, 0).UTC(), now)
+		}
+	})
+}
+
+func TestCronRun(t *testing.T) {
+	c, _ := newTestCron(t, newTestDSN(t))
+
+	broken := &storageV1.Box{Namespace: "test", Name: "broken", Enabled: true, Data: "{"}
+	box := &v1.Box{Schedules: []v1.BoxSchedule{{Name: "hourly", Cron: "0 * * * *"}}}
+	box.SetNamespace("test")
+	box.SetName("box")
+	boxS := new(storageV1.Box)
+	if err := boxS.FromAPI(box); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []*storageV1.Box{broken, boxS} {
+		if err := c.db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	if err := c.run(context.Background(), now); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	var state storageV1.Schedule
+	if err := c.db.Where(&storageV1.Schedule{BoxID: boxS.ID, Name: "hourly"}).First(&state).Error; err != nil {
+		t.Fatalf("run() did not record the schedule after the broken box: %v", err)
+	}
+	if state.LastFired != now.Unix() {
+		t.Fatalf("run() last fired = %d, want %d", state.LastFired, now.Unix())
+	}
+}
@@ -24,16 +24,23 @@ import (
 	"time"
 
 	"github.com/99nil/gopkg/ctr"
-	"github.com/99nil/gopkg/sets"
+	"github.com/zc2638/wslog"
 	"gorm.io/gorm"
 
 	"github.com/zc2638/ink/core/constant"
 	"github.com/zc2638/ink/core/handler/wrapper"
+	"github.com/zc2638/ink/core/notify"
+	"github.com/zc2638/ink/core/report"
 	"github.com/zc2638/ink/core/scheduler"
+	"github.com/zc2638/ink/core/service/build"
 	v1 "github.com/zc2638/ink/pkg/api/core/v1"
 	storageV1 "github.com/zc2638/ink/pkg/api/storage/v1"
+	"github.com/zc2638/ink/pkg/artifact"
 	"github.com/zc2638/ink/pkg/database"
+	"github.com/zc2638/ink/pkg/keyring"
 	"github.com/zc2638/ink/pkg/livelog"
+	"github.com/zc2638/ink/pkg/logstore"
+	"github.com/zc2638/ink/pkg/pubsub"
 )
 
 // handleStatus returns a `http.HandlerFunc`
@@ -161,7 +168,7 @@ func handleInfo() http.HandlerFunc {
 			return
 		}
 
-		stageS := &storageV1.Workflow{Namespace: box.GetNamespace(), Name: status.Name}
+		stageS := &storageV1.Workflow{Namespace: box.GetNamespace(), Name: status.WorkflowName()}
 		if err := db.Where(stageS).First(stageS).Error; err != nil {
 			wrapper.InternalError(w, err)
 			return
@@ -192,7 +199,12 @@ func handleInfo() http.HandlerFunc {
 				return
 			}
 		}
+		kr := keyring.FromRequest(r)
 		for _, v := range secretList {
+			if err := v.Decrypt(kr); err != nil {
+				wrapper.InternalError(w, err)
+				return
+			}
 			secret, err := v.ToAPI()
 			if err != nil {
 				wrapper.InternalError(w, err)
@@ -247,23 +259,59 @@ func handleStageBegin() http.HandlerFunc {
 			return
 		}
 
+		// the output of the stage services is streamed while the stage runs,
+		// reset the stream in case the stage begins again.
+		if err := livelog.FromRequest(r).Reset(r.Context(), v1.ServiceLogID(stage.ID)); err != nil {
+			wrapper.InternalError(w, err)
+			return
+		}
+
+		var started bool
 		err = db.Transaction(func(tx *gorm.DB) error {
 			if err := tx.Model(stageS).Updates(stageS).Error; err != nil {
 				return err
 			}
-			if buildS.Phase != v1.PhasePending.String() {
-				return nil
-			}
-			buildS.Started = time.Now().Unix()
-			buildS.Phase = v1.PhaseRunning.String()
+			// the stages may begin concurrently, only the one which updates
+			// the pending build starts it.
+			now := time.Now().Unix()
 			buildWhere := new(storageV1.Build)
 			buildWhere.SetID(buildS.ID)
-			return tx.Model(buildWhere).Where(buildWhere).Updates(buildS).Error
+			result := tx.Model(buildWhere).Where(buildWhere).
+				Where("phase = ?", v1.PhasePending.String()).
+				Updates(map[string]any{
+					"phase":   v1.PhaseRunning.String(),
+					"started": now,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			started = result.RowsAffected == 1
+			if started {
+				buildS.Phase = v1.PhaseRunning.String()
+				buildS.Started = now
+			}
+			return nil
 		})
 		if err != nil {
 			wrapper.InternalError(w, err)
 			return
 		}
+		events := []*v1.PhaseEvent{{
+			Kind:  v1.PhaseEventStage,
+			Stage: stage.Number,
+			Name:  stage.Name,
+			Phase: stage.Phase,
+		}}
+		if started {
+			events = append([]*v1.PhaseEvent{{Kind: v1.PhaseEventBuild, Phase: v1.PhaseRunning}}, events...)
+			if nt := notify.FromRequest(r); nt != nil {
+				nt.Notify(r.Context(), buildS.ID)
+			}
+		}
+		publishEvents(r.Context(), db, buildS, events...)
+		if rp := report.FromRequest(r); rp != nil {
+			rp.Report(r.Context(), buildS.ID)
+		}
 		ctr.Success(w)
 	}
 }
@@ -286,6 +334,7 @@ func handleStageEnd() http.HandlerFunc {
 		ctx := r.Context()
 		db := database.FromContext(ctx)
 		ll := livelog.FromContext(ctx)
+		ls := logstore.FromContext(ctx)
 		sched := scheduler.FromContext(ctx)
 
 		buildS := new(storageV1.Build)
@@ -330,10 +379,37 @@ func handleStageEnd() http.HandlerFunc {
 			wrapper.InternalError(w, err)
 			return
 		}
+		publishEvents(ctx, db, buildS, &v1.PhaseEvent{
+			Kind:  v1.PhaseEventStage,
+			Stage: stage.Number,
+			Name:  stage.Name,
+			Phase: stage.Phase,
+			Error: stage.Error,
+		})
 
+		// archive the logs of steps that did not report their end,
+		// e.g. canceled or skipped before finishing.
 		for _, step := range stage.Steps {
-			// TODO need to log
-			_ = ll.Delete(ctx, strconv.FormatUint(step.ID, 10))
+			// the stage is already finished, the archive failure only affects the log.
+			if err := archiveLog(ctx, ll, ls, step.ID); err != nil {
+				wslog.FromContext(ctx).Error("Archive log failed", "step", step.ID, "error", err)
+			}
+		}
+		if err := ll.Delete(ctx, v1.ServiceLogID(stage.ID)); err != nil {
+			wslog.FromContext(ctx).Error("Delete service log failed", "stage", stage.ID, "error", err)
+		}
+
+		if stage.Phase.IsFailed() {
+			retried, err := retryStage(db, buildS, stage)
+			if err != nil {
+				wrapper.InternalError(w, err)
+				return
+			}
+			if retried {
+				sched.Schedule(ctx)
+				ctr.Success(w)
+				return
+			}
 		}
 
 		var stageList []storageV1.Stage
@@ -351,7 +427,7 @@ func handleStageEnd() http.HandlerFunc {
 			stages = append(stages, item)
 		}
 
-		if err := cancelDownstream(db, stages); err != nil {
+		if err := build.CancelDownstream(db, stages); err != nil {
 			wrapper.InternalError(w, err)
 			return
 		}
@@ -362,10 +438,7 @@ func handleStageEnd() http.HandlerFunc {
 
 		isBuildComplete := true
 		for _, sv := range stages {
-			if sv.Phase == v1.PhaseUnknown ||
-				sv.Phase == v1.PhaseWaiting ||
-				sv.Phase == v1.PhasePending ||
-				sv.Phase == v1.PhaseRunning {
+			if !sv.Phase.IsDone() {
 				isBuildComplete = false
 				break
 			}
@@ -374,21 +447,30 @@ func handleStageEnd() http.HandlerFunc {
 			buildS.Phase = v1.PhaseSucceeded.String()
 			buildS.Stopped = time.Now().Unix()
 			for _, sv := range stages {
-				if sv.Phase == v1.PhaseFailed || sv.Phase == v1.PhaseCanceled {
+				if sv.Phase.IsFailed() || sv.Phase == v1.PhaseCanceled {
 					buildS.Phase = sv.Phase.String()
 					break
 				}
 			}
 
-			if buildS.Started == 0 {
-				buildS.Started = buildS.Stopped
-			}
-			buildWhere := new(storageV1.Build)
-			buildWhere.SetID(buildS.ID)
-			if err := db.Model(buildWhere).Where(buildWhere).Updates(buildS).Error; err != nil {
+			ended, err := build.EndBuild(db, buildS)
+			if err != nil {
 				wrapper.InternalError(w, err)
 				return
 			}
+			// skip the events if the build is ended by others, e.g. by the rejection of a waiting stage.
+			if ended {
+				publishEvents(ctx, db, buildS, &v1.PhaseEvent{
+					Kind:  v1.PhaseEventBuild,
+					Phase: v1.Phase(buildS.Phase),
+				})
+				if nt := notify.FromContext(ctx); nt != nil {
+					nt.Notify(ctx, buildS.ID)
+				}
+			}
+		}
+		if rp := report.FromContext(ctx); rp != nil {
+			rp.Report(ctx, buildS.ID)
 		}
 
 		ctr.Success(w)
@@ -430,6 +512,7 @@ func handleStepBegin() http.HandlerFunc {
 			wrapper.InternalError(w, err)
 			return
 		}
+		publishStepEvent(ctx, db, step)
 		ctr.OK(w, step)
 	}
 }
@@ -451,6 +534,7 @@ func handleStepEnd() http.HandlerFunc {
 
 		ctx := r.Context()
 		ll := livelog.FromContext(ctx)
+		ls := logstore.FromContext(ctx)
 		db := database.FromContext(ctx)
 
 		if len(step.Error) > 500 {
@@ -465,40 +549,19 @@ func handleStepEnd() http.HandlerFunc {
 			return
 		}
 
-		lines, err := ll.List(ctx, strconv.FormatUint(step.ID, 10))
-		if err != nil {
-			wrapper.InternalError(w, err)
-			return
-		}
-		if len(lines) > 0 {
-			logBytes, err := json.Marshal(lines)
-			if err != nil {
-				wrapper.InternalError(w, err)
-				return
-			}
-
-			logS := new(storageV1.Log)
-			logS.SetID(step.ID)
-			logS.Data = logBytes
-			if err := db.Create(logS).Error; err != nil {
-				wrapper.InternalError(w, err)
-				return
-			}
-		}
-
-		if err := ll.Delete(ctx, strconv.FormatUint(step.ID, 10)); err != nil {
-			wrapper.InternalError(w, err)
-			return
+		if err := archiveLog(ctx, ll, ls, step.ID); err != nil {
+			wslog.FromContext(ctx).Error("Archive log failed", "step", step.ID, "error", err)
 		}
+		publishStepEvent(ctx, db, step)
 		ctr.OK(w, step)
 	}
 }
 
 // handleLogUpload returns a `http.HandlerFunc`
 // that accepts a `http.Request` to submit a stream of logs to the server.
-func handleLogUpload() http.HandlerFunc {
+func handleLogUpload(logID func(r *http.Request) string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		stepID := wrapper.URLParam(r, "step")
+		id := logID(r)
 		isAll, _ := strconv.ParseBool(r.URL.Query().Get("all"))
 
 		var lines []*livelog.Line
@@ -516,19 +579,19 @@ func handleLogUpload() http.HandlerFunc {
 
 		var opts []any
 		if isAll {
-			lineCount := ll.LineCount(ctx, stepID)
+			lineCount := ll.LineCount(ctx, id)
 			if lineCount == len(lines) {
 				ctr.Success(w)
 				return
 			}
-			if err := ll.Reset(ctx, stepID); err != nil {
+			if err := ll.Reset(ctx, id); err != nil {
 				ctr.InternalError(w, err)
 				return
 			}
 			opts = []any{livelog.PublishOption(false)}
 		}
 		for _, line := range lines {
-			if err := ll.Write(ctx, stepID, line, opts...); err != nil {
+			if err := ll.Write(ctx, id, line, opts...); err != nil {
 				ctr.InternalError(w, err)
 				return
 			}
@@ -537,6 +600,64 @@ func handleLogUpload() http.HandlerFunc {
 	}
 }
 
+// stepLogID returns the id of the live log stream of the step in the request.
+func stepLogID(r *http.Request) string {
+	return wrapper.URLParam(r, "step")
+}
+
+// serviceLogID returns the id of the live log stream of the stage services in the request.
+func serviceLogID(r *http.Request) string {
+	stageID, _ := strconv.ParseUint(wrapper.URLParam(r, "stage"), 10, 64)
+	return v1.ServiceLogID(stageID)
+}
+
+// archiveLog persists the live logs of the step to the log store,
+// and then releases the live stream.
+func archiveLog(ctx context.Context, ll livelog.Interface, ls logstore.Interface, stepID uint64) error {
+	id := strconv.FormatUint(stepID, 10)
+	lines, err := ll.List(ctx, id)
+	if err != nil {
+		return err
+	}
+	// nothing is left when the live stream has already been archived.
+	if len(lines) > 0 {
+		if err := ls.Save(ctx, stepID, lines); err != nil {
+			return err
+		}
+	}
+	return ll.Delete(ctx, id)
+}
+
+// handleArtifactUpload returns a `http.HandlerFunc`
+// that accepts a `http.Request` to submit an artifact file of the step to the server.
+func handleArtifactUpload() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stepID, _ := strconv.ParseUint(
+			wrapper.URLParam(r, "step"), 10, 64)
+		name, err := artifact.CleanName(r.URL.Query().Get("name"))
+		if err != nil {
+			wrapper.BadRequest(w, err)
+			return
+		}
+
+		ctx := r.Context()
+		db := database.FromContext(ctx)
+		as := artifact.FromContext(ctx)
+
+		stepS := new(storageV1.Step)
+		stepS.SetID(stepID)
+		if err := db.Where(stepS).First(stepS).Error; err != nil {
+			wrapper.InternalError(w, err)
+			return
+		}
+		if err := as.Save(ctx, stepS.ID, name, r.Body); err != nil {
+			wrapper.InternalError(w, err)
+			return
+		}
+		ctr.Success(w)
+	}
+}
+
 // handleWatch returns a `http.HandlerFunc`
 // that accepts a blocking `http.Request` that watches a build for cancellation.
 func handleWatchCancel(w http.ResponseWriter, r *http.Request) {
@@ -560,64 +681,70 @@ func handleWatchCancel(w http.ResponseWriter, r *http.Request) {
 	ctr.Success(w)
 }
 
-func cancelDownstream(db *gorm.DB, stages []*v1.Stage) error {
-	failed := false
-	for _, s := range stages {
-		if s.Phase.IsFailed() {
-			failed = true
-		}
+// retryStage re-queues the failed stage if it is allowed by the retry policy of the workflow.
+func retryStage(db *gorm.DB, buildS *storageV1.Build, stage *v1.Stage) (bool, error) {
+	boxS := new(storageV1.Box)
+	boxS.SetID(buildS.BoxID)
+	if err := db.Where(boxS).First(boxS).Error; err != nil {
+		return false, err
 	}
-
-	var errs []error
-	for _, s := range stages {
-		if s.Phase != v1.PhaseWaiting {
-			continue
-		}
-
-		var skip bool
-		if failed && !s.Phase.IsFailed() {
-			skip = true
-		}
-		if !failed && !s.Phase.IsSucceeded() {
-			skip = true
-		}
-		if !skip {
-			continue
-		}
-
-		if !areDepsComplete(s, stages) {
-			continue
+	workflowS := &storageV1.Workflow{Namespace: boxS.Namespace, Name: stage.WorkflowName()}
+	if err := db.Where(workflowS).First(workflowS).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
 		}
+		return false, err
+	}
+	workflow, err := workflowS.ToAPI()
+	if err != nil {
+		return false, err
+	}
 
-		s.Phase = v1.PhaseSkipped
-		s.Started = time.Now().Unix()
-		s.Stopped = time.Now().Unix()
-
-		stageS := new(storageV1.Stage)
-		if err := stageS.FromAPI(s); err != nil {
-			errs = append(errs, err)
-			continue
-		}
-		stageWhere := new(storageV1.Stage)
-		stageWhere.SetID(s.ID)
-		if err := db.Model(stageWhere).Where(stageWhere).Updates(stageS).Error; err != nil {
-			errs = append(errs, err)
+	retry := workflow.Spec.Retry
+	matched := retry.Match(stage.Attempt, -1)
+	if len(retry.ExitCodes) > 0 {
+		matched = false
+		for _, step := range stage.Steps {
+			if step.Phase == v1.PhaseFailed && retry.Match(stage.Attempt, step.ExitCode) {
+				matched = true
+				break
+			}
 		}
 	}
-	return errors.Join(errs...)
-}
+	if !matched {
+		return false, nil
+	}
 
-func areDepsComplete(stage *v1.Stage, stages []*v1.Stage) bool {
-	deps := sets.NewString(stage.DependsOn...)
-	for _, sv := range stages {
-		if !deps.Has(sv.Name) {
-			continue
-		}
-		if !sv.Phase.IsDone() {
-			return false
-		}
+	// the scheduler holds the stage until the backoff is over,
+	// instead of the worker waiting for it after accepting the stage.
+	var notBefore int64
+	if delay := retry.Delay(stage.Attempt + 1); delay > 0 {
+		notBefore = time.Now().Add(delay).Unix()
 	}
-	return true
+	err = db.Transaction(func(tx *gorm.DB) error {
+		stageWhere := new(storageV1.Stage)
+		stageWhere.SetID(stage.ID)
+		// the zero values are not updated by the struct.
+		if err := tx.Model(stageWhere).Where(stageWhere).Updates(map[string]any{
+			"phase":       v1.PhasePending.String(),
+			"attempt":     stage.Attempt + 1,
+			"not_before":  notBefore,
+			"worker_name": "",
+			"started":     0,
+			"stopped":     0,
+			"error":       "",
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&storageV1.Step{}).Where(&storageV1.Step{StageID: stage.ID}).Updates(map[string]any{
+			"phase":     v1.PhasePending.String(),
+			"started":   0,
+			"stopped":   0,
+			"exit_code": 0,
+			"error":     "",
+		}).Error
+	})
+	return err == nil, err
 }
 
 func scheduleDownstream(ctx context.Context, sched scheduler.Interface, db *gorm.DB, stages []*v1.Stage) error {
@@ -629,11 +756,11 @@ func scheduleDownstream(ctx context.Context, sched scheduler.Interface, db *gorm
 		if len(sv.DependsOn) == 0 {
 			continue
 		}
-		if !areDepsComplete(sv, stages) {
+		if !sv.IsDepsDone(stages) {
 			continue
 		}
 
-		sv.Phase = v1.PhasePending
+		sv.Phase = sv.ReadyPhase()
 
 		stageS := new(storageV1.Stage)
 		if err := stageS.FromAPI(sv); err != nil {
@@ -646,7 +773,59 @@ func scheduleDownstream(ctx context.Context, sched scheduler.Interface, db *gorm
 			errs = append(errs, err)
 			continue
 		}
-		sched.Schedule(ctx)
+		if sv.Phase == v1.PhasePending {
+			sched.Schedule(ctx)
+		}
 	}
 	return errors.Join(errs...)
 }
+
+// publishEvents sends the phase transitions of the build to the subscribers,
+// the events are completed with the box and the number of the build.
+func publishEvents(ctx context.Context, db *gorm.DB, buildS *storageV1.Build, events ...*v1.PhaseEvent) {
+	ps := pubsub.FromContext(ctx)
+	if ps == nil {
+		return
+	}
+	boxS := new(storageV1.Box)
+	boxS.SetID(buildS.BoxID)
+	if err := db.Where(boxS).First(boxS).Error; err != nil {
+		wslog.FromContext(ctx).Error("Publish events failed", "build", buildS.ID, "error", err)
+		return
+	}
+	now := time.Now()
+	for _, event := range events {
+		event.Namespace = boxS.Namespace
+		event.Box = boxS.Name
+		event.Build = buildS.Number
+		event.Time = now
+		ps.Publish(ctx, event)
+	}
+}
+
+// publishStepEvent sends the phase transition of the step to the subscribers.
+func publishStepEvent(ctx context.Context, db *gorm.DB, step *v1.Step) {
+	if pubsub.FromContext(ctx) == nil {
+		return
+	}
+	stageS := new(storageV1.Stage)
+	stageS.SetID(step.StageID)
+	if err := db.Where(stageS).First(stageS).Error; err != nil {
+		wslog.FromContext(ctx).Error("Publish events failed", "step", step.ID, "error", err)
+		return
+	}
+	buildS := new(storageV1.Build)
+	buildS.SetID(stageS.BuildID)
+	if err := db.Where(buildS).First(buildS).Error; err != nil {
+		wslog.FromContext(ctx).Error("Publish events failed", "step", step.ID, "error", err)
+		return
+	}
+	publishEvents(ctx, db, buildS, &v1.PhaseEvent{
+		Kind:  v1.PhaseEventStep,
+		Stage: stageS.Number,
+		Step:  step.Number,
+		Name:  step.Name,
+		Phase: step.Phase,
+		Error: step.Error,
+	})
+}
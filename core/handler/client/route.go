@@ -32,9 +32,11 @@ func Handler(middlewares chi.Middlewares) http.Handler {
 	r.Get("/stage/{stage}", handleInfo())
 	r.Post("/stage/{stage}/begin", handleStageBegin())
 	r.Post("/stage/{stage}/end", handleStageEnd())
+	r.Post("/stage/{stage}/logs/upload", handleLogUpload(serviceLogID))
 	r.Post("/step/{step}/begin", handleStepBegin())
 	r.Post("/step/{step}/end", handleStepEnd())
-	r.Post("/step/{step}/logs/upload", handleLogUpload())
+	r.Post("/step/{step}/logs/upload", handleLogUpload(stepLogID))
+	r.Post("/step/{step}/artifacts", handleArtifactUpload())
 	r.Post("/build/{build}/watch", handleWatchCancel)
 	return r
 }
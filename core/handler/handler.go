@@ -19,6 +19,7 @@ import (
 	"log/slog"
 	"net/http"
 	"regexp"
+	"slices"
 	"time"
 
 	"github.com/99nil/gopkg/ctr"
@@ -29,47 +30,89 @@ import (
 	"github.com/zc2638/wslog"
 	"gorm.io/gorm"
 
+	"github.com/zc2638/ink/core/auth"
 	"github.com/zc2638/ink/core/constant"
 	"github.com/zc2638/ink/core/handler/client"
+	"github.com/zc2638/ink/core/handler/hook"
 	"github.com/zc2638/ink/core/handler/server"
+	"github.com/zc2638/ink/core/notify"
+	"github.com/zc2638/ink/core/report"
 	"github.com/zc2638/ink/core/scheduler"
+	"github.com/zc2638/ink/pkg/artifact"
 	"github.com/zc2638/ink/pkg/database"
+	"github.com/zc2638/ink/pkg/keyring"
 	"github.com/zc2638/ink/pkg/livelog"
+	"github.com/zc2638/ink/pkg/logstore"
+	"github.com/zc2638/ink/pkg/pubsub"
 )
 
-var corsOpts = cors.Options{
-	AllowedOrigins: []string{"*"},
-	AllowedMethods: []string{
-		http.MethodGet,
-		http.MethodPost,
-		http.MethodPatch,
-		http.MethodPut,
-		http.MethodDelete,
-		http.MethodOptions,
-	},
-	AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-	ExposedHeaders:   []string{"Link"},
-	AllowCredentials: true,
-	MaxAge:           300,
+// corsOptions returns the cors options of the allowed origins,
+// the cors must not be enabled without the origins, which means all origins are allowed.
+func corsOptions(allowedOrigins []string) cors.Options {
+	return cors.Options{
+		AllowedOrigins: allowedOrigins,
+		AllowedMethods: []string{
+			http.MethodGet,
+			http.MethodPost,
+			http.MethodPatch,
+			http.MethodPut,
+			http.MethodDelete,
+			http.MethodOptions,
+		},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		ExposedHeaders:   []string{"Link"},
+		AllowCredentials: true,
+		MaxAge:           300,
+	}
 }
 
-func New(log *wslog.Logger, db *gorm.DB, ll livelog.Interface, sched scheduler.Interface) http.Handler {
+func New(
+	log *wslog.Logger,
+	db *gorm.DB,
+	ll livelog.Interface,
+	ls logstore.Interface,
+	as artifact.Interface,
+	kr *keyring.Keyring,
+	authn *auth.Authenticator,
+	sched scheduler.Interface,
+	nt notify.Interface,
+	rp report.Interface,
+	ps pubsub.Interface,
+) http.Handler {
 	apiMiddlewares := chi.Middlewares{
 		middleware.Logger,
 		middleware.Recoverer,
-		cors.New(corsOpts).Handler,
-		serviceMiddleware(log, ll, sched, db),
-		timeoutMiddleware,
 	}
+	if origins := authn.AllowedOrigins(); len(origins) > 0 {
+		apiMiddlewares = append(apiMiddlewares, cors.New(corsOptions(origins)).Handler)
+	}
+	apiMiddlewares = append(apiMiddlewares,
+		serviceMiddleware(log, ll, ls, as, kr, sched, nt, rp, ps, db),
+		timeoutMiddleware,
+	)
+	serverMiddlewares := append(slices.Clone(apiMiddlewares), authn.Authenticate)
+	clientMiddlewares := append(slices.Clone(apiMiddlewares), authn.AuthenticateWorker)
 
 	mux := chi.NewMux()
 	mux.Get("/", func(w http.ResponseWriter, r *http.Request) { ctr.OK(w, "Hello Ink") })
-	mux.Mount("/api/core/v1", server.Handler(apiMiddlewares))
-	mux.Mount("/api/client/v1", client.Handler(apiMiddlewares))
+	mux.Mount("/api/core/v1", server.Handler(serverMiddlewares))
+	mux.Mount("/api/client/v1", client.Handler(clientMiddlewares))
+	mux.Mount("/api/hooks", hook.Handler(apiMiddlewares))
 	return mux
 }
 
-func serviceMiddleware(log *wslog.Logger, ll livelog.Interface, sched scheduler.Interface, db *gorm.DB) func(next http.Handler) http.Handler {
+func serviceMiddleware(
+	log *wslog.Logger,
+	ll livelog.Interface,
+	ls logstore.Interface,
+	as artifact.Interface,
+	kr *keyring.Keyring,
+	sched scheduler.Interface,
+	nt notify.Interface,
+	rp report.Interface,
+	ps pubsub.Interface,
+	db *gorm.DB,
+) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			id := r.Header.Get("X-Request-ID")
@@ -81,7 +124,13 @@ func serviceMiddleware(log *wslog.Logger, ll livelog.Interface, sched scheduler.
 			ctx := r.Context()
 			ctx = wslog.WithContext(ctx, log)
 			ctx = livelog.WithContext(ctx, ll)
+			ctx = logstore.WithContext(ctx, ls)
+			ctx = artifact.WithContext(ctx, as)
+			ctx = keyring.WithContext(ctx, kr)
 			ctx = scheduler.WithContext(ctx, sched)
+			ctx = notify.WithContext(ctx, nt)
+			ctx = report.WithContext(ctx, rp)
+			ctx = pubsub.WithContext(ctx, ps)
 			ctx = database.WithContext(ctx, db)
 
 			if !log.Enabled(slog.LevelDebug) {
@@ -105,11 +154,19 @@ func serviceMiddleware(log *wslog.Logger, ll livelog.Interface, sched scheduler.
 	}
 }
 
-var logWatchRe = regexp.MustCompile(`/api/core/.+/box/.+/.+/build/.+/logs/.+/.+`)
+var (
+	logWatchRe = regexp.MustCompile(`/api/core/.+/box/.+/.+/build/.+/logs/.+/.+`)
+	// the transfer time of the artifacts depends on the file size.
+	artifactRe = regexp.MustCompile(`(/api/core/.+/box/.+/.+/build/.+/artifacts/.+/.+)|(/api/client/.+/step/.+/artifacts)`)
+	// the event streams are kept open until the client disconnects.
+	eventsRe = regexp.MustCompile(`(/api/core/.+/box/.+/.+/build/.+/events/?$)|(^/api/core/[^/]+/events(/[^/]+)?/?$)`)
+)
 
 func timeoutMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if logWatchRe.MatchString(r.URL.Path) {
+		if logWatchRe.MatchString(r.URL.Path) ||
+			artifactRe.MatchString(r.URL.Path) ||
+			eventsRe.MatchString(r.URL.Path) {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -0,0 +1,65 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zc2638/ink/core/constant"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	timeout := constant.DefaultHTTPTimeout
+	constant.DefaultHTTPTimeout = 20 * time.Millisecond
+	defer func() { constant.DefaultHTTPTimeout = timeout }()
+
+	// the handler holds the stream open past the timeout unless the request is canceled.
+	h := timeoutMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			w.WriteHeader(http.StatusGatewayTimeout)
+		case <-time.After(100 * time.Millisecond):
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{path: "/api/core/v1/box/default/test/build/1/events", want: http.StatusOK},
+		{path: "/api/core/v1/events", want: http.StatusOK},
+		{path: "/api/core/v1/events/", want: http.StatusOK},
+		{path: "/api/core/v1/events/default", want: http.StatusOK},
+		{path: "/api/core/v1/box/default/test/build/1/logs/1/1", want: http.StatusOK},
+		{path: "/api/core/v1/box/default/test/build/1/logs/1/services", want: http.StatusOK},
+		{path: "/api/core/v1/box/default/test/build/1/artifacts/1/1", want: http.StatusOK},
+		{path: "/api/core/v1/box/default/test/build/1", want: http.StatusGatewayTimeout},
+		{path: "/api/core/v1/box/default/events", want: http.StatusGatewayTimeout},
+		{path: "/api/core/v1/events/default/test", want: http.StatusGatewayTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != tt.want {
+				t.Fatalf("timeoutMiddleware() status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hook
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/99nil/gopkg/ctr"
+	"gorm.io/gorm"
+
+	"github.com/zc2638/ink/core/handler/wrapper"
+	"github.com/zc2638/ink/core/scheduler"
+	"github.com/zc2638/ink/core/service"
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+	"github.com/zc2638/ink/pkg/webhook"
+)
+
+func handleHook(boxSrv service.Box, buildSrv service.Build, secretSrv service.Secret) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		provider := wrapper.URLParam(r, "provider")
+		namespace := wrapper.URLParam(r, "namespace")
+		name := wrapper.URLParam(r, "name")
+		ctx := r.Context()
+
+		box, err := boxSrv.Info(ctx, namespace, name)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				wrapper.ErrorCode(w, http.StatusNotFound, err)
+				return
+			}
+			wrapper.InternalError(w, err)
+			return
+		}
+		if box.Webhook == nil {
+			wrapper.ErrorCode(w, http.StatusNotFound, "webhook is not enabled")
+			return
+		}
+
+		sec, err := secretSrv.Info(ctx, namespace, box.Webhook.Secret)
+		if err != nil {
+			wrapper.InternalError(w, fmt.Errorf("get webhook secret failed: %v", err))
+			return
+		}
+		if err := sec.Decrypt(); err != nil {
+			wrapper.InternalError(w, err)
+			return
+		}
+
+		commit, err := webhook.Parse(provider, r, sec.Data[v1.WebhookSecretKey])
+		if err != nil {
+			switch {
+			case errors.Is(err, webhook.ErrIgnored):
+				ctr.OK(w, err.Error())
+			case errors.Is(err, webhook.ErrUnsupportedProvider):
+				wrapper.ErrorCode(w, http.StatusNotFound, err)
+			case errors.Is(err, webhook.ErrInvalidSignature):
+				wrapper.ErrorCode(w, http.StatusUnauthorized, err)
+			default:
+				wrapper.BadRequest(w, err)
+			}
+			return
+		}
+
+		number, err := buildSrv.Create(ctx, namespace, name, nil, commit)
+		if err != nil {
+			wrapper.InternalError(w, err)
+			return
+		}
+
+		sched := scheduler.FromRequest(r)
+		sched.Schedule(ctx)
+		ctr.OK(w, number)
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hook
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"github.com/zc2638/ink/core/service/box"
+	"github.com/zc2638/ink/core/service/build"
+	"github.com/zc2638/ink/core/service/secret"
+)
+
+// Handler serves the webhooks of the git providers,
+// which are verified by the signatures instead of the tokens.
+func Handler(middlewares chi.Middlewares) http.Handler {
+	r := chi.NewRouter()
+	r.Use(middlewares...)
+
+	r.Post("/{provider}/{namespace}/{name}", handleHook(box.New(), build.New(), secret.New()))
+	return r
+}
@@ -0,0 +1,129 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/99nil/gopkg/ctr"
+
+	"github.com/zc2638/ink/core/handler/wrapper"
+	"github.com/zc2638/ink/core/service"
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+	"github.com/zc2638/ink/pkg/artifact"
+)
+
+func artifactList(buildSrv service.Build) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		namespace := wrapper.URLParam(r, "namespace")
+		name := wrapper.URLParam(r, "name")
+		number, _ := strconv.ParseUint(
+			wrapper.URLParam(r, "number"), 10, 64)
+		if number == 0 {
+			wrapper.BadRequest(w, errors.New("invalid build number"))
+			return
+		}
+
+		ctx := r.Context()
+		build, err := buildSrv.Info(ctx, namespace, name, number)
+		if err != nil {
+			wrapper.InternalError(w, err)
+			return
+		}
+
+		as := artifact.FromContext(ctx)
+		result := make([]*v1.Artifact, 0)
+		for _, stage := range build.Stages {
+			for _, step := range stage.Steps {
+				list, err := as.List(ctx, step.ID)
+				if err != nil {
+					wrapper.InternalError(w, err)
+					return
+				}
+				for _, v := range list {
+					result = append(result, &v1.Artifact{
+						Stage: stage.Number,
+						Step:  step.Number,
+						Name:  v.Name,
+						Size:  v.Size,
+					})
+				}
+			}
+		}
+		ctr.OK(w, result)
+	}
+}
+
+func artifactDownload(buildSrv service.Build) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		namespace := wrapper.URLParam(r, "namespace")
+		name := wrapper.URLParam(r, "name")
+		number, _ := strconv.ParseUint(
+			wrapper.URLParam(r, "number"), 10, 64)
+		stageNumber, _ := strconv.ParseUint(
+			wrapper.URLParam(r, "stage"), 10, 64)
+		stepNumber, _ := strconv.ParseUint(
+			wrapper.URLParam(r, "step"), 10, 64)
+		artifactName, err := artifact.CleanName(r.URL.Query().Get("name"))
+		if err != nil {
+			wrapper.BadRequest(w, err)
+			return
+		}
+
+		ctx := r.Context()
+		build, err := buildSrv.Info(ctx, namespace, name, number)
+		if err != nil {
+			wrapper.InternalError(w, err)
+			return
+		}
+
+		var stepID uint64
+		for _, stage := range build.Stages {
+			if stage.Number != stageNumber {
+				continue
+			}
+			for _, step := range stage.Steps {
+				if step.Number == stepNumber {
+					stepID = step.ID
+					break
+				}
+			}
+		}
+		if stepID == 0 {
+			wrapper.BadRequest(w, errors.New("step not found"))
+			return
+		}
+
+		as := artifact.FromContext(ctx)
+		rc, err := as.Open(ctx, stepID, artifactName)
+		if errors.Is(err, artifact.ErrNotFound) {
+			wrapper.ErrorCode(w, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			wrapper.InternalError(w, err)
+			return
+		}
+		defer rc.Close()
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(path.Base(artifactName)))
+		_, _ = io.Copy(w, rc)
+	}
+}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/99nil/gopkg/ctr"
 
+	"github.com/zc2638/ink/core/auth"
 	"github.com/zc2638/ink/core/handler/wrapper"
 	"github.com/zc2638/ink/core/service"
 	v1 "github.com/zc2638/ink/pkg/api/core/v1"
@@ -66,6 +67,10 @@ func boxCreate(boxSrv service.Box) http.HandlerFunc {
 			wrapper.BadRequest(w, "box requires at least one resource")
 			return
 		}
+		if err := auth.Authorize(r, in.GetNamespace(), v1.RoleEditor); err != nil {
+			wrapper.ErrorCode(w, http.StatusForbidden, err)
+			return
+		}
 
 		if err := boxSrv.Create(r.Context(), &in); err != nil {
 			wrapper.InternalError(w, err)
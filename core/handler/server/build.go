@@ -22,6 +22,8 @@ import (
 
 	"github.com/99nil/gopkg/ctr"
 
+	"github.com/zc2638/ink/core/auth"
+	"github.com/zc2638/ink/core/constant"
 	"github.com/zc2638/ink/core/handler/wrapper"
 	"github.com/zc2638/ink/core/scheduler"
 	"github.com/zc2638/ink/core/service"
@@ -75,8 +77,13 @@ func buildCreate(buildSrv service.Build) http.HandlerFunc {
 			settings[k] = query.Get(k)
 		}
 
-		number, err := buildSrv.Create(r.Context(), namespace, name, settings)
+		number, err := buildSrv.Create(r.Context(), namespace, name, settings, nil)
 		if err != nil {
+			var parameterErr *v1.ParameterError
+			if errors.As(err, &parameterErr) {
+				wrapper.BadRequest(w, err)
+				return
+			}
 			wrapper.InternalError(w, err)
 			return
 		}
@@ -87,6 +94,35 @@ func buildCreate(buildSrv service.Build) http.HandlerFunc {
 	}
 }
 
+func buildRerun(buildSrv service.Build) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		namespace := wrapper.URLParam(r, "namespace")
+		name := wrapper.URLParam(r, "name")
+		number, _ := strconv.ParseUint(
+			wrapper.URLParam(r, "number"), 10, 64)
+		if number == 0 {
+			wrapper.BadRequest(w, errors.New("invalid build number"))
+			return
+		}
+		failedOnly, _ := strconv.ParseBool(r.URL.Query().Get("failedOnly"))
+
+		result, err := buildSrv.Rerun(r.Context(), namespace, name, number, failedOnly)
+		if err != nil {
+			var parameterErr *v1.ParameterError
+			if errors.As(err, &parameterErr) {
+				wrapper.BadRequest(w, err)
+				return
+			}
+			wrapper.InternalError(w, err)
+			return
+		}
+
+		sched := scheduler.FromRequest(r)
+		sched.Schedule(r.Context())
+		ctr.OK(w, result)
+	}
+}
+
 func buildCancel(buildSrv service.Build) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		namespace := wrapper.URLParam(r, "namespace")
@@ -105,3 +141,84 @@ func buildCancel(buildSrv service.Build) http.HandlerFunc {
 		ctr.Success(w)
 	}
 }
+
+func buildApprove(buildSrv service.Build, approved bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		namespace := wrapper.URLParam(r, "namespace")
+		name := wrapper.URLParam(r, "name")
+		number, _ := strconv.ParseUint(
+			wrapper.URLParam(r, "number"), 10, 64)
+		if number == 0 {
+			wrapper.BadRequest(w, errors.New("invalid build number"))
+			return
+		}
+		stage, _ := strconv.ParseUint(
+			wrapper.URLParam(r, "stage"), 10, 64)
+		if stage == 0 {
+			wrapper.BadRequest(w, errors.New("invalid stage number"))
+			return
+		}
+
+		approval := new(v1.StageApproval)
+		_ = json.NewDecoder(r.Body).Decode(approval)
+		// the approver is the token if the authentication is enabled.
+		if token := auth.FromRequest(r); token != nil {
+			approval.Approver = token.Name
+		}
+		if len(approval.Approver) == 0 {
+			wrapper.BadRequest(w, errors.New("approver must be defined"))
+			return
+		}
+
+		ctx := r.Context()
+		if !approved {
+			if err := buildSrv.Reject(ctx, namespace, name, number, stage, approval); err != nil {
+				approveError(w, err)
+				return
+			}
+			ctr.Success(w)
+			return
+		}
+
+		if err := buildSrv.Approve(ctx, namespace, name, number, stage, approval); err != nil {
+			approveError(w, err)
+			return
+		}
+		sched := scheduler.FromRequest(r)
+		sched.Schedule(ctx)
+		ctr.Success(w)
+	}
+}
+
+// approveError writes the status of the decision error,
+// the stage may be missing or already decided.
+func approveError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, constant.ErrNoRecord):
+		wrapper.ErrorCode(w, http.StatusNotFound, err)
+	case errors.Is(err, constant.ErrNotWaitingApproval):
+		wrapper.ErrorCode(w, http.StatusConflict, err)
+	default:
+		wrapper.InternalError(w, err)
+	}
+}
+
+func buildNotifications(buildSrv service.Build) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		namespace := wrapper.URLParam(r, "namespace")
+		name := wrapper.URLParam(r, "name")
+		number, _ := strconv.ParseUint(
+			wrapper.URLParam(r, "number"), 10, 64)
+		if number == 0 {
+			wrapper.BadRequest(w, errors.New("invalid build number"))
+			return
+		}
+
+		result, err := buildSrv.Notifications(r.Context(), namespace, name, number)
+		if err != nil {
+			wrapper.InternalError(w, err)
+			return
+		}
+		ctr.OK(w, result)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zc2638/ink/core/constant"
+)
+
+func TestApproveError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "missing stage", err: fmt.Errorf("stage 2: %w", constant.ErrNoRecord), want: http.StatusNotFound},
+		{name: "decided", err: fmt.Errorf("stage 2 is %w", constant.ErrNotWaitingApproval), want: http.StatusConflict},
+		{name: "other", err: errors.New("database is closed"), want: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			approveError(w, tt.err)
+			if w.Code != tt.want {
+				t.Fatalf("approveError() status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
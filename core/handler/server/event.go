@@ -0,0 +1,136 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/99nil/gopkg/sse"
+
+	"github.com/zc2638/ink/core/handler/wrapper"
+	"github.com/zc2638/ink/core/service"
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+	"github.com/zc2638/ink/pkg/pubsub"
+)
+
+func buildEvents(buildSrv service.Build) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		namespace := wrapper.URLParam(r, "namespace")
+		name := wrapper.URLParam(r, "name")
+		number, _ := strconv.ParseUint(
+			wrapper.URLParam(r, "number"), 10, 64)
+		if number == 0 {
+			wrapper.BadRequest(w, errors.New("invalid build number"))
+			return
+		}
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		// subscribe before the build is got, so that no transition is missed.
+		events := pubsub.FromRequest(r).Subscribe(ctx)
+		build, err := buildSrv.Info(ctx, namespace, name, number)
+		if err != nil {
+			wrapper.InternalError(w, err)
+			return
+		}
+
+		var initial []*v1.PhaseEvent
+		if build.Phase.IsDone() {
+			initial = append(initial, &v1.PhaseEvent{
+				Kind:      v1.PhaseEventBuild,
+				Namespace: namespace,
+				Box:       name,
+				Build:     number,
+				Phase:     build.Phase,
+				Time:      time.Unix(build.Stopped, 0),
+			})
+		}
+		// the stream of the build ends when the build is done.
+		serveEvents(ctx, w, events, initial, func(event *v1.PhaseEvent) (bool, bool) {
+			if event.Namespace != namespace || event.Box != name || event.Build != number {
+				return false, false
+			}
+			return true, event.Kind == v1.PhaseEventBuild && event.Phase.IsDone()
+		})
+	}
+}
+
+func namespaceEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		namespace := wrapper.URLParam(r, "namespace")
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		events := pubsub.FromRequest(r).Subscribe(ctx)
+		serveEvents(ctx, w, events, nil, func(event *v1.PhaseEvent) (bool, bool) {
+			return namespace == v1.AllNamespace || event.Namespace == namespace, false
+		})
+	}
+}
+
+// serveEvents sends the initial events and the subscribed events matched by the filter,
+// until the client goes away or the filter reports the stream is done.
+// The stream ends after the initial events if there are any.
+func serveEvents(
+	ctx context.Context,
+	w http.ResponseWriter,
+	events <-chan *v1.PhaseEvent,
+	initial []*v1.PhaseEvent,
+	filter func(event *v1.PhaseEvent) (matched bool, done bool),
+) {
+	sender, err := sse.NewSender(w)
+	if err != nil {
+		wrapper.InternalError(w, err)
+		return
+	}
+
+	eventCh := make(chan *v1.PhaseEvent)
+	go func() {
+		defer close(eventCh)
+		send := func(event *v1.PhaseEvent) bool {
+			select {
+			case eventCh <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for _, event := range initial {
+			if !send(event) {
+				return
+			}
+		}
+		if len(initial) > 0 {
+			return
+		}
+		for event := range events {
+			matched, done := filter(event)
+			if !matched {
+				continue
+			}
+			if !send(event) || done {
+				return
+			}
+		}
+	}()
+	_ = sse.SendLoop[*v1.PhaseEvent](ctx, sender, eventCh, nil, 0, 0)
+}
@@ -16,18 +16,18 @@ package server
 
 import (
 	"context"
-	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/99nil/gopkg/ctr"
 	"github.com/99nil/gopkg/sse"
-	"gorm.io/gorm"
 
 	"github.com/zc2638/ink/core/handler/wrapper"
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
 	storageV1 "github.com/zc2638/ink/pkg/api/storage/v1"
 	"github.com/zc2638/ink/pkg/database"
 	"github.com/zc2638/ink/pkg/livelog"
+	"github.com/zc2638/ink/pkg/logstore"
 )
 
 func logInfo() http.HandlerFunc {
@@ -62,17 +62,13 @@ func logInfo() http.HandlerFunc {
 			wrapper.InternalError(w, err)
 			return
 		}
-		logS := new(storageV1.Log)
-		logS.SetID(stepS.ID)
-		if err := db.Where(logS).First(logS).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		ls := logstore.FromRequest(r)
+		lines, err := ls.Find(r.Context(), stepS.ID)
+		if err != nil {
 			wrapper.InternalError(w, err)
 			return
 		}
-		if logS.Data == nil {
-			ctr.OK(w, []struct{}{})
-			return
-		}
-		ctr.Bytes(w, logS.Data)
+		ctr.OK(w, lines)
 	}
 }
 
@@ -109,34 +105,70 @@ func logWatch() http.HandlerFunc {
 			return
 		}
 
-		ctx, cancel := context.WithCancel(r.Context())
-		defer cancel()
+		watchLog(w, r, strconv.FormatUint(stepS.ID, 10))
+	}
+}
 
-		ll := livelog.FromRequest(r)
-		lineCh, closeCh, err := ll.Watch(ctx, strconv.FormatUint(stepS.ID, 10))
-		if err != nil {
+// serviceLogWatch returns a `http.HandlerFunc` that streams the output of the stage services,
+// the output is only available while the stage is running.
+func serviceLogWatch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		namespace := wrapper.URLParam(r, "namespace")
+		name := wrapper.URLParam(r, "name")
+		number, _ := strconv.ParseUint(
+			wrapper.URLParam(r, "number"), 10, 64)
+		stageNumber, _ := strconv.ParseUint(
+			wrapper.URLParam(r, "stage"), 10, 64)
+		db := database.FromRequest(r)
+
+		boxS := &storageV1.Box{Namespace: namespace, Name: name}
+		if err := db.Where(boxS).First(boxS).Error; err != nil {
 			wrapper.InternalError(w, err)
 			return
 		}
-		if closeCh == nil {
-			// TODO step pending 时 livelog 未创建，导致 close nil 的处理
+		buildS := &storageV1.Build{BoxID: boxS.ID, Number: number}
+		if err := db.Where(buildS).First(buildS).Error; err != nil {
+			wrapper.InternalError(w, err)
 			return
 		}
-
-		sender, err := sse.NewSender(w)
-		if err != nil {
+		stageS := &storageV1.Stage{BuildID: buildS.ID, Number: stageNumber}
+		if err := db.Where(stageS).First(stageS).Error; err != nil {
 			wrapper.InternalError(w, err)
 			return
 		}
+		watchLog(w, r, v1.ServiceLogID(stageS.ID))
+	}
+}
+
+// watchLog sends the lines of the live log stream until the stream is closed.
+func watchLog(w http.ResponseWriter, r *http.Request, id string) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	ll := livelog.FromRequest(r)
+	lineCh, closeCh, err := ll.Watch(ctx, id)
+	if err != nil {
+		wrapper.InternalError(w, err)
+		return
+	}
+	if closeCh == nil {
+		// TODO step pending 时 livelog 未创建，导致 close nil 的处理
+		return
+	}
 
-		go func() {
-			select {
-			case <-ctx.Done():
-			case <-sender.WaitForClose():
-			case <-closeCh:
-				sender.Close()
-			}
-		}()
-		_ = sse.SendLoop[*livelog.Line](ctx, sender, lineCh, nil, 0, 0)
+	sender, err := sse.NewSender(w)
+	if err != nil {
+		wrapper.InternalError(w, err)
+		return
 	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-sender.WaitForClose():
+		case <-closeCh:
+			sender.Close()
+		}
+	}()
+	_ = sse.SendLoop[*livelog.Line](ctx, sender, lineCh, nil, 0, 0)
 }
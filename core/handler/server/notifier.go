@@ -0,0 +1,119 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/99nil/gopkg/ctr"
+
+	"github.com/zc2638/ink/core/auth"
+	"github.com/zc2638/ink/core/handler/wrapper"
+	"github.com/zc2638/ink/core/service"
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+)
+
+func notifierList(notifierSrv service.Notifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		namespace := wrapper.URLParam(r, "namespace")
+		page := v1.GetPagination(r)
+		result, err := notifierSrv.List(r.Context(), namespace, v1.ListOption{
+			Pagination:    *page,
+			LabelSelector: r.URL.Query().Get("labelSelector"),
+		})
+		if err != nil {
+			wrapper.InternalError(w, err)
+			return
+		}
+		ctr.OK(w, page.List(result))
+	}
+}
+
+func notifierInfo(notifierSrv service.Notifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		namespace := wrapper.URLParam(r, "namespace")
+		name := wrapper.URLParam(r, "name")
+
+		result, err := notifierSrv.Info(r.Context(), namespace, name)
+		if err != nil {
+			wrapper.InternalError(w, err)
+			return
+		}
+		ctr.OK(w, result)
+	}
+}
+
+func notifierCreate(notifierSrv service.Notifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var in v1.Notifier
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			wrapper.BadRequest(w, err)
+			return
+		}
+		if err := in.Validate(); err != nil {
+			wrapper.BadRequest(w, err)
+			return
+		}
+		if err := auth.Authorize(r, in.GetNamespace(), v1.RoleEditor); err != nil {
+			wrapper.ErrorCode(w, http.StatusForbidden, err)
+			return
+		}
+
+		if err := notifierSrv.Create(r.Context(), &in); err != nil {
+			wrapper.InternalError(w, err)
+			return
+		}
+		ctr.Success(w)
+	}
+}
+
+func notifierUpdate(notifierSrv service.Notifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		namespace := wrapper.URLParam(r, "namespace")
+		name := wrapper.URLParam(r, "name")
+
+		var in v1.Notifier
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			wrapper.BadRequest(w, err)
+			return
+		}
+		if err := in.Validate(); err != nil {
+			wrapper.BadRequest(w, err)
+			return
+		}
+
+		in.SetNamespace(namespace)
+		in.SetName(name)
+		if err := notifierSrv.Update(r.Context(), &in); err != nil {
+			wrapper.InternalError(w, err)
+			return
+		}
+		ctr.Success(w)
+	}
+}
+
+func notifierDelete(notifierSrv service.Notifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		namespace := wrapper.URLParam(r, "namespace")
+		name := wrapper.URLParam(r, "name")
+
+		if err := notifierSrv.Delete(r.Context(), namespace, name); err != nil {
+			wrapper.InternalError(w, err)
+			return
+		}
+		ctr.Success(w)
+	}
+}
@@ -19,10 +19,14 @@ import (
 
 	"github.com/go-chi/chi"
 
+	"github.com/zc2638/ink/core/auth"
 	"github.com/zc2638/ink/core/service/box"
 	"github.com/zc2638/ink/core/service/build"
+	"github.com/zc2638/ink/core/service/notifier"
 	"github.com/zc2638/ink/core/service/secret"
+	"github.com/zc2638/ink/core/service/token"
 	"github.com/zc2638/ink/core/service/workflow"
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
 )
 
 func Handler(middlewares chi.Middlewares) http.Handler {
@@ -33,52 +37,94 @@ func Handler(middlewares chi.Middlewares) http.Handler {
 	boxSrv := box.New()
 	buildSrv := build.New()
 	secretSrv := secret.New()
+	tokenSrv := token.New()
+	notifierSrv := notifier.New()
+
+	// the roles are required in the namespace of the url,
+	// the creations check the namespace of the body in the handlers.
+	viewer := auth.Require(v1.RoleViewer)
+	editor := auth.Require(v1.RoleEditor)
+	admin := auth.Require(v1.RoleAdmin)
 
 	r.Route("/box", func(r chi.Router) {
-		r.Get("/", boxList(boxSrv))
-		r.Get("/{namespace}", boxList(boxSrv))
+		r.With(viewer).Get("/", boxList(boxSrv))
+		r.With(viewer).Get("/{namespace}", boxList(boxSrv))
 		r.Post("/", boxCreate(boxSrv))
 
 		r.Route("/{namespace}/{name}", func(r chi.Router) {
-			r.Get("/", boxInfo(boxSrv))
-			r.Put("/", boxUpdate(boxSrv))
-			r.Delete("/", boxDelete(boxSrv))
+			r.With(viewer).Get("/", boxInfo(boxSrv))
+			r.With(editor).Put("/", boxUpdate(boxSrv))
+			r.With(editor).Delete("/", boxDelete(boxSrv))
 
 			r.Route("/build", func(r chi.Router) {
-				r.Get("/", buildList(buildSrv))
-				r.Post("/", buildCreate(buildSrv))
+				r.With(viewer).Get("/", buildList(buildSrv))
+				r.With(editor).Post("/", buildCreate(buildSrv))
 
 				r.Route("/{number}", func(r chi.Router) {
+					r.Use(viewer)
 					r.Get("/", buildInfo(buildSrv))
-					r.Post("/cancel", buildCancel(buildSrv))
+					r.With(editor).Post("/cancel", buildCancel(buildSrv))
+					r.With(editor).Post("/rerun", buildRerun(buildSrv))
+					r.With(editor).Post("/stages/{stage}/approve", buildApprove(buildSrv, true))
+					r.With(editor).Post("/stages/{stage}/reject", buildApprove(buildSrv, false))
 					r.Get("/logs/{stage}/{step}", logInfo())
 					r.Post("/logs/{stage}/{step}", logWatch())
+					r.Post("/logs/{stage}/services", serviceLogWatch())
+					r.Get("/artifacts", artifactList(buildSrv))
+					r.Get("/artifacts/{stage}/{step}", artifactDownload(buildSrv))
+					r.Get("/notifications", buildNotifications(buildSrv))
+					r.Get("/events", buildEvents(buildSrv))
 				})
 			})
 		})
 	})
 
+	r.Route("/events", func(r chi.Router) {
+		r.With(viewer).Get("/", namespaceEvents())
+		r.With(viewer).Get("/{namespace}", namespaceEvents())
+	})
+
 	r.Route("/workflow", func(r chi.Router) {
 		r.Post("/", workflowCreate(workflowSrv))
-		r.Get("/", workflowList(workflowSrv))
-		r.Get("/{namespace}", workflowList(workflowSrv))
-		r.Delete("/{namespace}", workflowDelete(workflowSrv))
+		r.With(viewer).Get("/", workflowList(workflowSrv))
+		r.With(viewer).Get("/{namespace}", workflowList(workflowSrv))
+		r.With(editor).Delete("/{namespace}", workflowDelete(workflowSrv))
 		r.Route("/{namespace}/{name}", func(r chi.Router) {
-			r.Get("/", workflowInfo(workflowSrv))
-			r.Put("/", workflowUpdate(workflowSrv))
-			r.Delete("/", workflowDelete(workflowSrv))
+			r.With(viewer).Get("/", workflowInfo(workflowSrv))
+			r.With(editor).Put("/", workflowUpdate(workflowSrv))
+			r.With(editor).Delete("/", workflowDelete(workflowSrv))
+		})
+	})
+
+	r.Route("/notifier", func(r chi.Router) {
+		r.Post("/", notifierCreate(notifierSrv))
+		r.With(viewer).Get("/", notifierList(notifierSrv))
+		r.With(viewer).Get("/{namespace}", notifierList(notifierSrv))
+		r.Route("/{namespace}/{name}", func(r chi.Router) {
+			r.With(viewer).Get("/", notifierInfo(notifierSrv))
+			r.With(editor).Put("/", notifierUpdate(notifierSrv))
+			r.With(editor).Delete("/", notifierDelete(notifierSrv))
 		})
 	})
 
 	r.Route("/secret", func(r chi.Router) {
-		r.Get("/", secretList(secretSrv))
-		r.Get("/{namespace}", secretList(secretSrv))
+		r.With(admin).Get("/", secretList(secretSrv))
+		r.With(admin).Get("/{namespace}", secretList(secretSrv))
 		r.Post("/", secretCreate(secretSrv))
 		r.Route("/{namespace}/{name}", func(r chi.Router) {
+			r.Use(admin)
 			r.Get("/", secretInfo(secretSrv))
 			r.Put("/", secretUpdate(secretSrv))
 			r.Delete("/", secretDelete(secretSrv))
 		})
 	})
+
+	// the tokens are managed by the admin of all namespaces.
+	r.Route("/token", func(r chi.Router) {
+		r.Use(admin)
+		r.Get("/", tokenList(tokenSrv))
+		r.Post("/", tokenCreate(tokenSrv))
+		r.Delete("/{name}", tokenDelete(tokenSrv))
+	})
 	return r
 }
@@ -0,0 +1,64 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	"github.com/zc2638/ink/core/auth"
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+	"github.com/zc2638/ink/pkg/pubsub"
+)
+
+func TestEventsAuthorization(t *testing.T) {
+	token := &v1.Token{
+		Name:  "team",
+		Roles: []v1.RoleBinding{{Namespace: "team", Role: v1.RoleViewer}},
+	}
+	h := Handler(chi.Middlewares{func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := auth.WithContext(r.Context(), token)
+			ctx = pubsub.WithContext(ctx, pubsub.New())
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}})
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{path: "/events/team", want: http.StatusOK},
+		{path: "/events/other", want: http.StatusForbidden},
+		{path: "/events", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			// the stream ends as soon as it is opened by the canceled request.
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil).WithContext(ctx))
+			if w.Code != tt.want {
+				t.Fatalf("Handler() status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
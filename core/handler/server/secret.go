@@ -20,6 +20,7 @@ import (
 
 	"github.com/99nil/gopkg/ctr"
 
+	"github.com/zc2638/ink/core/auth"
 	"github.com/zc2638/ink/core/handler/wrapper"
 	"github.com/zc2638/ink/core/service"
 	v1 "github.com/zc2638/ink/pkg/api/core/v1"
@@ -62,6 +63,10 @@ func secretCreate(secretSrv service.Secret) http.HandlerFunc {
 			wrapper.BadRequest(w, err)
 			return
 		}
+		if err := auth.Authorize(r, in.GetNamespace(), v1.RoleAdmin); err != nil {
+			wrapper.ErrorCode(w, http.StatusForbidden, err)
+			return
+		}
 
 		if err := secretSrv.Create(r.Context(), &in); err != nil {
 			wrapper.InternalError(w, err)
@@ -0,0 +1,70 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/99nil/gopkg/ctr"
+
+	"github.com/zc2638/ink/core/handler/wrapper"
+	"github.com/zc2638/ink/core/service"
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+)
+
+func tokenList(tokenSrv service.Token) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, err := tokenSrv.List(r.Context())
+		if err != nil {
+			wrapper.InternalError(w, err)
+			return
+		}
+		ctr.OK(w, result)
+	}
+}
+
+func tokenCreate(tokenSrv service.Token) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var in v1.Token
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			wrapper.BadRequest(w, err)
+			return
+		}
+		if err := in.Validate(); err != nil {
+			wrapper.BadRequest(w, err)
+			return
+		}
+
+		result, err := tokenSrv.Create(r.Context(), &in)
+		if err != nil {
+			wrapper.InternalError(w, err)
+			return
+		}
+		ctr.OK(w, result)
+	}
+}
+
+func tokenDelete(tokenSrv service.Token) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := wrapper.URLParam(r, "name")
+
+		if err := tokenSrv.Delete(r.Context(), name); err != nil {
+			wrapper.InternalError(w, err)
+			return
+		}
+		ctr.Success(w)
+	}
+}
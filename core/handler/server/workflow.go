@@ -20,6 +20,7 @@ import (
 
 	"github.com/99nil/gopkg/ctr"
 
+	"github.com/zc2638/ink/core/auth"
 	"github.com/zc2638/ink/core/handler/wrapper"
 	"github.com/zc2638/ink/core/service"
 	v1 "github.com/zc2638/ink/pkg/api/core/v1"
@@ -62,11 +63,13 @@ func workflowCreate(workflowSrv service.Workflow) http.HandlerFunc {
 			wrapper.BadRequest(w, err)
 			return
 		}
-		if in.Spec.When != nil {
-			if err := in.Spec.When.Validate(); err != nil {
-				wrapper.BadRequest(w, err)
-				return
-			}
+		if err := in.Validate(); err != nil {
+			wrapper.BadRequest(w, err)
+			return
+		}
+		if err := auth.Authorize(r, in.GetNamespace(), v1.RoleEditor); err != nil {
+			wrapper.ErrorCode(w, http.StatusForbidden, err)
+			return
 		}
 
 		if err := workflowSrv.Create(r.Context(), &in); err != nil {
@@ -87,11 +90,9 @@ func workflowUpdate(workflowSrv service.Workflow) http.HandlerFunc {
 			wrapper.BadRequest(w, err)
 			return
 		}
-		if in.Spec.When != nil {
-			if err := in.Spec.When.Validate(); err != nil {
-				wrapper.BadRequest(w, err)
-				return
-			}
+		if err := in.Validate(); err != nil {
+			wrapper.BadRequest(w, err)
+			return
 		}
 
 		in.SetNamespace(namespace)
@@ -0,0 +1,232 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/zc2638/wslog"
+	"gorm.io/gorm"
+
+	"github.com/zc2638/ink/core/service"
+	"github.com/zc2638/ink/core/service/secret"
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+	storageV1 "github.com/zc2638/ink/pkg/api/storage/v1"
+	"github.com/zc2638/ink/pkg/database"
+	"github.com/zc2638/ink/pkg/keyring"
+	"github.com/zc2638/ink/pkg/notifier"
+)
+
+// defaultRetry is used if the notifier does not define the retry policy.
+var defaultRetry = &v1.Retry{Count: 3, Backoff: v1.Duration(time.Second * 2)}
+
+// Interface sends the build events to the notifiers.
+type Interface interface {
+	// Notify sends the current phase of the build to the notifiers of the box asynchronously.
+	Notify(ctx context.Context, buildID uint64)
+}
+
+// New returns the notify to deliver the events of the builds,
+// the keyring decrypts the secrets used by the notifiers.
+// The deliveries are abandoned when the context is done.
+func New(ctx context.Context, db *gorm.DB, kr *keyring.Keyring) Interface {
+	ctx = database.WithContext(ctx, db)
+	ctx = keyring.WithContext(ctx, kr)
+	return &notify{ctx: ctx, db: db, secretSrv: secret.New()}
+}
+
+type notify struct {
+	ctx       context.Context
+	db        *gorm.DB
+	secretSrv service.Secret
+}
+
+func (n *notify) Notify(ctx context.Context, buildID uint64) {
+	log := wslog.FromContext(ctx).With("build", buildID)
+	go func() {
+		if err := n.notify(log, buildID); err != nil {
+			log.Error("Notify build failed", "error", err)
+		}
+	}()
+}
+
+func (n *notify) notify(log *wslog.Logger, buildID uint64) error {
+	buildS := new(storageV1.Build)
+	buildS.SetID(buildID)
+	if err := n.db.Where(buildS).First(buildS).Error; err != nil {
+		return err
+	}
+	build, err := buildS.ToAPI()
+	if err != nil {
+		return err
+	}
+	event := v1.NotifyEvent(build.Phase)
+	if event == "" {
+		return nil
+	}
+
+	boxS := new(storageV1.Box)
+	boxS.SetID(build.BoxID)
+	if err := n.db.Where(boxS).First(boxS).Error; err != nil {
+		return err
+	}
+	box, err := boxS.ToAPI()
+	if err != nil {
+		return err
+	}
+
+	notifiers, err := n.listNotifiers(box, build)
+	if err != nil {
+		return err
+	}
+	if len(notifiers) == 0 {
+		return nil
+	}
+
+	var stageList []storageV1.Stage
+	if err := n.db.Where(&storageV1.Stage{BuildID: build.ID}).Order("number").Find(&stageList).Error; err != nil {
+		return err
+	}
+	data := &notifier.Data{Event: event, Box: box, Build: build}
+	for _, v := range stageList {
+		stage, err := v.ToAPI()
+		if err != nil {
+			return err
+		}
+		data.Stages = append(data.Stages, stage)
+	}
+
+	for _, v := range notifiers {
+		if !v.Match(event) {
+			continue
+		}
+		go n.deliver(log.With("notifier", v.Name), v, data)
+	}
+	return nil
+}
+
+// listNotifiers returns the notifiers selected by the box,
+// and the notifiers applied to all boxes in the namespace.
+func (n *notify) listNotifiers(box *v1.Box, build *v1.Build) ([]*v1.Notifier, error) {
+	names, selectors := box.GetSelectors(v1.KindNotifier, build.CompleteSettings(box))
+
+	db := n.db.Where("namespace = ?", box.Namespace)
+	if !slices.Contains(names, "") {
+		db = db.Where(n.db.Where("all_boxes = ?", true).Or("name in (?)", names))
+	}
+	var list []storageV1.Notifier
+	if err := db.Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]*v1.Notifier, 0, len(list))
+	for _, v := range list {
+		item, err := v.ToAPI()
+		if err != nil {
+			return nil, err
+		}
+
+		matched := item.Spec.AllBoxes || len(selectors) == 0
+		for _, sv := range selectors {
+			if matched {
+				break
+			}
+			matched = sv.Match(item.Labels)
+		}
+		if matched {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+// deliver sends the event by the notifier, and records the delivery in the history.
+func (n *notify) deliver(log *wslog.Logger, item *v1.Notifier, data *notifier.Data) {
+	record := &storageV1.Notification{
+		BuildID:  data.Build.ID,
+		Notifier: item.Name,
+		Event:    data.Event,
+		Phase:    v1.PhaseRunning.String(),
+	}
+	if err := n.db.Create(record).Error; err != nil {
+		log.Error("Create notification failed", "error", err)
+		return
+	}
+
+	err := n.send(record, item, data)
+	record.Phase = v1.PhaseSucceeded.String()
+	if err != nil {
+		log.Error("Deliver notification failed", "error", err)
+		record.Phase = v1.PhaseFailed.String()
+		record.Error = err.Error()
+		if len(record.Error) > 1000 {
+			record.Error = record.Error[:1000]
+		}
+	}
+	where := new(storageV1.Notification)
+	where.SetID(record.ID)
+	if err := n.db.Model(where).Where(where).Updates(record).Error; err != nil {
+		log.Error("Update notification failed", "error", err)
+	}
+}
+
+func (n *notify) send(record *storageV1.Notification, item *v1.Notifier, data *notifier.Data) error {
+	var secretData map[string]string
+	if item.Spec.Secret != "" {
+		sec, err := n.secretSrv.Info(n.ctx, item.Namespace, item.Spec.Secret)
+		if err != nil {
+			return fmt.Errorf("get secret(%s) failed: %v", item.Spec.Secret, err)
+		}
+		if err := sec.Decrypt(); err != nil {
+			return err
+		}
+		secretData = sec.Data
+	}
+
+	sender, err := notifier.New(&item.Spec, secretData)
+	if err != nil {
+		return err
+	}
+	message, err := notifier.Render(item.Spec.Template, data)
+	if err != nil {
+		return fmt.Errorf("render message failed: %v", err)
+	}
+
+	retry := item.Spec.Retry
+	if retry == nil {
+		retry = defaultRetry
+	}
+	record.Attempts, err = sendWithRetry(n.ctx, sender, retry, data, message)
+	return err
+}
+
+// sendWithRetry sends the message until it succeeds or the retry policy is exhausted,
+// or the context is done, and returns the number of the attempts.
+func sendWithRetry(ctx context.Context, sender notifier.Sender, retry *v1.Retry, data *notifier.Data, message string) (int, error) {
+	for attempts := 1; ; attempts++ {
+		err := sender.Send(ctx, data, message)
+		if err == nil || !retry.Match(attempts-1, -1) {
+			return attempts, err
+		}
+		select {
+		case <-ctx.Done():
+			return attempts, ctx.Err()
+		case <-time.After(retry.Delay(attempts)):
+		}
+	}
+}
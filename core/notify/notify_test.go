@@ -0,0 +1,74 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+	"github.com/zc2638/ink/pkg/notifier"
+)
+
+// fakeSender fails the first attempts.
+type fakeSender struct {
+	failures int
+	attempts int
+}
+
+func (s *fakeSender) Send(context.Context, *notifier.Data, string) error {
+	s.attempts++
+	if s.attempts <= s.failures {
+		return errors.New("send failed")
+	}
+	return nil
+}
+
+func TestSendWithRetry(t *testing.T) {
+	backoff := v1.Duration(time.Millisecond)
+	tests := []struct {
+		name         string
+		failures     int
+		canceled     bool
+		retry        *v1.Retry
+		wantAttempts int
+		wantErr      bool
+	}{
+		{name: "success", retry: &v1.Retry{Count: 3, Backoff: backoff}, wantAttempts: 1},
+		{name: "retried", failures: 2, retry: &v1.Retry{Count: 3, Backoff: backoff}, wantAttempts: 3},
+		{name: "exhausted", failures: 5, retry: &v1.Retry{Count: 2, Backoff: backoff}, wantAttempts: 3, wantErr: true},
+		{name: "no retry", failures: 1, retry: &v1.Retry{}, wantAttempts: 1, wantErr: true},
+		{name: "canceled", failures: 1, canceled: true, retry: &v1.Retry{Count: 3, Backoff: v1.Duration(time.Hour)}, wantAttempts: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.canceled {
+				cancel()
+			}
+			sender := &fakeSender{failures: tt.failures}
+			attempts, err := sendWithRetry(ctx, sender, tt.retry, nil, "message")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sendWithRetry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts || sender.attempts != tt.wantAttempts {
+				t.Fatalf("sendWithRetry() attempts = %d, sent %d, want %d", attempts, sender.attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zc2638/wslog"
+	"gorm.io/gorm"
+
+	"github.com/zc2638/ink/core/service"
+	"github.com/zc2638/ink/core/service/secret"
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+	storageV1 "github.com/zc2638/ink/pkg/api/storage/v1"
+	"github.com/zc2638/ink/pkg/commitstatus"
+	"github.com/zc2638/ink/pkg/database"
+	"github.com/zc2638/ink/pkg/keyring"
+)
+
+// DefaultQueueSize is the number of the builds waiting to be reported.
+const DefaultQueueSize = 1000
+
+// Interface reports the phases of the builds to the commits.
+type Interface interface {
+	// Report queues the build to report the statuses of it and its stages,
+	// it never blocks, and the build is dropped if the queue is full.
+	Report(ctx context.Context, buildID uint64)
+}
+
+// New creates the reporter, the keyring decrypts the secrets of the access tokens.
+func New(db *gorm.DB, kr *keyring.Keyring) *Reporter {
+	return &Reporter{
+		db:        db,
+		kr:        kr,
+		secretSrv: secret.New(),
+		queue:     make(chan uint64, DefaultQueueSize),
+		reported:  make(map[string]commitstatus.State),
+	}
+}
+
+type Reporter struct {
+	db        *gorm.DB
+	kr        *keyring.Keyring
+	secretSrv service.Secret
+	queue     chan uint64
+
+	// reported records the last reported states to skip the unchanged statuses,
+	// it is only accessed by the worker.
+	reported map[string]commitstatus.State
+}
+
+func (r *Reporter) Report(ctx context.Context, buildID uint64) {
+	select {
+	case r.queue <- buildID:
+	default:
+		wslog.FromContext(ctx).Warn("Commit status queue is full", "build", buildID)
+	}
+}
+
+// Start reports the queued builds one by one until the context is done.
+func (r *Reporter) Start(ctx context.Context) {
+	ctx = database.WithContext(ctx, r.db)
+	ctx = keyring.WithContext(ctx, r.kr)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case buildID := <-r.queue:
+			if err := r.report(ctx, buildID); err != nil {
+				wslog.FromContext(ctx).Error("Report commit status failed", "build", buildID, "error", err)
+			}
+		}
+	}
+}
+
+func (r *Reporter) report(ctx context.Context, buildID uint64) error {
+	buildS := new(storageV1.Build)
+	buildS.SetID(buildID)
+	if err := r.db.Where(buildS).First(buildS).Error; err != nil {
+		return err
+	}
+	build, err := buildS.ToAPI()
+	if err != nil {
+		return err
+	}
+
+	boxS := new(storageV1.Box)
+	boxS.SetID(build.BoxID)
+	if err := r.db.Where(boxS).First(boxS).Error; err != nil {
+		return err
+	}
+	box, err := boxS.ToAPI()
+	if err != nil {
+		return err
+	}
+	cfg := box.CommitStatus
+	if cfg == nil {
+		return nil
+	}
+	sha := build.CompleteSettings(box)["DYNASTY_COMMIT_SHA"]
+	if sha == "" {
+		return nil
+	}
+
+	sec, err := r.secretSrv.Info(ctx, box.Namespace, cfg.Secret)
+	if err != nil {
+		return fmt.Errorf("get secret(%s) failed: %v", cfg.Secret, err)
+	}
+	if err := sec.Decrypt(); err != nil {
+		return err
+	}
+	reporter, err := commitstatus.New(cfg, sec.Data[v1.CommitStatusTokenKey])
+	if err != nil {
+		return err
+	}
+
+	var stageList []storageV1.Stage
+	if err := r.db.Where(&storageV1.Stage{BuildID: build.ID}).Order("number").Find(&stageList).Error; err != nil {
+		return err
+	}
+
+	name := cfg.Context
+	if name == "" {
+		name = commitstatus.DefaultContext
+	}
+	statuses := []*commitstatus.Status{{
+		SHA:         sha,
+		State:       commitstatus.StateOf(build.Phase),
+		Context:     name,
+		Description: fmt.Sprintf("Build #%d %s", build.Number, build.Phase),
+	}}
+	for _, v := range stageList {
+		statuses = append(statuses, &commitstatus.Status{
+			SHA:         sha,
+			State:       commitstatus.StateOf(v1.Phase(v.Phase)),
+			Context:     name + "/" + v.Name,
+			Description: fmt.Sprintf("Stage %s %s", v.Name, v.Phase),
+		})
+	}
+
+	log := wslog.FromContext(ctx).With("box", box.Namespace+"/"+box.Name, "build", build.Number)
+	for _, status := range statuses {
+		key := fmt.Sprintf("%d/%s", build.ID, status.Context)
+		if r.reported[key] == status.State {
+			continue
+		}
+		// the failed status is reported again on the next phase change.
+		if err := reporter.Report(ctx, status); err != nil {
+			log.Error("Report commit status failed", "context", status.Context, "error", err)
+			continue
+		}
+		r.reported[key] = status.State
+	}
+
+	if build.Phase.IsDone() {
+		for _, status := range statuses {
+			delete(r.reported, fmt.Sprintf("%d/%s", build.ID, status.Context))
+		}
+	}
+	return nil
+}
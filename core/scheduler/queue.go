@@ -33,6 +33,10 @@ type queue struct {
 	storeFunc StoreFunc
 	workers   map[*worker]struct{}
 	ctx       context.Context
+
+	// wake signals the queue when the earliest held stage is ready.
+	wake     *time.Timer
+	wakeTime time.Time
 }
 
 // newQueue returns a new Queue backed by the build datastore.
@@ -143,10 +147,18 @@ func (q *queue) signal(ctx context.Context) error {
 
 	q.Lock()
 	defer q.Unlock()
+	now := time.Now()
 	for _, item := range items {
 		if item.Phase == v1.PhaseRunning {
 			continue
 		}
+		// the retried stage is held until the backoff is over.
+		if item.NotBefore > 0 {
+			if notBefore := time.Unix(item.NotBefore, 0); notBefore.After(now) {
+				q.wakeAt(notBefore)
+				continue
+			}
+		}
 
 		// if the stage defines concurrency limits, we
 		// need to make sure those limits are not exceeded
@@ -172,10 +184,14 @@ func (q *queue) signal(ctx context.Context) error {
 				// the worker is platform-specific. check to ensure
 				// the queue item matches the worker platform.
 				if item.Worker.Platform != nil {
-					if w.os != item.Worker.Platform.OS {
+					// the matrix may only select the os or the arch,
+					// the other one of the matrix stage matches any.
+					anyOS := len(item.Matrix) > 0 && item.Worker.Platform.OS == ""
+					anyArch := len(item.Matrix) > 0 && item.Worker.Platform.Arch == ""
+					if !anyOS && w.os != item.Worker.Platform.OS {
 						continue
 					}
-					if w.arch != item.Worker.Platform.Arch {
+					if !anyArch && w.arch != item.Worker.Platform.Arch {
 						continue
 					}
 				}
@@ -193,6 +209,21 @@ func (q *queue) signal(ctx context.Context) error {
 	return nil
 }
 
+// wakeAt signals the queue at the time unless it is signaled earlier,
+// the caller must hold the lock.
+func (q *queue) wakeAt(t time.Time) {
+	if q.wake != nil && q.wakeTime.After(time.Now()) && !q.wakeTime.After(t) {
+		return
+	}
+	if q.wake != nil {
+		q.wake.Stop()
+	}
+	q.wakeTime = t
+	q.wake = time.AfterFunc(time.Until(t), func() {
+		q.Schedule(q.ctx)
+	})
+}
+
 func (q *queue) start() {
 	for {
 		select {
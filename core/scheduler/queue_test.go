@@ -0,0 +1,89 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+)
+
+func TestQueueNotBefore(t *testing.T) {
+	stage := &v1.Stage{
+		ID:        1,
+		Phase:     v1.PhasePending,
+		Worker:    v1.Worker{Kind: v1.WorkerKindDocker},
+		NotBefore: time.Now().Add(2 * time.Second).Unix(),
+	}
+	q := newQueue(func(context.Context) ([]*v1.Stage, error) {
+		return []*v1.Stage{stage}, nil
+	})
+
+	ctx, cancel := context.WithTimeout(noContext, 500*time.Millisecond)
+	defer cancel()
+	if _, err := q.Request(ctx, v1.Worker{}); err == nil {
+		t.Fatal("Request() expected the stage to be held until the backoff is over")
+	}
+
+	ctx, cancel = context.WithTimeout(noContext, 5*time.Second)
+	defer cancel()
+	got, err := q.Request(ctx, v1.Worker{})
+	if err != nil {
+		t.Fatalf("Request() expected the stage after the backoff, error = %v", err)
+	}
+	if got.ID != stage.ID {
+		t.Fatalf("Request() got stage %d, want %d", got.ID, stage.ID)
+	}
+}
+
+func TestQueuePlatform(t *testing.T) {
+	linux := &v1.Platform{OS: "linux", Arch: "amd64"}
+	tests := []struct {
+		name     string
+		platform *v1.Platform
+		matrix   map[string]string
+		want     bool
+	}{
+		{name: "any", want: true},
+		{name: "matched", platform: &v1.Platform{OS: "linux", Arch: "amd64"}, want: true},
+		{name: "mismatched", platform: &v1.Platform{OS: "linux", Arch: "arm64"}},
+		{name: "os only", platform: &v1.Platform{OS: "linux"}},
+		{name: "matrix os only", platform: &v1.Platform{OS: "linux"}, matrix: map[string]string{"os": "linux"}, want: true},
+		{name: "matrix arch only", platform: &v1.Platform{Arch: "amd64"}, matrix: map[string]string{"arch": "amd64"}, want: true},
+		{name: "matrix mismatched", platform: &v1.Platform{Arch: "arm64"}, matrix: map[string]string{"arch": "arm64"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stage := &v1.Stage{
+				ID:     1,
+				Phase:  v1.PhasePending,
+				Worker: v1.Worker{Kind: v1.WorkerKindDocker, Platform: tt.platform},
+				Matrix: tt.matrix,
+			}
+			q := newQueue(func(context.Context) ([]*v1.Stage, error) {
+				return []*v1.Stage{stage}, nil
+			})
+
+			ctx, cancel := context.WithTimeout(noContext, 200*time.Millisecond)
+			defer cancel()
+			_, err := q.Request(ctx, v1.Worker{Platform: linux})
+			if got := err == nil; got != tt.want {
+				t.Fatalf("Request() matched = %v, want %v, error = %v", got, tt.want, err)
+			}
+		})
+	}
+}
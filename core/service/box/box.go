@@ -17,6 +17,7 @@ package box
 import (
 	"context"
 	"reflect"
+	"time"
 
 	"gorm.io/gorm"
 
@@ -70,6 +71,9 @@ func (s *srv) List(ctx context.Context, namespace string, opt v1.ListOption) ([]
 	return result, nil
 }
 
+// upcomingCount is the number of the upcoming runs shown in the schedule status.
+const upcomingCount = 3
+
 func (s *srv) Info(ctx context.Context, namespace, name string) (*v1.Box, error) {
 	db := database.FromContext(ctx)
 
@@ -85,6 +89,25 @@ func (s *srv) Info(ctx context.Context, namespace, name string) (*v1.Box, error)
 	if err := db.Model(&storageV1.Build{}).Where(&storageV1.Build{BoxID: info.ID}).Count(&out.Status.Builds).Error; err != nil {
 		return nil, err
 	}
+
+	if len(out.Schedules) > 0 {
+		var states []storageV1.Schedule
+		if err := db.Where(&storageV1.Schedule{BoxID: info.ID}).Find(&states).Error; err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		for _, schedule := range out.Schedules {
+			status := v1.ScheduleStatus{Name: schedule.Name}
+			for _, state := range states {
+				if state.Name == schedule.Name {
+					status.LastFired = time.Unix(state.LastFired, 0)
+					break
+				}
+			}
+			status.Upcoming, _ = schedule.Upcoming(now, upcomingCount)
+			out.Status.Schedules = append(out.Status.Schedules, status)
+		}
+	}
 	return out, nil
 }
 
@@ -180,6 +203,10 @@ func (s *srv) Delete(ctx context.Context, namespace, name string) error {
 		return constant.ErrNoRecord
 	}
 	return db.Transaction(func(tx *gorm.DB) error {
+		if err := db.Where("box_id IN (?)", db.Model(sd).Select("id").Where(sd)).
+			Delete(&storageV1.Schedule{}).Error; err != nil {
+			return err
+		}
 		if err := db.Where(sd).Delete(sd).Error; err != nil {
 			return err
 		}
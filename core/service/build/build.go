@@ -20,14 +20,20 @@ import (
 	"fmt"
 	"maps"
 	"slices"
+	"strings"
+	"time"
 
 	"github.com/99nil/gopkg/sets"
 
+	"github.com/zc2638/ink/core/constant"
+	"github.com/zc2638/ink/core/notify"
+	"github.com/zc2638/ink/core/report"
 	"github.com/zc2638/ink/core/scheduler"
 	"gorm.io/gorm"
 
 	storageV1 "github.com/zc2638/ink/pkg/api/storage/v1"
 	"github.com/zc2638/ink/pkg/database"
+	"github.com/zc2638/ink/pkg/pubsub"
 
 	"github.com/zc2638/ink/core/service"
 	v1 "github.com/zc2638/ink/pkg/api/core/v1"
@@ -126,7 +132,47 @@ func (s *srv) Info(ctx context.Context, namespace, name string, number uint64) (
 	return build, nil
 }
 
-func (s *srv) Create(ctx context.Context, namespace, name string, settings map[string]string) (uint64, error) {
+func (s *srv) Create(ctx context.Context, namespace, name string, settings map[string]string, commit *v1.Commit) (uint64, error) {
+	build := &v1.Build{
+		Settings: settings,
+		Commit:   commit,
+	}
+	if commit != nil {
+		build.Title, _, _ = strings.Cut(commit.Message, "\n")
+	}
+	return s.create(ctx, namespace, name, build, nil)
+}
+
+func (s *srv) Rerun(ctx context.Context, namespace, name string, number uint64, failedOnly bool) (uint64, error) {
+	origin, err := s.Info(ctx, namespace, name, number)
+	if err != nil {
+		return 0, err
+	}
+	build := &v1.Build{
+		Title:    origin.Title,
+		Settings: origin.Settings,
+		Commit:   origin.Commit,
+		RerunOf:  origin.Number,
+	}
+	if !failedOnly {
+		return s.create(ctx, namespace, name, build, nil)
+	}
+
+	if !origin.Phase.IsDone() {
+		return 0, errors.New("the build is not done")
+	}
+	var passed []string
+	for _, v := range origin.Stages {
+		if v.Phase.IsSucceeded() || v.Phase == v1.PhaseSkipped {
+			passed = append(passed, v.Name)
+		}
+	}
+	return s.create(ctx, namespace, name, build, passed)
+}
+
+// create creates the build by the template, the stages passed
+// are skipped and the others run by their dependencies.
+func (s *srv) create(ctx context.Context, namespace, name string, template *v1.Build, passed []string) (uint64, error) {
 	db := database.FromContext(ctx)
 
 	boxS := &storageV1.Box{
@@ -149,19 +195,27 @@ func (s *srv) Create(ctx context.Context, namespace, name string, settings map[s
 
 	currentSettings := make(map[string]string)
 	maps.Copy(currentSettings, box.Settings)
-	maps.Copy(currentSettings, settings)
+	maps.Copy(currentSettings, template.Settings)
+	if err := box.CheckSettings(currentSettings); err != nil {
+		return 0, err
+	}
 	build := &v1.Build{
 		BoxID:    box.ID,
 		Number:   uint64(buildCount) + 1,
 		Phase:    v1.PhasePending,
+		Title:    template.Title,
 		Settings: currentSettings,
+		Commit:   template.Commit,
+		RerunOf:  template.RerunOf,
 	}
 	var buildS storageV1.Build
 	if err := buildS.FromAPI(build); err != nil {
 		return 0, err
 	}
 
-	workflowNames, selectors := box.GetSelectors(v1.KindWorkflow, currentSettings)
+	// the selectors can match the commit of the build
+	matchSettings := build.CompleteSettings(box)
+	workflowNames, selectors := box.GetSelectors(v1.KindWorkflow, matchSettings)
 	if len(workflowNames) == 0 {
 		return 0, errors.New("workflow resource not found")
 	}
@@ -198,45 +252,43 @@ func (s *srv) Create(ctx context.Context, namespace, name string, settings map[s
 		return 0, errors.New("no workflow matched")
 	}
 
+	stages := v1.NewStages(workflows, matchSettings)
+	if len(passed) > 0 {
+		for _, stage := range stages {
+			if !slices.Contains(passed, stage.Name) {
+				continue
+			}
+			stage.Phase = v1.PhaseSkipped
+			for _, step := range stage.Steps {
+				step.Phase = v1.PhaseSkipped
+			}
+		}
+		v1.ReleaseStages(stages)
+
+		if !slices.ContainsFunc(stages, func(stage *v1.Stage) bool { return !stage.Phase.IsDone() }) {
+			return 0, errors.New("no stage to rerun")
+		}
+	}
+
 	err = db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(&buildS).Error; err != nil {
 			return err
 		}
 
-		for k, workflow := range workflows {
-			status := &v1.Stage{
-				BoxID:     box.ID,
-				BuildID:   buildS.ID,
-				Number:    uint64(k) + 1,
-				Phase:     v1.PhasePending,
-				Name:      workflow.Name,
-				Limit:     workflow.Spec.Concurrency,
-				Worker:    *workflow.Worker(),
-				DependsOn: workflow.Spec.DependsOn,
-			}
-			if !workflow.Spec.When.Match(currentSettings) {
-				status.Phase = v1.PhaseSkipped
-			}
+		for _, stage := range stages {
+			stage.BoxID = box.ID
+			stage.BuildID = buildS.ID
 
-			var statusS storageV1.Stage
-			if err := statusS.FromAPI(status); err != nil {
+			var stageS storageV1.Stage
+			if err := stageS.FromAPI(stage); err != nil {
 				return err
 			}
-			if err := tx.Create(&statusS).Error; err != nil {
+			if err := tx.Create(&stageS).Error; err != nil {
 				return err
 			}
 
-			for sk, sv := range workflow.Spec.Steps {
-				step := &v1.Step{
-					StageID: statusS.ID,
-					Number:  uint64(sk) + 1,
-					Phase:   v1.PhasePending,
-					Name:    sv.Name,
-				}
-				if status.Phase == v1.PhaseSkipped {
-					step.Phase = v1.PhaseSkipped
-				}
-
+			for _, step := range stage.Steps {
+				step.StageID = stageS.ID
 				stepS := new(storageV1.Step)
 				stepS.FromAPI(step)
 				if err := tx.Create(stepS).Error; err != nil {
@@ -249,6 +301,9 @@ func (s *srv) Create(ctx context.Context, namespace, name string, settings map[s
 	if err != nil {
 		return 0, err
 	}
+	if rp := report.FromContext(ctx); rp != nil {
+		rp.Report(ctx, buildS.ID)
+	}
 	return buildS.Number, nil
 }
 
@@ -278,12 +333,14 @@ func (s *srv) Cancel(ctx context.Context, namespace, name string, number uint64)
 		return errors.New("already done")
 	}
 
+	// the stages not started yet are canceled directly.
+	queued := []string{v1.PhasePending.String(), v1.PhaseWaiting.String(), v1.PhaseWaitingApproval.String()}
 	var stages []storageV1.Stage
 	if err := db.Where(&storageV1.Stage{
 		BoxID:   buildS.BoxID,
 		BuildID: buildS.ID,
-		Phase:   v1.PhasePending.String(),
-	}).Find(&stages).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+	}).Where("phase in (?)", queued).
+		Find(&stages).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		return fmt.Errorf("find pending stages failed: %v", err)
 	}
 
@@ -297,7 +354,7 @@ func (s *srv) Cancel(ctx context.Context, namespace, name string, number uint64)
 		err := db.Transaction(func(tx *gorm.DB) error {
 			if err := tx.Model(&storageV1.Stage{}).
 				Where("id in (?)", stageIds).
-				Where(&storageV1.Stage{Phase: v1.PhasePending.String()}).
+				Where("phase in (?)", queued).
 				Update("phase", v1.PhaseCanceled).Error; err != nil {
 				return fmt.Errorf("cancel pending stages failed: %v", err)
 			}
@@ -314,6 +371,287 @@ func (s *srv) Cancel(ctx context.Context, namespace, name string, number uint64)
 		}
 	}
 
+	// the build is done if none of the stages is running,
+	// otherwise it is done when the running stages end.
+	var unfinished int64
+	if err := db.Model(&storageV1.Stage{}).
+		Where(&storageV1.Stage{BuildID: buildS.ID}).
+		Where("phase in (?)", []string{
+			v1.PhaseWaiting.String(), v1.PhaseWaitingApproval.String(), v1.PhasePending.String(), v1.PhaseRunning.String(),
+		}).
+		Count(&unfinished).Error; err != nil {
+		return err
+	}
+	if unfinished == 0 {
+		buildS.Phase = v1.PhaseCanceled.String()
+		buildS.Stopped = time.Now().Unix()
+		ended, err := EndBuild(db, buildS)
+		if err != nil {
+			return err
+		}
+		if ps := pubsub.FromContext(ctx); ended && ps != nil {
+			ps.Publish(ctx, &v1.PhaseEvent{
+				Kind:      v1.PhaseEventBuild,
+				Namespace: namespace,
+				Box:       name,
+				Build:     number,
+				Phase:     v1.PhaseCanceled,
+				Time:      time.Now(),
+			})
+		}
+		if nt := notify.FromContext(ctx); ended && nt != nil {
+			nt.Notify(ctx, buildS.ID)
+		}
+	}
+	if rp := report.FromContext(ctx); rp != nil {
+		rp.Report(ctx, buildS.ID)
+	}
+
 	sched := scheduler.FromContext(ctx)
 	return sched.Cancel(ctx, int64(build.ID))
 }
+
+func (s *srv) Approve(ctx context.Context, namespace, name string, number, stage uint64, approval *v1.StageApproval) error {
+	return s.decide(ctx, namespace, name, number, stage, approval, true)
+}
+
+func (s *srv) Reject(ctx context.Context, namespace, name string, number, stage uint64, approval *v1.StageApproval) error {
+	return s.decide(ctx, namespace, name, number, stage, approval, false)
+}
+
+// decide releases the stage waiting for the approval if it is approved,
+// otherwise cancels the stage and the stages depending on it.
+func (s *srv) decide(
+	ctx context.Context,
+	namespace, name string,
+	number, stage uint64,
+	approval *v1.StageApproval,
+	approved bool,
+) error {
+	db := database.FromContext(ctx)
+
+	boxS := &storageV1.Box{
+		Namespace: namespace,
+		Name:      name,
+	}
+	if err := db.Where(boxS).First(boxS).Error; err != nil {
+		return notFound(err, "box %s/%s", namespace, name)
+	}
+	buildS := &storageV1.Build{
+		BoxID:  boxS.ID,
+		Number: number,
+	}
+	if err := db.Where(buildS).First(buildS).Error; err != nil {
+		return notFound(err, "build %d", number)
+	}
+	stageS := &storageV1.Stage{
+		BuildID: buildS.ID,
+		Number:  stage,
+	}
+	if err := db.Where(stageS).First(stageS).Error; err != nil {
+		return notFound(err, "stage %d", stage)
+	}
+
+	comment := approval.Comment
+	if len(comment) > 1000 {
+		comment = comment[:1000]
+	}
+	values := map[string]any{
+		"phase":    v1.PhasePending.String(),
+		"approver": approval.Approver,
+		"comment":  comment,
+	}
+	if !approved {
+		now := time.Now().Unix()
+		values["phase"] = v1.PhaseCanceled.String()
+		values["started"] = now
+		values["stopped"] = now
+		values["error"] = fmt.Sprintf("rejected by %s", approval.Approver)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// only one of the decisions succeeds.
+		result := tx.Model(&storageV1.Stage{}).
+			Where("id = ? AND phase = ?", stageS.ID, v1.PhaseWaitingApproval.String()).
+			Updates(values)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("stage %d is %w", stage, constant.ErrNotWaitingApproval)
+		}
+		if approved {
+			return nil
+		}
+		return tx.Model(&storageV1.Step{}).
+			Where(&storageV1.Step{StageID: stageS.ID, Phase: v1.PhasePending.String()}).
+			Update("phase", v1.PhaseCanceled.String()).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	ps := pubsub.FromContext(ctx)
+	publish := func(event *v1.PhaseEvent) {
+		if ps == nil {
+			return
+		}
+		event.Namespace = namespace
+		event.Box = name
+		event.Build = number
+		event.Time = time.Now()
+		ps.Publish(ctx, event)
+	}
+	publish(&v1.PhaseEvent{
+		Kind:  v1.PhaseEventStage,
+		Stage: stage,
+		Name:  stageS.Name,
+		Phase: v1.Phase(values["phase"].(string)),
+	})
+	defer func() {
+		if rp := report.FromContext(ctx); rp != nil {
+			rp.Report(ctx, buildS.ID)
+		}
+	}()
+	if approved {
+		return nil
+	}
+
+	var stageList []storageV1.Stage
+	if err := db.Where(&storageV1.Stage{BuildID: buildS.ID}).Order("number").Find(&stageList).Error; err != nil {
+		return err
+	}
+	stages := make([]*v1.Stage, 0, len(stageList))
+	for _, v := range stageList {
+		item, err := v.ToAPI()
+		if err != nil {
+			return err
+		}
+		stages = append(stages, item)
+	}
+	if err := CancelDownstream(db, stages); err != nil {
+		return err
+	}
+
+	// the build is done if the rejection leaves no stage to run,
+	// otherwise it is done when the running stages end.
+	phase := v1.PhaseSucceeded
+	for _, sv := range stages {
+		if !sv.Phase.IsDone() {
+			return nil
+		}
+		if phase == v1.PhaseSucceeded && (sv.Phase.IsFailed() || sv.Phase == v1.PhaseCanceled) {
+			phase = sv.Phase
+		}
+	}
+	buildS.Phase = phase.String()
+	buildS.Stopped = time.Now().Unix()
+	ended, err := EndBuild(db, buildS)
+	if err != nil || !ended {
+		return err
+	}
+	publish(&v1.PhaseEvent{
+		Kind:  v1.PhaseEventBuild,
+		Phase: phase,
+	})
+	if nt := notify.FromContext(ctx); nt != nil {
+		nt.Notify(ctx, buildS.ID)
+	}
+	return nil
+}
+
+func (s *srv) Notifications(ctx context.Context, namespace, name string, number uint64) ([]*v1.Notification, error) {
+	db := database.FromContext(ctx)
+
+	boxS := &storageV1.Box{
+		Namespace: namespace,
+		Name:      name,
+	}
+	if err := db.Where(boxS).First(boxS).Error; err != nil {
+		return nil, err
+	}
+	buildS := &storageV1.Build{
+		BoxID:  boxS.ID,
+		Number: number,
+	}
+	if err := db.Where(buildS).First(buildS).Error; err != nil {
+		return nil, err
+	}
+
+	var list []storageV1.Notification
+	if err := db.Where(&storageV1.Notification{BuildID: buildS.ID}).Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	result := make([]*v1.Notification, 0, len(list))
+	for _, v := range list {
+		result = append(result, v.ToAPI())
+	}
+	return result, nil
+}
+
+// CancelDownstream cancels the waiting stages whose dependencies failed,
+// the cancellation is cascaded to the stages depending on the canceled stages.
+func CancelDownstream(db *gorm.DB, stages []*v1.Stage) error {
+	var errs []error
+	for canceled := true; canceled; {
+		canceled = false
+		for _, s := range stages {
+			if s.Phase != v1.PhaseWaiting || !s.IsDepsDone(stages) || !s.IsDepsFailed(stages) {
+				continue
+			}
+			canceled = true
+
+			now := time.Now().Unix()
+			s.Phase = v1.PhaseCanceled
+			s.Started = now
+			s.Stopped = now
+
+			stageS := new(storageV1.Stage)
+			if err := stageS.FromAPI(s); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			stageWhere := new(storageV1.Stage)
+			stageWhere.SetID(s.ID)
+			if err := db.Model(stageWhere).Where(stageWhere).Updates(stageS).Error; err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if err := db.Model(&storageV1.Step{}).
+				Where(&storageV1.Step{StageID: s.ID, Phase: v1.PhasePending.String()}).
+				Update("phase", v1.PhaseCanceled.String()).Error; err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// EndBuild records the end of the build unless it has already ended,
+// it returns false if the build is ended by others, e.g. the end of the last stage races a rejection.
+func EndBuild(db *gorm.DB, buildS *storageV1.Build) (bool, error) {
+	if buildS.Started == 0 {
+		buildS.Started = buildS.Stopped
+	}
+	buildWhere := new(storageV1.Build)
+	buildWhere.SetID(buildS.ID)
+	result := db.Model(buildWhere).Where(buildWhere).
+		Where("phase NOT IN (?)", []string{
+			v1.PhaseSucceeded.String(), v1.PhaseFailed.String(), v1.PhaseCanceled.String(),
+			v1.PhaseSkipped.String(), v1.PhaseTimedOut.String(),
+		}).
+		Updates(buildS)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// notFound describes the missing record with constant.ErrNoRecord,
+// the other errors are returned as they are.
+func notFound(err error, format string, args ...any) error {
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return fmt.Errorf("%s: %w", fmt.Sprintf(format, args...), constant.ErrNoRecord)
+}
@@ -0,0 +1,72 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+	storageV1 "github.com/zc2638/ink/pkg/api/storage/v1"
+	"github.com/zc2638/ink/pkg/database"
+	"github.com/zc2638/ink/resource"
+)
+
+func TestEndBuild(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "ink.db")
+	if err := resource.MigrateDatabase("sqlite3", dsn); err != nil {
+		t.Fatal(err)
+	}
+	db, err := database.New(database.Config{Driver: "sqlite3", DSN: dsn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buildS := &storageV1.Build{BoxID: 1, Number: 1, Phase: v1.PhaseRunning.String()}
+	if err := db.Create(buildS).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		phase     v1.Phase
+		want      bool
+		wantPhase v1.Phase
+	}{
+		{name: "rejected", phase: v1.PhaseCanceled, want: true, wantPhase: v1.PhaseCanceled},
+		{name: "last stage ended", phase: v1.PhaseSucceeded, want: false, wantPhase: v1.PhaseCanceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := &storageV1.Build{Phase: tt.phase.String(), Stopped: 100}
+			in.SetID(buildS.ID)
+			got, err := EndBuild(db, in)
+			if err != nil {
+				t.Fatalf("EndBuild() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("EndBuild() = %v, want %v", got, tt.want)
+			}
+
+			out := new(storageV1.Build)
+			out.SetID(buildS.ID)
+			if err := db.Where(out).First(out).Error; err != nil {
+				t.Fatal(err)
+			}
+			if out.Phase != tt.wantPhase.String() {
+				t.Fatalf("EndBuild() phase = %s, want %s", out.Phase, tt.wantPhase)
+			}
+		})
+	}
+}
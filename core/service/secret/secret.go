@@ -26,6 +26,7 @@ import (
 	v1 "github.com/zc2638/ink/pkg/api/core/v1"
 	storageV1 "github.com/zc2638/ink/pkg/api/storage/v1"
 	"github.com/zc2638/ink/pkg/database"
+	"github.com/zc2638/ink/pkg/keyring"
 )
 
 func New() service.Secret {
@@ -60,8 +61,12 @@ func (s *srv) List(ctx context.Context, namespace string, opt v1.ListOption) ([]
 		return nil, err
 	}
 
+	kr := keyring.FromContext(ctx)
 	result := make([]*v1.Secret, 0, len(list))
 	for _, v := range list {
+		if err := v.Decrypt(kr); err != nil {
+			return nil, err
+		}
 		item, err := v.ToAPI()
 		if err != nil {
 			return nil, err
@@ -78,6 +83,9 @@ func (s *srv) Info(ctx context.Context, namespace, name string) (*v1.Secret, err
 	if err := db.Where(sd).First(sd).Error; err != nil {
 		return nil, err
 	}
+	if err := sd.Decrypt(keyring.FromContext(ctx)); err != nil {
+		return nil, err
+	}
 	return sd.ToAPI()
 }
 
@@ -96,6 +104,9 @@ func (s *srv) Create(ctx context.Context, data *v1.Secret) error {
 	if err := sd.FromAPI(data); err != nil {
 		return err
 	}
+	if err := sd.Encrypt(keyring.FromContext(ctx)); err != nil {
+		return err
+	}
 	labels := common.ConvertLabels(v1.KindSecret, sd.Namespace, sd.Name, data.Labels)
 	return db.Transaction(func(tx *gorm.DB) error {
 		if err := db.Create(sd).Error; err != nil {
@@ -119,6 +130,10 @@ func (s *srv) Update(ctx context.Context, data *v1.Secret) error {
 	if err := db.Where(sd).First(sd).Error; err != nil {
 		return err
 	}
+	kr := keyring.FromContext(ctx)
+	if err := sd.Decrypt(kr); err != nil {
+		return err
+	}
 	origin, err := sd.ToAPI()
 	if err != nil {
 		return err
@@ -126,6 +141,9 @@ func (s *srv) Update(ctx context.Context, data *v1.Secret) error {
 	if err := sd.FromAPI(data); err != nil {
 		return err
 	}
+	if err := sd.Encrypt(kr); err != nil {
+		return err
+	}
 
 	var labels []storageV1.Label
 	labelChanged := !reflect.DeepEqual(origin.Labels, data.Labels)
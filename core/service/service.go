@@ -37,11 +37,29 @@ type (
 		Delete(ctx context.Context, namespace, name string) error
 	}
 
+	Notifier interface {
+		List(ctx context.Context, namespace string, opt v1.ListOption) ([]*v1.Notifier, error)
+		Info(ctx context.Context, namespace, name string) (*v1.Notifier, error)
+		Create(ctx context.Context, data *v1.Notifier) error
+		Update(ctx context.Context, data *v1.Notifier) error
+		Delete(ctx context.Context, namespace, name string) error
+	}
+
 	Build interface {
 		List(ctx context.Context, namespace, name string, page *v1.Pagination) ([]*v1.Build, error)
 		Info(ctx context.Context, namespace, name string, number uint64) (*v1.Build, error)
-		Create(ctx context.Context, namespace, name string, settings map[string]string) (uint64, error)
+		// Create creates the build of the box, the commit is optional.
+		Create(ctx context.Context, namespace, name string, settings map[string]string, commit *v1.Commit) (uint64, error)
+		// Rerun creates a new build with the settings and the commit of the build,
+		// only the failed and canceled stages run if failedOnly is true.
+		Rerun(ctx context.Context, namespace, name string, number uint64, failedOnly bool) (uint64, error)
 		Cancel(ctx context.Context, namespace, name string, number uint64) error
+		// Approve releases the stage of the build waiting for the approval.
+		Approve(ctx context.Context, namespace, name string, number, stage uint64, approval *v1.StageApproval) error
+		// Reject cancels the stage of the build waiting for the approval, and the stages depending on it.
+		Reject(ctx context.Context, namespace, name string, number, stage uint64, approval *v1.StageApproval) error
+		// Notifications returns the delivery history of the notifications of the build.
+		Notifications(ctx context.Context, namespace, name string, number uint64) ([]*v1.Notification, error)
 	}
 
 	Secret interface {
@@ -51,4 +69,11 @@ type (
 		Update(ctx context.Context, data *v1.Secret) error
 		Delete(ctx context.Context, namespace, name string) error
 	}
+
+	Token interface {
+		List(ctx context.Context) ([]*v1.Token, error)
+		// Create generates the value of the token, the value is only available in the returned token.
+		Create(ctx context.Context, data *v1.Token) (*v1.Token, error)
+		Delete(ctx context.Context, name string) error
+	}
 )
@@ -0,0 +1,105 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+
+	"github.com/zc2638/ink/core/constant"
+	"github.com/zc2638/ink/core/service"
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+	storageV1 "github.com/zc2638/ink/pkg/api/storage/v1"
+	"github.com/zc2638/ink/pkg/database"
+)
+
+// valuePrefix makes the tokens recognizable, e.g. by the secret scanners.
+const valuePrefix = "ink_"
+
+func New() service.Token {
+	return &srv{}
+}
+
+type srv struct{}
+
+func (s *srv) List(ctx context.Context) ([]*v1.Token, error) {
+	db := database.FromContext(ctx)
+
+	var list []storageV1.Token
+	if err := db.Order("name").Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]*v1.Token, 0, len(list))
+	for _, v := range list {
+		item, err := v.ToAPI()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+func (s *srv) Create(ctx context.Context, data *v1.Token) (*v1.Token, error) {
+	if err := data.Validate(); err != nil {
+		return nil, err
+	}
+	db := database.FromContext(ctx)
+
+	var count int64
+	sd := &storageV1.Token{Name: data.Name}
+	if err := db.Where(sd).Model(sd).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, constant.ErrAlreadyExists
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	value := valuePrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	if err := sd.FromAPI(data); err != nil {
+		return nil, err
+	}
+	sd.Hash = storageV1.HashToken(value)
+	if err := db.Create(sd).Error; err != nil {
+		return nil, err
+	}
+
+	result, err := sd.ToAPI()
+	if err != nil {
+		return nil, err
+	}
+	result.Token = value
+	return result, nil
+}
+
+func (s *srv) Delete(ctx context.Context, name string) error {
+	db := database.FromContext(ctx)
+
+	result := db.Where(&storageV1.Token{Name: name}).Delete(&storageV1.Token{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return constant.ErrNoRecord
+	}
+	return nil
+}
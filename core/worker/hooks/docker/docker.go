@@ -15,9 +15,12 @@
 package docker
 
 import (
+	"archive/tar"
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"path"
 	"strings"
 	"sync"
 
@@ -96,8 +99,53 @@ func (h *docker) Begin(ctx context.Context, spec *worker.Workflow) error {
 		spec.Worker.Platform.OS == "windows" {
 		driver = "nat"
 	}
-	_, err := h.client.NetworkCreate(ctx, spec.ID, types.NetworkCreate{Driver: driver})
-	return trimExtraInfo(err)
+	if _, err := h.client.NetworkCreate(ctx, spec.ID, types.NetworkCreate{Driver: driver}); err != nil {
+		return trimExtraInfo(err)
+	}
+
+	for _, service := range spec.Services {
+		if err := h.startService(ctx, state, spec, service); err != nil {
+			return fmt.Errorf("start service(%s) failed: %v", service.Name, trimExtraInfo(err))
+		}
+	}
+	return nil
+}
+
+// startService starts the service container in the background,
+// it is reachable by name from the steps on the workflow network.
+func (h *docker) startService(ctx context.Context, state *dockerState, spec *worker.Workflow, service *worker.Step) error {
+	writer := newLogWriter(worker.ServiceLogFromContext(ctx), service.Name)
+
+	state.addContainer(service.Name, service.ID)
+	if err := h.pull(ctx, service, writer); err != nil {
+		return err
+	}
+
+	containerConfig := toContainerConfig(spec, service)
+	hostConfig := toHostConfig(spec, service)
+	networkConfig := toNetConfig(spec, service)
+	if _, err := h.client.ContainerCreate(ctx, containerConfig, hostConfig, networkConfig, nil, service.ID); err != nil {
+		return err
+	}
+	if err := h.client.ContainerStart(ctx, service.ID, types.ContainerStartOptions{}); err != nil {
+		return err
+	}
+
+	logs, err := h.client.ContainerLogs(ctx, service.ID, types.ContainerLogsOptions{
+		Follow:     true,
+		ShowStdout: true,
+		ShowStderr: true,
+	})
+	if err != nil {
+		return err
+	}
+	// the logs stream is closed when the container is removed.
+	go func() {
+		defer logs.Close()
+		_, _ = StdCopy(writer, logs)
+		_ = writer.Close()
+	}()
+	return nil
 }
 
 func (h *docker) End(ctx context.Context, spec *worker.Workflow) error {
@@ -113,8 +161,9 @@ func (h *docker) End(ctx context.Context, spec *worker.Workflow) error {
 	}
 	log := wslog.FromContext(ctx)
 
+	containers := state.listContainers()
 	// stop step containers
-	for _, id := range state.containers {
+	for _, id := range containers {
 		if err := h.client.ContainerKill(ctx, id, "9"); err != nil && !client.IsErrNotFound(err) && !errdefs.IsConflict(err) {
 			log.Error("Kill container failed",
 				"error", err,
@@ -123,7 +172,7 @@ func (h *docker) End(ctx context.Context, spec *worker.Workflow) error {
 		}
 	}
 	// remove step containers
-	for _, id := range state.containers {
+	for _, id := range containers {
 		if err := h.client.ContainerRemove(ctx, id, removeOpts); err != nil && !client.IsErrNotFound(err) {
 			log.Error("Remove container failed",
 				"error", err,
@@ -157,41 +206,16 @@ func (h *docker) Step(ctx context.Context, spec *worker.Workflow, step *worker.S
 	if state == nil {
 		return nil, errors.New("abnormal state")
 	}
-	state.containers[step.Name] = step.ID
+	state.addContainer(step.Name, step.ID)
 
-	image := ImageExpand(step.Image)
-	isLatest := strings.HasSuffix(image, ":latest")
-	pullOpts := types.ImagePullOptions{
-		RegistryAuth: step.ImagePullAuth,
+	if err := h.pull(ctx, step, writer); err != nil {
+		return nil, err
 	}
 
-	if step.ImagePullPolicy == v1.PullIfNotPresent {
-		var imageExist bool
-		if !isLatest {
-			searchImage := strings.TrimPrefix(image, "docker.io/library/")
-			imageList, err := h.client.ImageList(ctx, types.ImageListOptions{
-				Filters: filters.NewArgs(filters.Arg("reference", searchImage)),
-			})
-			if err != nil {
-				return nil, err
-			}
-			imageExist = len(imageList) > 0
-		}
-		if !imageExist {
-			rc, pullErr := h.client.ImagePull(ctx, image, pullOpts)
-			if pullErr != nil {
-				return nil, pullErr
-			}
-			_ = PullReaderCopy(rc, writer)
-			rc.Close()
-		}
-	} else if step.ImagePullPolicy == v1.PullAlways {
-		rc, pullErr := h.client.ImagePull(ctx, image, pullOpts)
-		if pullErr != nil {
-			return nil, pullErr
-		}
-		_ = PullReaderCopy(rc, writer)
-		rc.Close()
+	// remove the container left by the previous attempt of the step.
+	if err := h.client.ContainerRemove(ctx, step.ID, types.ContainerRemoveOptions{Force: true}); err != nil &&
+		!client.IsErrNotFound(err) {
+		return nil, err
 	}
 
 	containerConfig := toContainerConfig(spec, step)
@@ -222,6 +246,15 @@ func (h *docker) Step(ctx context.Context, spec *worker.Workflow, step *worker.S
 	waitCh, errCh := h.client.ContainerWait(ctx, step.ID, container.WaitConditionNotRunning)
 	select {
 	case err = <-errCh:
+		if ctx.Err() != nil {
+			// the step is canceled or timed out, the container
+			// must be killed to release the resources immediately.
+			if err := h.client.ContainerKill(context.WithoutCancel(ctx), step.ID, "9"); err != nil &&
+				!client.IsErrNotFound(err) && !errdefs.IsConflict(err) {
+				log.Error("Kill container failed", "error", err)
+			}
+			return nil, ctx.Err()
+		}
 		if errdefs.IsCancelled(err) {
 			return nil, context.Canceled
 		}
@@ -240,6 +273,99 @@ func (h *docker) Step(ctx context.Context, spec *worker.Workflow, step *worker.S
 	}, nil
 }
 
+func (h *docker) Artifacts(ctx context.Context, _ *worker.Workflow, step *worker.Step, fn worker.ArtifactFunc) error {
+	// only the directories which may contain the artifacts are copied,
+	// instead of the whole working directory.
+	for _, root := range worker.ArtifactRoots(step.Artifacts) {
+		if err := h.copyArtifacts(ctx, step, root, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyArtifacts copies the path relative to the working directory from the container,
+// and handles the files matched by the artifact patterns.
+func (h *docker) copyArtifacts(ctx context.Context, step *worker.Step, root string, fn worker.ArtifactFunc) error {
+	rc, _, err := h.client.CopyFromContainer(ctx, step.ID, path.Join(step.WorkingDir, root))
+	if err != nil {
+		// nothing is matched if the path does not exist.
+		if client.IsErrNotFound(err) {
+			return nil
+		}
+		return trimExtraInfo(err)
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// the entries are prefixed with the base name of the copied path.
+		name := path.Join(path.Dir(root), header.Name)
+		if root == "." {
+			var ok bool
+			if _, name, ok = strings.Cut(header.Name, "/"); !ok {
+				continue
+			}
+		}
+		if !worker.MatchArtifact(step.Artifacts, name) {
+			continue
+		}
+		if err := fn(name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// pull pulls the image of the step according to the pull policy.
+func (h *docker) pull(ctx context.Context, step *worker.Step, writer io.Writer) error {
+	image := ImageExpand(step.Image)
+	isLatest := strings.HasSuffix(image, ":latest")
+	pullOpts := types.ImagePullOptions{
+		RegistryAuth: step.ImagePullAuth,
+	}
+
+	if step.ImagePullPolicy == v1.PullIfNotPresent {
+		var imageExist bool
+		if !isLatest {
+			searchImage := strings.TrimPrefix(image, "docker.io/library/")
+			imageList, err := h.client.ImageList(ctx, types.ImageListOptions{
+				Filters: filters.NewArgs(filters.Arg("reference", searchImage)),
+			})
+			if err != nil {
+				return err
+			}
+			imageExist = len(imageList) > 0
+		}
+		if !imageExist {
+			rc, pullErr := h.client.ImagePull(ctx, image, pullOpts)
+			if pullErr != nil {
+				return pullErr
+			}
+			_ = PullReaderCopy(rc, writer)
+			rc.Close()
+		}
+	} else if step.ImagePullPolicy == v1.PullAlways {
+		rc, pullErr := h.client.ImagePull(ctx, image, pullOpts)
+		if pullErr != nil {
+			return pullErr
+		}
+		_ = PullReaderCopy(rc, writer)
+		rc.Close()
+	}
+	return nil
+}
+
 // trimExtraInfo is a helper function that trims extra information
 // from a Docker error. Specifically, on Windows, this can expose
 // environment variables and other sensitive data.
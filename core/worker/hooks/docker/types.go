@@ -15,10 +15,12 @@
 package docker
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
+	"sync"
 )
 
 func newDockerState() *dockerState {
@@ -31,9 +33,65 @@ func newDockerState() *dockerState {
 type dockerState struct {
 	id         string
 	volumes    map[string]string
+	mux        sync.Mutex
 	containers map[string]string
 }
 
+// addContainer records the container of the step,
+// the steps of a stage may be executed concurrently.
+func (s *dockerState) addContainer(name, id string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.containers[name] = id
+}
+
+func (s *dockerState) listContainers() []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	ids := make([]string, 0, len(s.containers))
+	for _, id := range s.containers {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// logWriter writes the output of the service containers
+// to the service log of the stage line by line, prefixed by the service name.
+type logWriter struct {
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func newLogWriter(w io.Writer, name string) *logWriter {
+	return &logWriter{w: w, prefix: "[" + name + "] "}
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		// each line is written at once since the services share the same log.
+		if _, err := io.WriteString(w.w, w.prefix+string(w.buf[:i+1])); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (w *logWriter) Close() error {
+	if len(w.buf) > 0 {
+		_, err := io.WriteString(w.w, w.prefix+string(w.buf)+"\n")
+		w.buf = nil
+		return err
+	}
+	return nil
+}
+
 type jsonError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
@@ -17,9 +17,13 @@ package hooks
 import (
 	"github.com/zc2638/ink/core/worker/hooks/docker"
 	"github.com/zc2638/ink/core/worker/hooks/host"
+	"github.com/zc2638/ink/core/worker/hooks/kubernetes"
+	"github.com/zc2638/ink/core/worker/hooks/ssh"
 )
 
 var (
-	NewDocker = docker.New
-	NewHost   = host.New
+	NewDocker     = docker.New
+	NewHost       = host.New
+	NewKubernetes = kubernetes.New
+	NewSSH        = ssh.New
 )
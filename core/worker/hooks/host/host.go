@@ -17,10 +17,12 @@ package host
 import (
 	"context"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/zc2638/wslog"
 
@@ -43,6 +45,16 @@ func (h *host) Begin(ctx context.Context, spec *worker.Workflow) error {
 		return err
 	}
 
+	rootDir := getRootDir(spec)
+	for _, step := range spec.Steps {
+		for _, vm := range step.VolumeMounts {
+			if err := linkCache(spec, rootDir, vm); err != nil {
+				log.Error("cannot link cache", "error", err)
+				return err
+			}
+		}
+	}
+
 	for _, step := range spec.Steps {
 		cmdName, args := shell.Command()
 		if len(step.Shell) > 0 {
@@ -67,6 +79,37 @@ func (h *host) End(_ context.Context, spec *worker.Workflow) error {
 	return os.RemoveAll(getRootDir(spec))
 }
 
+func (h *host) Artifacts(ctx context.Context, spec *worker.Workflow, step *worker.Step, fn worker.ArtifactFunc) error {
+	workingDir := filepath.Join(getRootDir(spec), step.WorkingDir)
+	return filepath.WalkDir(workingDir, func(fp string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(workingDir, fp)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if !worker.MatchArtifact(step.Artifacts, name) {
+			return nil
+		}
+
+		f, err := os.Open(fp)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return fn(name, f)
+	})
+}
+
 func (h *host) Step(ctx context.Context, spec *worker.Workflow, step *worker.Step, writer io.Writer) (*worker.State, error) {
 	if len(step.Command) == 0 {
 		return nil, nil
@@ -87,11 +130,14 @@ func (h *host) Step(ctx context.Context, spec *worker.Workflow, step *worker.Ste
 		"INK_WORKSPACE": workingDir,
 	})
 
-	cmd := exec.CommandContext(ctx, step.Command[0], step.Args...)
+	cmd := exec.Command(step.Command[0], step.Args...)
 	cmd.Env = worker.EnvToSlice(env)
 	cmd.Dir = workingDir
 	cmd.Stdout = writer
 	cmd.Stderr = writer
+	// the child processes may hold the output pipes after being killed.
+	cmd.WaitDelay = 5 * time.Second
+	setProcessGroup(cmd)
 
 	err := cmd.Start()
 	if err != nil {
@@ -110,7 +156,10 @@ func (h *host) Step(ctx context.Context, spec *worker.Workflow, step *worker.Ste
 	select {
 	case err = <-done:
 	case <-ctx.Done():
-		_ = cmd.Process.Kill()
+		if err := killProcess(cmd); err != nil {
+			log.Error("kill process failed", "error", err)
+		}
+		<-done
 
 		log.Debug("process killed")
 		return nil, ctx.Err()
@@ -19,6 +19,7 @@ import (
 	"path/filepath"
 
 	"github.com/zc2638/ink/core/worker"
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
 )
 
 func getRootDir(spec *worker.Workflow) string {
@@ -29,3 +30,22 @@ func getHomedir(spec *worker.Workflow) string {
 	dir := getRootDir(spec)
 	return filepath.Join(dir, "/home/ink")
 }
+
+// linkCache links the mount path of the cache volume to the cache directory,
+// the other volumes are not supported by the host.
+func linkCache(spec *worker.Workflow, rootDir string, vm v1.VolumeMount) error {
+	for _, v := range spec.Volumes {
+		if v.Name != vm.Name || v.Cache == nil || v.HostPath == nil {
+			continue
+		}
+		link := filepath.Join(rootDir, vm.Path)
+		if _, err := os.Lstat(link); err == nil {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(link), os.ModePerm); err != nil {
+			return err
+		}
+		return os.Symlink(v.HostPath.Path, link)
+	}
+	return nil
+}
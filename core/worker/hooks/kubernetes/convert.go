@@ -0,0 +1,305 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/zc2638/ink/core/constant"
+	"github.com/zc2638/ink/core/worker"
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+	"github.com/zc2638/ink/pkg/shell"
+)
+
+const labelStage = constant.Name + "/stage"
+
+func toPod(cfg Config, spec *worker.Workflow, pullSecret *corev1.Secret) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      spec.ID,
+			Namespace: cfg.Namespace,
+			Labels:    map[string]string{labelStage: spec.ID},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy:      corev1.RestartPolicyNever,
+			ServiceAccountName: cfg.ServiceAccount,
+			Volumes:            toVolumes(spec),
+			HostAliases:        toHostAliases(spec),
+			NodeSelector:       toNodeSelector(spec),
+		},
+	}
+	if pullSecret != nil {
+		pod.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: pullSecret.Name}}
+	}
+	for _, step := range spec.Steps {
+		pod.Spec.Containers = append(pod.Spec.Containers, toContainer(cfg, spec, step))
+	}
+	return pod
+}
+
+// toContainer converts the step to a container which runs the placeholder image,
+// the image is replaced with the step image when the step starts.
+func toContainer(cfg Config, spec *worker.Workflow, step *worker.Step) corev1.Container {
+	privileged := step.Privileged
+	optional := true
+	container := corev1.Container{
+		Name:            step.ID,
+		Image:           cfg.Placeholder,
+		ImagePullPolicy: toPullPolicy(step.ImagePullPolicy),
+		WorkingDir:      step.WorkingDir,
+		VolumeMounts:    toVolumeMounts(spec, step),
+		// the environments are only known when the step starts,
+		// so they are loaded from the secret created at that time.
+		EnvFrom: []corev1.EnvFromSource{{
+			SecretRef: &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: step.ID},
+				Optional:             &optional,
+			},
+		}},
+		SecurityContext: &corev1.SecurityContext{
+			Privileged: &privileged,
+		},
+	}
+
+	if len(step.Entrypoint) > 0 {
+		container.Command = step.Entrypoint
+		container.Args = step.Command
+	} else if len(step.Command) > 0 {
+		cmdName, args := shell.Command()
+		if len(step.Shell) > 0 {
+			cmdName = step.Shell[0]
+			args = step.Shell[1:]
+		}
+		container.Command = append([]string{cmdName}, args...)
+		container.Args = shell.EchoEnvCommand("INK_SCRIPT", cmdName)
+	}
+	return container
+}
+
+// toEnvSecret returns the secret which holds the environments of the step.
+func toEnvSecret(cfg Config, spec *worker.Workflow, step *worker.Step) *corev1.Secret {
+	var configEnv map[string]string
+	if len(step.Entrypoint) == 0 && len(step.Command) > 0 {
+		configEnv = map[string]string{
+			"INK_SCRIPT": shell.Script(step.Command),
+		}
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      step.ID,
+			Namespace: cfg.Namespace,
+			Labels:    map[string]string{labelStage: spec.ID},
+		},
+		Type:       corev1.SecretTypeOpaque,
+		StringData: step.CombineEnv(configEnv),
+	}
+}
+
+// toPullSecret returns the image pull secret of all steps,
+// nil is returned if no step requires authentication.
+func toPullSecret(cfg Config, spec *worker.Workflow) (*corev1.Secret, error) {
+	auths := make(map[string]v1.DockerAuth)
+	for _, step := range spec.Steps {
+		if step.ImagePullAuth == "" {
+			continue
+		}
+		domain := imageDomain(step.Image)
+		if _, ok := auths[domain]; ok {
+			continue
+		}
+
+		authBytes, err := base64.URLEncoding.DecodeString(step.ImagePullAuth)
+		if err != nil {
+			return nil, fmt.Errorf("decode image pull auth of step(%s) failed: %v", step.Name, err)
+		}
+		var auth v1.DockerAuth
+		if err := json.Unmarshal(authBytes, &auth); err != nil {
+			return nil, fmt.Errorf("parse image pull auth of step(%s) failed: %v", step.Name, err)
+		}
+		auth.Encrypt()
+		auths[domain] = auth
+	}
+	if len(auths) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(&v1.DockerAuths{Auths: auths})
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      spec.ID + "-pull",
+			Namespace: cfg.Namespace,
+			Labels:    map[string]string{labelStage: spec.ID},
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: data,
+		},
+	}, nil
+}
+
+func toVolumes(spec *worker.Workflow) []corev1.Volume {
+	var volumes []corev1.Volume
+	for i, v := range spec.Volumes {
+		volume := corev1.Volume{Name: toVolumeName(i)}
+		switch {
+		case v.EmptyDir != nil:
+			source := &corev1.EmptyDirVolumeSource{}
+			if v.EmptyDir.Medium == v1.StorageMediumMemory {
+				source.Medium = corev1.StorageMediumMemory
+			}
+			if v.EmptyDir.SizeLimit > 0 {
+				source.SizeLimit = resource.NewQuantity(int64(v.EmptyDir.SizeLimit), resource.BinarySI)
+			}
+			volume.EmptyDir = source
+		case v.HostPath != nil:
+			volume.HostPath = &corev1.HostPathVolumeSource{Path: v.HostPath.Path}
+		default:
+			continue
+		}
+		volumes = append(volumes, volume)
+	}
+	return volumes
+}
+
+func toVolumeMounts(spec *worker.Workflow, step *worker.Step) []corev1.VolumeMount {
+	var mounts []corev1.VolumeMount
+	for _, vm := range step.VolumeMounts {
+		index := lookupVolume(spec, vm.Name)
+		if index < 0 {
+			continue
+		}
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      toVolumeName(index),
+			MountPath: vm.Path,
+		})
+	}
+	for _, vd := range step.Devices {
+		index := lookupVolume(spec, vd.Name)
+		if index < 0 || spec.Volumes[index].HostPath == nil {
+			continue
+		}
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      toVolumeName(index),
+			MountPath: vd.Path,
+		})
+	}
+	return mounts
+}
+
+// toHostAliases converts the extra hosts in the format of `host:ip`
+// to the host aliases of the pod.
+func toHostAliases(spec *worker.Workflow) []corev1.HostAlias {
+	ips := make(map[string][]string)
+	var order []string
+	for _, step := range spec.Steps {
+		for _, v := range step.ExtraHosts {
+			host, ip, ok := strings.Cut(v, ":")
+			if !ok || host == "" || ip == "" {
+				continue
+			}
+			if _, exists := ips[ip]; !exists {
+				order = append(order, ip)
+			}
+			ips[ip] = append(ips[ip], host)
+		}
+	}
+
+	aliases := make([]corev1.HostAlias, 0, len(order))
+	for _, ip := range order {
+		aliases = append(aliases, corev1.HostAlias{IP: ip, Hostnames: ips[ip]})
+	}
+	if len(aliases) == 0 {
+		return nil
+	}
+	return aliases
+}
+
+func toNodeSelector(spec *worker.Workflow) map[string]string {
+	if spec.Worker == nil || spec.Worker.Platform == nil {
+		return nil
+	}
+
+	selector := make(map[string]string)
+	if spec.Worker.Platform.OS != "" {
+		selector[corev1.LabelOSStable] = spec.Worker.Platform.OS
+	}
+	if spec.Worker.Platform.Arch != "" {
+		selector[corev1.LabelArchStable] = spec.Worker.Platform.Arch
+	}
+	if len(selector) == 0 {
+		return nil
+	}
+	return selector
+}
+
+func toPullPolicy(policy v1.PullPolicy) corev1.PullPolicy {
+	switch policy {
+	case v1.PullAlways:
+		return corev1.PullAlways
+	case v1.PullNever:
+		return corev1.PullNever
+	default:
+		return corev1.PullIfNotPresent
+	}
+}
+
+// toVolumeName returns the pod volume name,
+// because the volume name of workflow may not be a valid DNS label.
+func toVolumeName(index int) string {
+	return fmt.Sprintf("volume-%d", index)
+}
+
+// lookupVolume returns the index of the named volume.
+func lookupVolume(spec *worker.Workflow, name string) int {
+	for i, v := range spec.Volumes {
+		if v.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// imageDomain returns the registry domain of the image.
+func imageDomain(image string) string {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return image
+	}
+	return reference.Domain(named)
+}
+
+// imageEqual returns true if the images refer to the same name.
+func imageEqual(a, b string) bool {
+	return imageExpand(a) == imageExpand(b)
+}
+
+func imageExpand(name string) string {
+	named, err := reference.ParseNormalizedNamed(name)
+	if err != nil {
+		return name
+	}
+	return reference.TagNameOnly(named).String()
+}
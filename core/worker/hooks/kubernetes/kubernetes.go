@@ -0,0 +1,248 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/zc2638/wslog"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/zc2638/ink/core/worker"
+)
+
+func New(cfg Config) (worker.Hook, error) {
+	restConfig, err := clientcmd.BuildConfigFromFlags("", cfg.Kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithClient(client, cfg), nil
+}
+
+func NewWithClient(client kubernetes.Interface, cfg Config) worker.Hook {
+	if cfg.Namespace == "" {
+		cfg.Namespace = metav1.NamespaceDefault
+	}
+	if cfg.Placeholder == "" {
+		cfg.Placeholder = DefaultPlaceholder
+	}
+	return &kube{
+		client:   client,
+		cfg:      cfg,
+		interval: time.Second,
+	}
+}
+
+type kube struct {
+	client   kubernetes.Interface
+	cfg      Config
+	interval time.Duration
+	states   sync.Map
+}
+
+func (h *kube) getState(id string) *kubeState {
+	v, ok := h.states.Load(id)
+	if !ok {
+		return nil
+	}
+	return v.(*kubeState)
+}
+
+func (h *kube) addState(id string, state *kubeState) {
+	h.states.Store(id, state)
+}
+
+func (h *kube) delState(id string) {
+	h.states.Delete(id)
+}
+
+func (h *kube) Begin(ctx context.Context, spec *worker.Workflow) error {
+	state := newKubeState()
+	h.addState(spec.ID, state)
+
+	pullSecret, err := toPullSecret(h.cfg, spec)
+	if err != nil {
+		return err
+	}
+	if pullSecret != nil {
+		if _, err := h.client.CoreV1().Secrets(h.cfg.Namespace).Create(ctx, pullSecret, metav1.CreateOptions{}); err != nil {
+			return err
+		}
+		state.addSecret(pullSecret.Name)
+	}
+
+	pod := toPod(h.cfg, spec, pullSecret)
+	_, err = h.client.CoreV1().Pods(h.cfg.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+	return err
+}
+
+func (h *kube) End(ctx context.Context, spec *worker.Workflow) error {
+	state := h.getState(spec.ID)
+	if state == nil {
+		return errors.New("abnormal state")
+	}
+
+	// the resources also need to be cleaned up after the stage is canceled.
+	ctx = context.WithoutCancel(ctx)
+	log := wslog.FromContext(ctx)
+
+	var gracePeriod int64
+	deleteOpts := metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod}
+	if err := h.client.CoreV1().Pods(h.cfg.Namespace).Delete(ctx, spec.ID, deleteOpts); err != nil && !apierrors.IsNotFound(err) {
+		log.Error("Remove pod failed",
+			"error", err,
+			"pod", spec.ID,
+		)
+	}
+	for _, name := range state.listSecrets() {
+		if err := h.client.CoreV1().Secrets(h.cfg.Namespace).Delete(ctx, name, deleteOpts); err != nil && !apierrors.IsNotFound(err) {
+			log.Error("Remove secret failed",
+				"error", err,
+				"secret", name,
+			)
+		}
+	}
+	h.delState(spec.ID)
+	return nil
+}
+
+func (h *kube) Step(ctx context.Context, spec *worker.Workflow, step *worker.Step, writer io.Writer) (*worker.State, error) {
+	log := wslog.FromContext(ctx).With("workflow", spec.Name, "step", step.Name)
+
+	state := h.getState(spec.ID)
+	if state == nil {
+		return nil, errors.New("abnormal state")
+	}
+
+	envSecret := toEnvSecret(h.cfg, spec, step)
+	if _, err := h.client.CoreV1().Secrets(h.cfg.Namespace).Create(ctx, envSecret, metav1.CreateOptions{}); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			// the containers of the pod cannot be restarted.
+			return nil, errors.New("the step cannot be run again on kubernetes")
+		}
+		return nil, err
+	}
+	state.addSecret(envSecret.Name)
+
+	// replace the placeholder image, then the container restarts with the step image.
+	pods := h.client.CoreV1().Pods(h.cfg.Namespace)
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		pod, err := pods.Get(ctx, spec.ID, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		for i, c := range pod.Spec.Containers {
+			if c.Name == step.ID {
+				pod.Spec.Containers[i].Image = step.Image
+			}
+		}
+		_, err = pods.Update(ctx, pod, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := h.waitContainer(ctx, spec, step, func(status *corev1.ContainerStatus) bool {
+		return status.State.Running != nil || status.State.Terminated != nil
+	}); err != nil {
+		return nil, err
+	}
+
+	logs, err := pods.GetLogs(spec.ID, &corev1.PodLogOptions{
+		Container: step.ID,
+		Follow:    true,
+	}).Stream(ctx)
+	if err != nil {
+		log.With("error", err).Error("container logs error")
+	} else {
+		_, _ = io.Copy(writer, logs)
+		logs.Close()
+	}
+
+	status, err := h.waitContainer(ctx, spec, step, func(status *corev1.ContainerStatus) bool {
+		return status.State.Terminated != nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	terminated := status.State.Terminated
+	return &worker.State{
+		ExitCode:  int(terminated.ExitCode),
+		OOMKilled: terminated.Reason == "OOMKilled",
+	}, nil
+}
+
+// waitContainer polls the pod until the step container matches the condition.
+func (h *kube) waitContainer(
+	ctx context.Context,
+	spec *worker.Workflow,
+	step *worker.Step,
+	condition func(status *corev1.ContainerStatus) bool,
+) (*corev1.ContainerStatus, error) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		pod, err := h.client.CoreV1().Pods(h.cfg.Namespace).Get(ctx, spec.ID, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range pod.Status.ContainerStatuses {
+			status := &pod.Status.ContainerStatuses[i]
+			if status.Name != step.ID {
+				continue
+			}
+			if waiting := status.State.Waiting; waiting != nil {
+				switch waiting.Reason {
+				case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "ErrImageNeverPull",
+					"CreateContainerConfigError", "CreateContainerError":
+					return nil, fmt.Errorf("%s: %s", waiting.Reason, waiting.Message)
+				}
+			}
+			// the container is still the placeholder.
+			if !imageEqual(status.Image, step.Image) {
+				break
+			}
+			if condition(status) {
+				return status, nil
+			}
+		}
+		if pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodSucceeded {
+			return nil, fmt.Errorf("pod is already %s: %s", pod.Status.Phase, pod.Status.Message)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/zc2638/ink/core/worker"
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+)
+
+var noContext = context.Background()
+
+func testWorkflow() *worker.Workflow {
+	auth := base64.URLEncoding.EncodeToString([]byte(`{"username":"foo","password":"bar"}`))
+	spec := &worker.Workflow{
+		ID:   "ink-1",
+		Name: "test",
+		Volumes: []worker.Volume{
+			{Volume: v1.Volume{Name: "cache", EmptyDir: &v1.EmptyDirVolume{Medium: v1.StorageMediumMemory}}},
+		},
+		Steps: []*worker.Step{
+			{ID: "ink-2", Name: "build", Image: "golang:1.21", Command: []string{"go build"}},
+			{ID: "ink-3", Name: "push", Image: "registry.example.com/tools:v1", ImagePullAuth: auth,
+				VolumeMounts: []v1.VolumeMount{{Name: "cache", Path: "/cache"}}},
+		},
+	}
+	worker.Compile(spec)
+	return spec
+}
+
+func TestBeginEnd(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	hook := NewWithClient(client, Config{Namespace: "ci"})
+	spec := testWorkflow()
+
+	if err := hook.Begin(noContext, spec); err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+
+	pod, err := client.CoreV1().Pods("ci").Get(noContext, spec.ID, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get pod failed: %v", err)
+	}
+	if got, want := len(pod.Spec.Containers), 2; got != want {
+		t.Fatalf("Want %d containers, got %d", want, got)
+	}
+	for _, c := range pod.Spec.Containers {
+		if c.Image != DefaultPlaceholder {
+			t.Errorf("Want placeholder image for container %s, got %s", c.Name, c.Image)
+		}
+	}
+	if got, want := len(pod.Spec.Volumes), 2; got != want {
+		t.Fatalf("Want %d volumes, got %d", want, got)
+	}
+	if pod.Spec.Volumes[1].EmptyDir == nil || pod.Spec.Volumes[1].EmptyDir.Medium != corev1.StorageMediumMemory {
+		t.Errorf("Want memory empty dir volume")
+	}
+	if got, want := len(pod.Spec.Containers[1].VolumeMounts), 2; got != want {
+		t.Errorf("Want %d volume mounts, got %d", want, got)
+	}
+	if len(pod.Spec.ImagePullSecrets) != 1 {
+		t.Fatalf("Want image pull secret")
+	}
+
+	secret, err := client.CoreV1().Secrets("ci").Get(noContext, pod.Spec.ImagePullSecrets[0].Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get image pull secret failed: %v", err)
+	}
+	if !bytes.Contains(secret.Data[corev1.DockerConfigJsonKey], []byte("registry.example.com")) {
+		t.Errorf("Want auth of registry.example.com, got %s", secret.Data[corev1.DockerConfigJsonKey])
+	}
+
+	if err := hook.End(noContext, spec); err != nil {
+		t.Fatalf("End failed: %v", err)
+	}
+	pods, _ := client.CoreV1().Pods("ci").List(noContext, metav1.ListOptions{})
+	if len(pods.Items) != 0 {
+		t.Errorf("Want pod removed")
+	}
+	secrets, _ := client.CoreV1().Secrets("ci").List(noContext, metav1.ListOptions{})
+	if len(secrets.Items) != 0 {
+		t.Errorf("Want secrets removed")
+	}
+}
+
+func TestStep(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	hook := NewWithClient(client, Config{})
+	hook.(*kube).interval = 10 * time.Millisecond
+	spec := testWorkflow()
+	step := spec.Steps[0]
+
+	if err := hook.Begin(noContext, spec); err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+
+	// simulate the kubelet to run the step container.
+	go func() {
+		pods := client.CoreV1().Pods(metav1.NamespaceDefault)
+		for {
+			time.Sleep(10 * time.Millisecond)
+			pod, err := pods.Get(noContext, spec.ID, metav1.GetOptions{})
+			if err != nil || pod.Spec.Containers[0].Image != step.Image {
+				continue
+			}
+			pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+				Name:  step.ID,
+				Image: "docker.io/library/" + step.Image,
+				State: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{ExitCode: 2},
+				},
+			}}
+			_, _ = pods.UpdateStatus(noContext, pod, metav1.UpdateOptions{})
+			return
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(noContext, 5*time.Second)
+	defer cancel()
+
+	var buf bytes.Buffer
+	state, err := hook.Step(ctx, spec, step, &buf)
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if state.ExitCode != 2 {
+		t.Errorf("Want exit code 2, got %d", state.ExitCode)
+	}
+	if buf.Len() == 0 {
+		t.Errorf("Want container logs")
+	}
+
+	secret, err := client.CoreV1().Secrets(metav1.NamespaceDefault).Get(noContext, step.ID, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Get env secret failed: %v", err)
+	}
+	if _, ok := secret.StringData["INK_SCRIPT"]; !ok {
+		t.Errorf("Want INK_SCRIPT in env secret")
+	}
+}
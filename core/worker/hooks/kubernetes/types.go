@@ -0,0 +1,54 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import "sync"
+
+// DefaultPlaceholder is the image that keeps a step container idle
+// until the step starts. It ignores the container command and sleeps forever.
+const DefaultPlaceholder = "drone/placeholder:1"
+
+type Config struct {
+	// Kubeconfig defines the path of kubeconfig,
+	// the in-cluster config is used if it is empty.
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+	// Namespace defines the namespace where the stage pods are created.
+	Namespace string `json:"namespace,omitempty"`
+	// Placeholder defines the image used before the step starts.
+	Placeholder string `json:"placeholder,omitempty"`
+	// ServiceAccount defines the service account used by the stage pods.
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+}
+
+func newKubeState() *kubeState {
+	return &kubeState{}
+}
+
+type kubeState struct {
+	mux     sync.Mutex
+	secrets []string
+}
+
+func (s *kubeState) addSecret(name string) {
+	s.mux.Lock()
+	s.secrets = append(s.secrets, name)
+	s.mux.Unlock()
+}
+
+func (s *kubeState) listSecrets() []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return append([]string{}, s.secrets...)
+}
@@ -0,0 +1,296 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/zc2638/wslog"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/zc2638/ink/core/worker"
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+	"github.com/zc2638/ink/pkg/shell"
+)
+
+func New(cfg Config, secret *v1.Secret) (worker.Hook, error) {
+	if cfg.Addr == "" {
+		return nil, errors.New("ssh addr must be defined")
+	}
+	if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+		cfg.Addr = net.JoinHostPort(cfg.Addr, "22")
+	}
+	if cfg.Workspace == "" {
+		cfg.Workspace = defaultWorkspace
+	}
+
+	clientConfig, err := toClientConfig(cfg, secret)
+	if err != nil {
+		return nil, err
+	}
+	return &sshHook{
+		addr:      cfg.Addr,
+		workspace: cfg.Workspace,
+		config:    clientConfig,
+	}, nil
+}
+
+func toClientConfig(cfg Config, secret *v1.Secret) (*ssh.ClientConfig, error) {
+	if secret == nil {
+		return nil, errors.New("ssh secret must be defined")
+	}
+	if err := secret.Decrypt(); err != nil {
+		return nil, err
+	}
+
+	var auths []ssh.AuthMethod
+	if key := secret.Data[v1.SSHPrivateKeyKey]; key != "" {
+		var (
+			signer ssh.Signer
+			err    error
+		)
+		if passphrase := secret.Data[v1.SSHPassphraseKey]; passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(key), []byte(passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(key))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse private key failed: %v", err)
+		}
+		auths = append(auths, ssh.PublicKeys(signer))
+	}
+	if password := secret.Data[v1.SSHPasswordKey]; password != "" {
+		auths = append(auths, ssh.Password(password))
+	}
+	if len(auths) == 0 {
+		return nil, errors.New("ssh password or private key must be defined")
+	}
+
+	hostKeyCallback, err := toHostKeyCallback(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssh.ClientConfig{
+		User:            secret.Data[v1.SSHUsernameKey],
+		Auth:            auths,
+		HostKeyCallback: hostKeyCallback,
+	}, nil
+}
+
+func toHostKeyCallback(cfg Config) (ssh.HostKeyCallback, error) {
+	switch {
+	case cfg.HostKey != "":
+		hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(cfg.HostKey))
+		if err != nil {
+			return nil, fmt.Errorf("parse host key failed: %v", err)
+		}
+		return ssh.FixedHostKey(hostKey), nil
+	case cfg.KnownHosts != "":
+		callback, err := knownhosts.New(cfg.KnownHosts)
+		if err != nil {
+			return nil, fmt.Errorf("load known hosts failed: %v", err)
+		}
+		return callback, nil
+	case cfg.InsecureIgnoreHostKey:
+		wslog.Warn("The host key of the ssh worker is not verified", "addr", cfg.Addr)
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	return nil, errors.New("ssh hostKey or knownHosts must be defined")
+}
+
+type sshHook struct {
+	addr      string
+	workspace string
+	config    *ssh.ClientConfig
+	states    sync.Map
+}
+
+func (h *sshHook) getState(id string) *sshState {
+	v, ok := h.states.Load(id)
+	if !ok {
+		return nil
+	}
+	return v.(*sshState)
+}
+
+func (h *sshHook) addState(id string, state *sshState) {
+	h.states.Store(id, state)
+}
+
+func (h *sshHook) delState(id string) {
+	h.states.Delete(id)
+}
+
+func (h *sshHook) Begin(ctx context.Context, spec *worker.Workflow) error {
+	log := wslog.FromContext(ctx)
+
+	state := new(sshState)
+	h.addState(spec.ID, state)
+
+	client, err := h.dial(ctx)
+	if err != nil {
+		return err
+	}
+	state.setClient(client)
+
+	scriptPath := path.Join(getHomedir(h.workspace, spec), "scripts")
+	if err := run(client, "mkdir -p "+quote(scriptPath), nil); err != nil {
+		return err
+	}
+
+	for _, step := range spec.Steps {
+		cmdName, args := shell.Command()
+		if len(step.Shell) > 0 {
+			cmdName = step.Shell[0]
+			args = step.Shell[1:]
+		}
+		cmdData := shell.Script(step.Command)
+		stepName := strings.ReplaceAll(step.Name, " ", "_")
+		fp := path.Join(scriptPath, stepName+shell.Suffix)
+		if err := run(client, "cat > "+quote(fp)+" && chmod +x "+quote(fp), strings.NewReader(cmdData)); err != nil {
+			log.Error("cannot write file", "error", err)
+			return err
+		}
+		args = append(args, fp)
+		step.Args = args
+		step.Command = []string{cmdName}
+	}
+	return nil
+}
+
+func (h *sshHook) End(_ context.Context, spec *worker.Workflow) error {
+	state := h.getState(spec.ID)
+	if state == nil {
+		return errors.New("abnormal state")
+	}
+	defer h.delState(spec.ID)
+
+	client := state.getClient()
+	if client == nil {
+		return nil
+	}
+	defer client.Close()
+	return run(client, "rm -rf "+quote(getRootDir(h.workspace, spec)), nil)
+}
+
+func (h *sshHook) Step(ctx context.Context, spec *worker.Workflow, step *worker.Step, writer io.Writer) (*worker.State, error) {
+	if len(step.Command) == 0 {
+		return nil, nil
+	}
+
+	hs := h.getState(spec.ID)
+	if hs == nil || hs.getClient() == nil {
+		return nil, errors.New("abnormal state")
+	}
+
+	homedir := getHomedir(h.workspace, spec)
+	workingDir := path.Join(getRootDir(h.workspace, spec), step.WorkingDir)
+	env := step.CombineEnv(map[string]string{
+		"HOME":          homedir,
+		"INK_HOME":      workingDir,
+		"INK_WORKSPACE": workingDir,
+	})
+
+	// the environments are exported in the command,
+	// because most ssh servers do not accept the env requests.
+	var cmd strings.Builder
+	cmd.WriteString("mkdir -p " + quote(workingDir) + " && cd " + quote(workingDir) + " && ")
+	for _, v := range worker.EnvToSlice(env) {
+		cmd.WriteString("export " + quote(v) + "; ")
+	}
+	cmd.WriteString(quote(step.Command[0]))
+	for _, arg := range step.Args {
+		cmd.WriteString(" " + quote(arg))
+	}
+
+	session, err := hs.getClient().NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+	session.Stdout = writer
+	session.Stderr = writer
+
+	log := wslog.FromContext(ctx)
+	if err := session.Start(cmd.String()); err != nil {
+		return nil, err
+	}
+	log.Debug("remote process started")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		_ = session.Signal(ssh.SIGKILL)
+		_ = session.Close()
+
+		log.Debug("remote process killed")
+		return nil, ctx.Err()
+	}
+
+	state := new(worker.State)
+	if err != nil {
+		state.ExitCode = 255
+	}
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		state.ExitCode = exitErr.ExitStatus()
+	}
+
+	log.Debug("remote process finished", "process.exit", state.ExitCode)
+	return state, nil
+}
+
+func (h *sshHook) dial(ctx context.Context) (*ssh.Client, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", h.addr)
+	if err != nil {
+		return nil, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, h.addr, h.config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// run executes the command in a new session and waits for it to complete.
+func run(client *ssh.Client, cmd string, stdin io.Reader) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	session.Stdin = stdin
+	if out, err := session.CombinedOutput(cmd); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
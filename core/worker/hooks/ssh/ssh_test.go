@@ -0,0 +1,245 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/zc2638/ink/core/worker"
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+)
+
+var noContext = context.Background()
+
+// newTestServer starts an in-process ssh server which executes the commands locally,
+// it returns the address and the host key of the server.
+func newTestServer(t *testing.T, user, password string) (string, ssh.PublicKey) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == user && string(pass) == password {
+				return nil, nil
+			}
+			return nil, errors.New("permission denied")
+		},
+	}
+	config.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveConn(conn, config)
+		}
+	}()
+	return ln.Addr().String(), signer.PublicKey()
+}
+
+func serveConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			_ = nc.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		ch, chReqs, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+		go serveSession(ch, chReqs)
+	}
+}
+
+func serveSession(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer ch.Close()
+
+	for req := range reqs {
+		if req.Type != "exec" {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		var payload struct{ Command string }
+		if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		_ = req.Reply(true, nil)
+
+		cmd := exec.Command("/bin/sh", "-c", payload.Command)
+		cmd.Stdin = ch
+		cmd.Stdout = ch
+		cmd.Stderr = ch.Stderr()
+
+		var status uint32
+		if err := cmd.Run(); err != nil {
+			status = 255
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				status = uint32(exitErr.ExitCode())
+			}
+		}
+		_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+		return
+	}
+}
+
+func TestHook(t *testing.T) {
+	addr, hostKey := newTestServer(t, "ink", "secret")
+	workspace := t.TempDir()
+
+	secret := &v1.Secret{Data: map[string]string{
+		v1.SSHUsernameKey: "ink",
+		v1.SSHPasswordKey: "secret",
+	}}
+	cfg := Config{Addr: addr, Workspace: workspace, HostKey: string(ssh.MarshalAuthorizedKey(hostKey))}
+	hook, err := New(cfg, secret)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	spec := &worker.Workflow{
+		ID:   "ink-1",
+		Name: "test",
+		Steps: []*worker.Step{
+			{ID: "ink-2", Name: "echo", Command: []string{"echo hello $FOO"}, Env: map[string]string{"FOO": "it's"}},
+			{ID: "ink-3", Name: "fail", Command: []string{"exit 3"}},
+		},
+	}
+	worker.Compile(spec)
+
+	if err := hook.Begin(noContext, spec); err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, spec.ID, "home/ink/scripts/echo")); err != nil {
+		t.Errorf("Want script uploaded: %v", err)
+	}
+
+	var buf bytes.Buffer
+	state, err := hook.Step(noContext, spec, spec.Steps[0], &buf)
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if state.ExitCode != 0 {
+		t.Errorf("Want exit code 0, got %d", state.ExitCode)
+	}
+	if !strings.Contains(buf.String(), "hello it's") {
+		t.Errorf("Want output contains %q, got %q", "hello it's", buf.String())
+	}
+
+	state, err = hook.Step(noContext, spec, spec.Steps[1], &buf)
+	if err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if state.ExitCode != 3 {
+		t.Errorf("Want exit code 3, got %d", state.ExitCode)
+	}
+
+	if err := hook.End(noContext, spec); err != nil {
+		t.Fatalf("End failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, spec.ID)); !os.IsNotExist(err) {
+		t.Errorf("Want remote workspace removed")
+	}
+}
+
+func TestNewWithoutCredentials(t *testing.T) {
+	_, err := New(Config{Addr: "127.0.0.1"}, &v1.Secret{})
+	if err == nil {
+		t.Errorf("Want error without credentials")
+	}
+}
+
+func TestHostKey(t *testing.T) {
+	addr, hostKey := newTestServer(t, "ink", "secret")
+	_, otherKey := newTestServer(t, "ink", "secret")
+
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostKey)
+	if err := os.WriteFile(knownHosts, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+		dialErr bool
+	}{
+		{name: "undefined", cfg: Config{}, wantErr: true},
+		{name: "host key", cfg: Config{HostKey: string(ssh.MarshalAuthorizedKey(hostKey))}},
+		{name: "mismatched host key", cfg: Config{HostKey: string(ssh.MarshalAuthorizedKey(otherKey))}, dialErr: true},
+		{name: "invalid host key", cfg: Config{HostKey: "invalid"}, wantErr: true},
+		{name: "known hosts", cfg: Config{KnownHosts: knownHosts}},
+		{name: "missing known hosts", cfg: Config{KnownHosts: filepath.Join(t.TempDir(), "missing")}, wantErr: true},
+		{name: "insecure", cfg: Config{InsecureIgnoreHostKey: true}},
+	}
+	secret := &v1.Secret{Data: map[string]string{
+		v1.SSHUsernameKey: "ink",
+		v1.SSHPasswordKey: "secret",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Addr = addr
+			hook, err := New(tt.cfg, secret)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			client, err := hook.(*sshHook).dial(noContext)
+			if (err != nil) != tt.dialErr {
+				t.Fatalf("dial() error = %v, dialErr %v", err, tt.dialErr)
+			}
+			if client != nil {
+				client.Close()
+			}
+		})
+	}
+}
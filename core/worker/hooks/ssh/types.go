@@ -0,0 +1,76 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"path"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/zc2638/ink/core/worker"
+)
+
+const defaultWorkspace = "/tmp"
+
+type Config struct {
+	// Addr defines the address of the remote host, the default port is 22.
+	Addr string `json:"addr"`
+	// Workspace defines the remote directory where the stages run.
+	Workspace string `json:"workspace,omitempty"`
+	// HostKey defines the public key of the remote host in the authorized_keys format.
+	HostKey string `json:"hostKey,omitempty"`
+	// KnownHosts defines the path of the known_hosts file to verify the remote host,
+	// it is used when the HostKey is empty.
+	KnownHosts string `json:"knownHosts,omitempty"`
+	// InsecureIgnoreHostKey skips the verification of the remote host,
+	// it only takes effect when neither HostKey nor KnownHosts is defined.
+	InsecureIgnoreHostKey bool `json:"insecureIgnoreHostKey,omitempty"`
+	// SecretFile defines the manifest file of the secret which holds the credentials.
+	SecretFile string `json:"secretFile"`
+	// SecretName defines the name of the secret if the file contains multiple secrets.
+	SecretName string `json:"secretName,omitempty"`
+}
+
+type sshState struct {
+	mux    sync.Mutex
+	client *ssh.Client
+}
+
+func (s *sshState) getClient() *ssh.Client {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.client
+}
+
+func (s *sshState) setClient(client *ssh.Client) {
+	s.mux.Lock()
+	s.client = client
+	s.mux.Unlock()
+}
+
+func getRootDir(workspace string, spec *worker.Workflow) string {
+	return path.Join(workspace, spec.ID)
+}
+
+func getHomedir(workspace string, spec *worker.Workflow) string {
+	return path.Join(getRootDir(workspace, spec), "/home/ink")
+}
+
+// quote returns the single-quoted string for the posix shell.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -16,15 +16,23 @@ package worker
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"maps"
+	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/99nil/gopkg/cycle"
 
 	"github.com/zc2638/ink/core/constant"
 	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+	"github.com/zc2638/ink/pkg/selector"
 )
 
 type State struct {
@@ -39,11 +47,13 @@ type Workflow struct {
 	Labels    map[string]string
 
 	Steps       []*Step
+	Services    []*Step
 	WorkingDir  string
 	Concurrency int
 	Volumes     []Volume
 	DependsOn   []string
 	Worker      *v1.Worker
+	Timeout     time.Duration
 }
 
 func (s *Workflow) GetStep(name string) *Step {
@@ -58,6 +68,29 @@ func (s *Workflow) GetStep(name string) *Step {
 	return nil
 }
 
+// Ancestors returns the names of the steps which the step depends on directly or indirectly.
+func (s *Workflow) Ancestors(name string) []string {
+	var out []string
+	seen := map[string]bool{name: true}
+	queue := []string{name}
+	for len(queue) > 0 {
+		step := s.GetStep(queue[0])
+		queue = queue[1:]
+		if step == nil {
+			continue
+		}
+		for _, dep := range step.DependsOn {
+			if seen[dep] {
+				continue
+			}
+			seen[dep] = true
+			out = append(out, dep)
+			queue = append(queue, dep)
+		}
+	}
+	return out
+}
+
 type (
 	Volume struct {
 		v1.Volume
@@ -90,6 +123,18 @@ type Step struct {
 	Args            []string
 	VolumeMounts    []v1.VolumeMount
 	Devices         []v1.VolumeDevice
+	When            *selector.Selector
+	RunPolicy       v1.RunPolicy
+	DependsOn       []string
+	Timeout         time.Duration
+	Retry           *v1.Retry
+	Artifacts       []string
+}
+
+// ShouldRun returns true if the step matches the build settings
+// and the result of the previous steps.
+func (s *Step) ShouldRun(settings map[string]string, failed bool) bool {
+	return s.When.Match(settings) && s.RunPolicy.Match(failed)
 }
 
 func (s *Step) CombineEnv(env ...any) map[string]string {
@@ -114,11 +159,56 @@ func (s *Step) CombineEnv(env ...any) map[string]string {
 	return out
 }
 
+// MatchArtifact returns true if the slash separated name relative to the working directory
+// matches any of the artifact patterns, all files under the matched directory are matched.
+func MatchArtifact(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		pattern = path.Clean(pattern)
+		for n := name; n != "." && n != "/"; n = path.Dir(n) {
+			if ok, _ := path.Match(pattern, n); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ArtifactRoots returns the slash separated paths which contain all the files
+// matched by the artifact patterns, they are the non-glob prefixes of the patterns
+// relative to the working directory, "." means the whole working directory.
+func ArtifactRoots(patterns []string) []string {
+	roots := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		var parts []string
+		for _, part := range strings.Split(path.Clean(pattern), "/") {
+			if strings.ContainsAny(part, `*?[\`) {
+				break
+			}
+			parts = append(parts, part)
+		}
+		roots = append(roots, path.Join(append([]string{"."}, parts...)...))
+	}
+	slices.Sort(roots)
+	roots = slices.Compact(roots)
+
+	// the roots under the others are already covered.
+	out := make([]string, 0, len(roots))
+	for _, root := range roots {
+		covered := slices.ContainsFunc(roots, func(other string) bool {
+			return other != root && (other == "." || strings.HasPrefix(root, other+"/"))
+		})
+		if !covered {
+			out = append(out, root)
+		}
+	}
+	return out
+}
+
 func completeID(id string) string {
 	return constant.Name + "-" + id
 }
 
-func Convert(in *v1.Workflow, status *v1.Stage, secrets []*v1.Secret) (*Workflow, error) {
+func Convert(in *v1.Workflow, status *v1.Stage, secrets []*v1.Secret, settings map[string]string) (*Workflow, error) {
 	out := &Workflow{
 		ID:          completeID(strconv.FormatUint(status.ID, 10)),
 		Name:        in.Name,
@@ -128,6 +218,7 @@ func Convert(in *v1.Workflow, status *v1.Stage, secrets []*v1.Secret) (*Workflow
 		Concurrency: in.Spec.Concurrency,
 		DependsOn:   in.Spec.DependsOn,
 		Worker:      in.Spec.Worker,
+		Timeout:     in.Spec.Timeout.Std(),
 	}
 
 	for _, v := range in.Spec.Volumes {
@@ -144,79 +235,263 @@ func Convert(in *v1.Workflow, status *v1.Stage, secrets []*v1.Secret) (*Workflow
 		}
 	}
 
-	for _, v := range in.Spec.Steps {
-		var id string
-		for _, vv := range status.Steps {
-			if vv.Name == v.Name {
-				id = strconv.FormatUint(vv.ID, 10)
-				break
+	stepID := func(name string) (string, error) {
+		for _, v := range status.Steps {
+			if v.Name == name {
+				return completeID(strconv.FormatUint(v.ID, 10)), nil
 			}
 		}
-		if id == "" {
-			return nil, fmt.Errorf("step not found: %s", v.Name)
+		return "", fmt.Errorf("step not found: %s", name)
+	}
+
+	if in.Spec.Source != nil {
+		id, err := stepID(v1.SourceStepName)
+		if err != nil {
+			return nil, err
+		}
+		step, err := convertSource(in.Spec.Source, id, secrets, settings)
+		if err != nil {
+			return nil, err
 		}
+		out.Steps = append(out.Steps, step)
+	}
 
-		step := &Step{
-			ID:              completeID(id),
-			Name:            v.Name,
-			Image:           v.Image,
-			ImagePullPolicy: v.ImagePullPolicy,
-			Privileged:      v.Privileged,
-			WorkingDir:      v.WorkingDir,
-			Entrypoint:      v.Entrypoint,
-			Shell:           v.Shell,
-			Command:         v.Command,
-			Args:            v.Args,
-			VolumeMounts:    v.VolumeMounts,
-			Devices:         v.Devices,
-			DNS:             v.DNS,
-			DNSSearch:       v.DNSSearch,
-			ExtraHosts:      v.ExtraHosts,
+	for _, v := range in.Spec.Steps {
+		id, err := stepID(v.Name)
+		if err != nil {
+			return nil, err
 		}
 
-		// image registry auth
-		for _, sv := range imagePullSecrets {
-			_ = sv.Decrypt()
-			dockerAuthsData, ok := sv.Data[v1.DockerConfigJSONKey]
-			if !ok {
-				continue
-			}
+		step := convertFlow(&v, id, imagePullSecrets, secrets)
+		out.Steps = append(out.Steps, step)
+	}
 
-			var dockerAuths v1.DockerAuths
-			if err := json.Unmarshal([]byte(dockerAuthsData), &dockerAuths); err != nil {
-				continue
+	// the steps run as a graph if any of them defines the dependencies,
+	// the steps without dependencies must wait for the clone in this case.
+	if in.Spec.Source != nil && slices.ContainsFunc(out.Steps, func(step *Step) bool {
+		return len(step.DependsOn) > 0
+	}) {
+		for _, step := range out.Steps[1:] {
+			if len(step.DependsOn) == 0 {
+				step.DependsOn = []string{v1.SourceStepName}
 			}
-			step.ImagePullAuth = dockerAuths.Match(v.Image)
-			break
 		}
+	}
 
-		env := make(map[string]string)
-		for _, ev := range v.Env {
-			if ev.Name == "" {
-				continue
+	for i, v := range in.Spec.Services {
+		id := fmt.Sprintf("%s-service-%d", out.ID, i)
+		out.Services = append(out.Services, convertFlow(&v, id, imagePullSecrets, secrets))
+	}
+
+	// the stage expanded by the matrix runs on the worker of its combination,
+	// the values of the combination are expanded in the images and are the defaults of the env.
+	if len(status.Matrix) > 0 {
+		worker := status.Worker
+		out.Worker = &worker
+		expand := func(k string) string {
+			if v, ok := status.Matrix[k]; ok {
+				return v
 			}
-			if ev.Value != "" {
-				env[ev.Name] = ev.Value
-				continue
+			return "${" + k + "}"
+		}
+		for _, step := range append(slices.Clone(out.Steps), out.Services...) {
+			step.Image = os.Expand(step.Image, expand)
+			if step.Env == nil {
+				step.Env = make(map[string]string)
 			}
-			if ev.ValueFrom != nil {
-				// secret to env
-				if ev.ValueFrom.SecretKeyRef != nil {
-					_, secData := ev.ValueFrom.SecretKeyRef.Find(secrets)
-					env[ev.Name] = secData
+			for k, v := range status.Matrix {
+				if _, ok := step.Env[k]; !ok {
+					step.Env[k] = v
 				}
 			}
 		}
-		if len(env) > 0 {
-			step.Env = env
+	}
+
+	// the services are only started by the docker worker.
+	if len(out.Services) > 0 && status.Worker.Kind != "" && status.Worker.Kind != v1.WorkerKindDocker {
+		return nil, fmt.Errorf("services are not supported by the %s worker", status.Worker.Kind)
+	}
+	// the containers of the kubernetes pod cannot be restarted.
+	if status.Worker.Kind == v1.WorkerKindKubernetes {
+		for _, step := range out.Steps {
+			if step.Retry != nil {
+				return nil, fmt.Errorf("step(%s): retry is not supported by the kubernetes worker", step.Name)
+			}
 		}
-		out.Steps = append(out.Steps, step)
 	}
 
 	Compile(out)
+
+	graph := cycle.New()
+	for _, step := range out.Steps {
+		graph.Add(step.Name, step.DependsOn...)
+	}
+	if graph.DetectCycles() {
+		return nil, errors.New("dependency cycle detected in steps")
+	}
 	return out, nil
 }
 
+func convertFlow(v *v1.Flow, id string, imagePullSecrets, secrets []*v1.Secret) *Step {
+	step := &Step{
+		ID:              id,
+		Name:            v.Name,
+		Image:           v.Image,
+		ImagePullPolicy: v.ImagePullPolicy,
+		Privileged:      v.Privileged,
+		WorkingDir:      v.WorkingDir,
+		Entrypoint:      v.Entrypoint,
+		Shell:           v.Shell,
+		Command:         v.Command,
+		Args:            v.Args,
+		VolumeMounts:    v.VolumeMounts,
+		Devices:         v.Devices,
+		DNS:             v.DNS,
+		DNSSearch:       v.DNSSearch,
+		ExtraHosts:      v.ExtraHosts,
+		When:            v.When,
+		RunPolicy:       v.RunPolicy,
+		DependsOn:       v.DependsOn,
+		Timeout:         v.Timeout.Std(),
+		Retry:           v.Retry,
+		Artifacts:       v.Artifacts,
+	}
+
+	// image registry auth
+	for _, sv := range imagePullSecrets {
+		_ = sv.Decrypt()
+		dockerAuthsData, ok := sv.Data[v1.DockerConfigJSONKey]
+		if !ok {
+			continue
+		}
+
+		var dockerAuths v1.DockerAuths
+		if err := json.Unmarshal([]byte(dockerAuthsData), &dockerAuths); err != nil {
+			continue
+		}
+		step.ImagePullAuth = dockerAuths.Match(v.Image)
+		break
+	}
+
+	env := make(map[string]string)
+	for _, ev := range v.Env {
+		if ev.Name == "" {
+			continue
+		}
+		if ev.Value != "" {
+			env[ev.Name] = ev.Value
+			continue
+		}
+		if ev.ValueFrom != nil {
+			// secret to env
+			if ev.ValueFrom.SecretKeyRef != nil {
+				_, secData := ev.ValueFrom.SecretKeyRef.Find(secrets)
+				env[ev.Name] = secData
+			}
+		}
+	}
+	if len(env) > 0 {
+		step.Env = env
+	}
+	return step
+}
+
+// DefaultSourceImage is the image used to clone the source in the container.
+const DefaultSourceImage = "alpine/git:2.45.2"
+
+// convertSource returns the step clones the source into the working directory.
+func convertSource(source *v1.Source, id string, secrets []*v1.Secret, settings map[string]string) (*Step, error) {
+	ref := os.Expand(source.Ref, func(k string) string { return settings[k] })
+	if ref == "" {
+		ref = settings["DYNASTY_COMMIT_SHA"]
+	}
+	if ref == "" {
+		ref = settings["DYNASTY_COMMIT_REF"]
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	step := &Step{
+		ID:    id,
+		Name:  v1.SourceStepName,
+		Image: source.Image,
+		Env: map[string]string{
+			"DYNASTY_SOURCE_URL": os.Expand(source.URL, func(k string) string { return settings[k] }),
+			"DYNASTY_SOURCE_REF": ref,
+		},
+	}
+	if step.Image == "" {
+		step.Image = DefaultSourceImage
+	}
+
+	commands := []string{
+		"git init -q",
+		`git remote add origin "$DYNASTY_SOURCE_URL" 2>/dev/null || git remote set-url origin "$DYNASTY_SOURCE_URL"`,
+	}
+	if source.Secret != "" {
+		var secret *v1.Secret
+		for _, v := range secrets {
+			if v.Name == source.Secret {
+				secret = v
+				break
+			}
+		}
+		if secret == nil {
+			return nil, fmt.Errorf("source secret not found: %s", source.Secret)
+		}
+		if err := secret.Decrypt(); err != nil {
+			return nil, fmt.Errorf("decrypt source secret failed: %v", err)
+		}
+
+		// the credentials are passed by env, so that they are never written into the repository config
+		if privateKey := secret.Data[v1.SSHPrivateKeyKey]; privateKey != "" {
+			// the clone container is fresh every time, so the host key
+			// is only verified by the known hosts of the secret.
+			knownHosts := secret.Data[v1.SSHKnownHostsKey]
+			if knownHosts == "" && !source.InsecureIgnoreHostKey {
+				return nil, fmt.Errorf("source secret(%s) %s must be defined for ssh", source.Secret, v1.SSHKnownHostsKey)
+			}
+			hostKeyOptions := "-o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null"
+			if knownHosts != "" {
+				hostKeyOptions = "-o StrictHostKeyChecking=yes -o UserKnownHostsFile=$DYNASTY_SOURCE_KNOWN_HOSTS_FILE"
+			}
+
+			step.Env["DYNASTY_SOURCE_PRIVATE_KEY"] = privateKey
+			step.Env["DYNASTY_SOURCE_KNOWN_HOSTS"] = knownHosts
+			commands = append(commands,
+				`DYNASTY_SOURCE_KEY_FILE="$(mktemp)"`,
+				`DYNASTY_SOURCE_KNOWN_HOSTS_FILE="$(mktemp)"`,
+				`trap 'rm -f "$DYNASTY_SOURCE_KEY_FILE" "$DYNASTY_SOURCE_KNOWN_HOSTS_FILE"' EXIT`,
+				`printf '%s\n' "$DYNASTY_SOURCE_PRIVATE_KEY" > "$DYNASTY_SOURCE_KEY_FILE"`,
+				`printf '%s\n' "$DYNASTY_SOURCE_KNOWN_HOSTS" > "$DYNASTY_SOURCE_KNOWN_HOSTS_FILE"`,
+				`export GIT_SSH_COMMAND="ssh -i $DYNASTY_SOURCE_KEY_FILE -o IdentitiesOnly=yes `+hostKeyOptions+`"`,
+			)
+		} else {
+			step.Env["DYNASTY_SOURCE_USERNAME"] = secret.Data[v1.SSHUsernameKey]
+			step.Env["DYNASTY_SOURCE_PASSWORD"] = secret.Data[v1.SSHPasswordKey]
+			commands = append(commands,
+				`git config credential.helper '!f() { echo "username=$DYNASTY_SOURCE_USERNAME"; echo "password=$DYNASTY_SOURCE_PASSWORD"; }; f'`,
+			)
+		}
+	}
+
+	depth := ""
+	if source.Depth > 0 {
+		depth = " --depth=" + strconv.Itoa(source.Depth)
+	}
+	commands = append(commands,
+		`git fetch -q`+depth+` origin "$DYNASTY_SOURCE_REF"`,
+		"git checkout -q -f FETCH_HEAD",
+	)
+	if source.Submodules {
+		commands = append(commands, "git submodule update -q --init --recursive"+depth)
+	}
+	commands = append(commands, "git log -1 --format='%H %s'")
+	step.Command = commands
+	return step, nil
+}
+
 func Compile(spec *Workflow) {
 	if len(spec.WorkingDir) == 0 {
 		spec.WorkingDir = constant.WorkspacePath
@@ -238,7 +513,22 @@ func Compile(spec *Workflow) {
 	}
 	spec.Volumes = append([]Volume{volume}, spec.Volumes...)
 
+	// run the steps serially in the defined order
+	// if none of them declares the dependencies.
+	serial := true
 	for _, s := range spec.Steps {
+		if len(s.DependsOn) > 0 {
+			serial = false
+			break
+		}
+	}
+	if serial {
+		for i := 1; i < len(spec.Steps); i++ {
+			spec.Steps[i].DependsOn = []string{spec.Steps[i-1].Name}
+		}
+	}
+
+	for _, s := range append(slices.Clone(spec.Steps), spec.Services...) {
 		switch s.ImagePullPolicy {
 		case v1.PullAlways, v1.PullNever, v1.PullIfNotPresent:
 		default:
@@ -20,6 +20,9 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"os"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/99nil/gopkg/sets"
@@ -30,6 +33,7 @@ import (
 	"github.com/zc2638/ink/core/constant"
 	"github.com/zc2638/ink/core/worker/runtime"
 	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+	"github.com/zc2638/ink/pkg/cache"
 	"github.com/zc2638/ink/pkg/livelog"
 )
 
@@ -39,6 +43,31 @@ type Hook interface {
 	Step(ctx context.Context, spec *Workflow, step *Step, writer io.Writer) (*State, error)
 }
 
+// ArtifactFunc handles the collected artifact file,
+// the name is the slash separated path relative to the working directory of the step.
+type ArtifactFunc func(name string, r io.Reader) error
+
+// ArtifactHook is implemented by the hooks that are able to collect the artifacts of the steps.
+type ArtifactHook interface {
+	Artifacts(ctx context.Context, spec *Workflow, step *Step, fn ArtifactFunc) error
+}
+
+type serviceLogKey struct{}
+
+// WithServiceLog returns a copy of ctx carrying the writer of the output of the stage services.
+func WithServiceLog(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, serviceLogKey{}, w)
+}
+
+// ServiceLogFromContext returns the writer of the output of the stage services,
+// the output is discarded if the writer is not defined.
+func ServiceLogFromContext(ctx context.Context) io.Writer {
+	if w, ok := ctx.Value(serviceLogKey{}).(io.Writer); ok {
+		return w
+	}
+	return io.Discard
+}
+
 func NewMust(client clients.Worker, hook Hook, log *wslog.Logger, count int) *Worker {
 	w, err := New(client, hook, log, count)
 	if err != nil {
@@ -194,12 +223,23 @@ func execute(
 	log := wslog.FromContext(ctx)
 	log.Debug("Execute stage begin request")
 
-	spec, err := Convert(workflow, status, secrets)
+	status.Started = time.Now().Unix()
+	spec, err := Convert(workflow, status, secrets, settings)
 	if err != nil {
-		return fmt.Errorf("convert to worker stage failed: %v", err)
+		// the stage is unable to run on the worker, e.g. the services on the host worker.
+		status.Phase = v1.PhaseFailed
+		status.Error = fmt.Sprintf("convert to worker stage failed: %v", err)
+		status.Stopped = status.Started
+		for _, step := range status.Steps {
+			step.Phase = v1.PhaseSkipped
+			step.Started = status.Started
+		}
+		if err := client.StageEnd(ctx, status); err != nil {
+			return fmt.Errorf("stage end failed: %v", err)
+		}
+		return nil
 	}
 
-	status.Started = time.Now().Unix()
 	if !workflow.Spec.When.Match(settings) {
 		status.Phase = v1.PhaseSkipped
 		for _, step := range status.Steps {
@@ -233,22 +273,66 @@ func execute(
 	secretValueList := secretValueSet.List()
 
 	var (
+		mux      sync.Mutex
 		failed   bool
 		canceled bool
+		aborted  bool
+		// failedSet records the failed steps, the run policy of a step
+		// only depends on the results of its own ancestors.
+		failedSet = make(map[string]bool)
 	)
 	status.Phase = v1.PhaseRunning
 	if err := client.StageBegin(ctx, status); err != nil {
 		return fmt.Errorf("stage begin request failed: %v", err)
 	}
 
+	for _, service := range spec.Services {
+		service.Env = service.CombineEnv(settings)
+	}
+
+	// the hooks are limited by the timeout of the workflow,
+	// but the requests to the server and the cleanup are not.
+	hookCtx := ctx
+	if spec.Timeout > 0 {
+		var hookCancel context.CancelFunc
+		hookCtx, hookCancel = context.WithTimeout(ctx, spec.Timeout)
+		defer hookCancel()
+	}
+
+	// the output of the services is streamed to the live log of the stage.
+	var serviceLog *serviceLogWriter
+	if len(spec.Services) > 0 {
+		wc := livelog.NewWriter(func(lines []*livelog.Line, isAll bool) {
+			if len(lines) == 0 {
+				return
+			}
+			if err := client.ServiceLogUpload(ctx, status.ID, lines, isAll); err != nil {
+				if errors.Is(ctx.Err(), context.Canceled) {
+					return
+				}
+				log.Error("Upload service log failed", "error", err)
+			}
+		})
+		serviceLog = &serviceLogWriter{wc: runtime.NewMaskReplacer(wc, secretValueList)}
+		defer serviceLog.Close()
+		hookCtx = WithServiceLog(hookCtx, serviceLog)
+	}
+
 	log.Debug("Execute stage begin hook")
-	if err := hook.Begin(ctx, spec); err != nil {
+	releaseCaches, err := acquireCaches(ctx, spec, settings)
+	if err == nil {
+		defer releaseCaches()
+		err = hook.Begin(hookCtx, spec)
+	}
+	if err != nil {
 		failed = true
+		// no step can run without the environment prepared by the hook.
+		aborted = true
 		status.Error = err.Error()
 		log.Error("Execute stage begin hook failed", "error", err)
 	}
 
-	for _, step := range status.Steps {
+	runStep := func(step *v1.Step) error {
 		stepSpec := spec.GetStep(step.Name)
 		if stepSpec != nil {
 			stepSpec.Env = stepSpec.CombineEnv(settings)
@@ -259,8 +343,21 @@ func execute(
 			"step_name", step.Name,
 		)
 
+		mux.Lock()
+		depFailed := slices.ContainsFunc(spec.Ancestors(step.Name), func(name string) bool {
+			return failedSet[name]
+		})
+		// the pending steps end with the stage once it is canceled or timed out,
+		// regardless of their run policies.
+		ctxErr := hookCtx.Err()
+		timedOut := stepSpec != nil && errors.Is(ctxErr, context.DeadlineExceeded)
+		isCanceled := stepSpec != nil && !timedOut && (canceled || ctxErr != nil)
+		skipped := !timedOut && !isCanceled &&
+			(stepSpec == nil || aborted || !stepSpec.ShouldRun(settings, depFailed))
+		mux.Unlock()
+
 		step.Started = time.Now().Unix()
-		if stepSpec == nil || failed {
+		if skipped {
 			step.Phase = v1.PhaseSkipped
 			step.Stopped = step.Started
 
@@ -268,17 +365,30 @@ func execute(
 			if err := client.StepEnd(ctx, step); err != nil {
 				return fmt.Errorf("step(%s) end request failed: %v", step.Name, err)
 			}
-			continue
+			return nil
 		}
 
-		if canceled {
+		if timedOut {
+			step.Phase = v1.PhaseTimedOut
+			step.Error = fmt.Sprintf("timed out after %s", spec.Timeout)
+			step.Stopped = step.Started
+
+			stepLog.Debug("Execute step end request by timed out")
+			if err := client.StepEnd(ctx, step); err != nil {
+				return fmt.Errorf("step(%s) end request failed: %v", step.Name, err)
+			}
+			return nil
+		}
+
+		if isCanceled {
 			step.Phase = v1.PhaseCanceled
+			step.Stopped = step.Started
 
 			stepLog.Debug("Execute step end request by canceled")
 			if err := client.StepEnd(ctx, step); err != nil {
 				return fmt.Errorf("step(%s) end request failed: %v", step.Name, err)
 			}
-			continue
+			return nil
 		}
 		step.Phase = v1.PhaseRunning
 
@@ -302,13 +412,60 @@ func execute(
 		wc := livelog.NewWriter(logHandle)
 		wc = runtime.NewMaskReplacer(wc, secretValueList)
 
+		stepCtx := hookCtx
+		if stepSpec.Timeout > 0 {
+			var stepCancel context.CancelFunc
+			stepCtx, stepCancel = context.WithTimeout(hookCtx, stepSpec.Timeout)
+			defer stepCancel()
+		}
+
 		stepLog.Debug("Execute step hook")
-		state, err := hook.Step(ctx, spec, stepSpec, wc)
+		state, err := hook.Step(stepCtx, spec, stepSpec, wc)
+		for attempt := 1; shouldRetry(stepCtx, stepSpec.Retry, attempt, state, err); attempt++ {
+			delay := stepSpec.Retry.Delay(attempt)
+			_, _ = fmt.Fprintf(wc, "[retry] attempt %d/%d in %s\n", attempt+1, stepSpec.Retry.Count+1, delay)
+			stepLog.Debug("Retry step hook", "attempt", attempt+1, "delay", delay)
+
+			select {
+			case <-stepCtx.Done():
+			case <-time.After(delay):
+			}
+			if stepCtx.Err() != nil {
+				state, err = nil, stepCtx.Err()
+				break
+			}
+			state, err = hook.Step(stepCtx, spec, stepSpec, wc)
+		}
+
+		if ah, ok := hook.(ArtifactHook); ok && state != nil && len(stepSpec.Artifacts) > 0 {
+			stepLog.Debug("Collect step artifacts")
+			err := ah.Artifacts(hookCtx, spec, stepSpec, func(name string, r io.Reader) error {
+				_, _ = fmt.Fprintf(wc, "[artifact] upload %s\n", name)
+				return client.ArtifactUpload(hookCtx, step.ID, name, r)
+			})
+			if err != nil {
+				_, _ = fmt.Fprintf(wc, "[artifact] collect failed: %v\n", err)
+				stepLog.Error("Collect step artifacts failed", "error", err)
+			}
+		}
 		_ = wc.Close()
 
+		mux.Lock()
 		step.Phase = v1.PhaseSucceeded
 		step.Stopped = time.Now().Unix()
-		if errors.Is(err, context.Canceled) {
+		if errors.Is(stepCtx.Err(), context.DeadlineExceeded) {
+			timeout := stepSpec.Timeout
+			if errors.Is(hookCtx.Err(), context.DeadlineExceeded) {
+				timeout = spec.Timeout
+			}
+			step.Phase = v1.PhaseTimedOut
+			step.Error = fmt.Sprintf("timed out after %s", timeout)
+			failed = true
+			failedSet[step.Name] = true
+			// the state of the killed step is meaningless.
+			state = nil
+			stepLog.Error("Execute step hook timed out", "timeout", timeout)
+		} else if errors.Is(err, context.Canceled) {
 			step.Phase = v1.PhaseCanceled
 			canceled = true
 			stepLog.Debug("Execute step hook cancel")
@@ -316,6 +473,7 @@ func execute(
 			step.Phase = v1.PhaseFailed
 			step.Error = err.Error()
 			failed = true
+			failedSet[step.Name] = true
 			stepLog.Error("Execute step hook failed")
 		}
 
@@ -326,34 +484,83 @@ func execute(
 			} else {
 				stepLog.Debugf("received exit code %d", state.ExitCode)
 			}
+			step.ExitCode = state.ExitCode
 			// if the exit code is 78, the system will skip all
 			// subsequent pending steps in the pipeline.
 			if state.ExitCode == 78 {
 				stepLog.Debug("received exit code 78. early exit.")
 				step.Phase = v1.PhaseSkipped
 				failed = true
+				failedSet[step.Name] = true
+				aborted = true
 			} else if state.ExitCode > 0 {
 				step.Phase = v1.PhaseFailed
 				failed = true
+				failedSet[step.Name] = true
 			}
 		}
+		mux.Unlock()
 
 		stepLog.Debug("Execute step end request")
 		if err := client.StepEnd(ctx, step); err != nil {
 			return fmt.Errorf("step(%s) end request failed: %v", step.Name, err)
 		}
+		return nil
+	}
+
+	// the steps run as soon as their dependencies are completed,
+	// the number of concurrently running steps is limited by the concurrency.
+	var limit chan struct{}
+	if spec.Concurrency > 0 {
+		limit = make(chan struct{}, spec.Concurrency)
+	}
+	doneSet := make(map[string]chan struct{}, len(status.Steps))
+	for _, step := range status.Steps {
+		doneSet[step.Name] = make(chan struct{})
+	}
+
+	var stepEg errgroup.Group
+	for _, step := range status.Steps {
+		step := step
+		stepEg.Go(func() error {
+			defer close(doneSet[step.Name])
+
+			if stepSpec := spec.GetStep(step.Name); stepSpec != nil {
+				for _, dep := range stepSpec.DependsOn {
+					if ch, ok := doneSet[dep]; ok {
+						<-ch
+					}
+				}
+			}
+			if limit != nil {
+				limit <- struct{}{}
+				defer func() { <-limit }()
+			}
+			return runStep(step)
+		})
+	}
+	if err := stepEg.Wait(); err != nil {
+		return err
 	}
 
 	log.Debug("Execute stage end hook")
 	if err := hook.End(ctx, spec); err != nil {
 		log.Error("Execute stage end hook failed", "error", err)
 	}
+	// flush the output of the services before the stage ends.
+	if serviceLog != nil {
+		_ = serviceLog.Close()
+	}
 
 	status.Stopped = time.Now().Unix()
 	status.Phase = v1.PhaseSucceeded
 	if failed {
 		status.Phase = v1.PhaseFailed
 	}
+	if errors.Is(hookCtx.Err(), context.DeadlineExceeded) {
+		status.Phase = v1.PhaseTimedOut
+		status.Error = fmt.Sprintf("timed out after %s", spec.Timeout)
+	}
 
 	log.Debug("Execute stage end request")
 	if err := client.StageEnd(ctx, status); err != nil {
@@ -362,6 +569,125 @@ func execute(
 	return nil
 }
 
+// serviceLogWriter serializes the output of the services,
+// the output after closing is dropped.
+type serviceLogWriter struct {
+	mux    sync.Mutex
+	wc     io.WriteCloser
+	closed bool
+}
+
+func (w *serviceLogWriter) Write(p []byte) (int, error) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.closed {
+		return len(p), nil
+	}
+	return w.wc.Write(p)
+}
+
+func (w *serviceLogWriter) Close() error {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.wc.Close()
+}
+
+// acquireCaches binds the cache volumes to the directories kept by the cache manager,
+// the returned function releases the caches and evicts the stale ones.
+// The cache volumes fall back to the empty dir if the worker does not support the caches.
+func acquireCaches(ctx context.Context, spec *Workflow, settings map[string]string) (func(), error) {
+	log := wslog.FromContext(ctx)
+	manager := cache.FromContext(ctx)
+
+	supported := manager != nil
+	if spec.Worker != nil {
+		switch spec.Worker.Kind {
+		case "", v1.WorkerKindDocker, v1.WorkerKindHost:
+		default:
+			supported = false
+		}
+	}
+
+	namespace := settings["DYNASTY_BOX_NAMESPACE"]
+	if len(namespace) == 0 {
+		namespace = spec.Namespace
+	}
+	box := settings["DYNASTY_BOX_NAME"]
+
+	var ids []string
+	release := func() {
+		for _, id := range ids {
+			if err := manager.Release(id); err != nil {
+				log.Error("Release cache failed", "id", id, "error", err)
+			}
+		}
+		if len(ids) == 0 {
+			return
+		}
+		evicted, err := manager.Evict()
+		if err != nil {
+			log.Error("Evict caches failed", "error", err)
+		}
+		for _, c := range evicted {
+			log.Debug("Evict cache", "id", c.ID, "namespace", c.Namespace, "box", c.Box, "volume", c.Volume)
+		}
+	}
+
+	for i := range spec.Volumes {
+		v := &spec.Volumes[i]
+		if v.Cache == nil {
+			continue
+		}
+		if !supported {
+			v.ID = fmt.Sprintf("%s-cache-%d", spec.ID, i)
+			v.EmptyDir = &v1.EmptyDirVolume{}
+			continue
+		}
+
+		c := &cache.Cache{
+			Namespace: namespace,
+			Box:       box,
+			Volume:    v.Name,
+			Key:       os.Expand(v.Cache.Key, func(k string) string { return settings[k] }),
+		}
+		dir, err := manager.Acquire(c)
+		if err != nil {
+			release()
+			return nil, fmt.Errorf("acquire cache(%s) failed: %v", v.Name, err)
+		}
+		ids = append(ids, c.ID)
+		v.HostPath = &v1.HostPathVolume{Path: dir}
+	}
+	return release, nil
+}
+
+// shouldRetry returns true if the failed step is allowed to be retried.
+func shouldRetry(ctx context.Context, retry *v1.Retry, attempt int, state *State, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	exitCode := -1
+	if err == nil {
+		if state == nil {
+			return false
+		}
+		exitCode = state.ExitCode
+		if state.OOMKilled {
+			exitCode = 137
+		}
+		// the exit code 78 is used to skip the subsequent steps.
+		if exitCode == 0 || exitCode == 78 {
+			return false
+		}
+	}
+	return retry.Match(attempt-1, exitCode)
+}
+
 func cancel(ctx context.Context, client clients.WorkerV1, status *v1.Stage) error {
 	if status.Phase.IsDone() {
 		return nil
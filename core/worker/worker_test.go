@@ -0,0 +1,313 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worker
+
+import (
+	"context"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zc2638/ink/core/clients"
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+	"github.com/zc2638/ink/pkg/livelog"
+)
+
+type fakeClient struct {
+	clients.WorkerV1
+
+	mux    sync.Mutex
+	phases map[string]v1.Phase
+}
+
+func (c *fakeClient) StageBegin(context.Context, *v1.Stage) error { return nil }
+
+func (c *fakeClient) StageEnd(context.Context, *v1.Stage) error { return nil }
+
+func (c *fakeClient) StepBegin(context.Context, *v1.Step) error { return nil }
+
+func (c *fakeClient) StepEnd(_ context.Context, step *v1.Step) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.phases[step.Name] = step.Phase
+	return nil
+}
+
+func (c *fakeClient) LogUpload(context.Context, uint64, []*livelog.Line, bool) error { return nil }
+
+// fakeHook exits with the code defined by the step name.
+type fakeHook struct {
+	exitCodes map[string]int
+	// block holds the step of the name until the context is done,
+	// the cancel is called when it starts.
+	block  string
+	cancel context.CancelFunc
+}
+
+func (h *fakeHook) Begin(context.Context, *Workflow) error { return nil }
+
+func (h *fakeHook) End(context.Context, *Workflow) error { return nil }
+
+func (h *fakeHook) Step(ctx context.Context, _ *Workflow, step *Step, _ io.Writer) (*State, error) {
+	if step.Name == h.block {
+		if h.cancel != nil {
+			h.cancel()
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return &State{ExitCode: h.exitCodes[step.Name]}, nil
+}
+
+func TestExecuteRunPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		steps     []v1.Flow
+		exitCodes map[string]int
+		want      map[string]v1.Phase
+	}{
+		{
+			name: "serial",
+			steps: []v1.Flow{
+				{Name: "build"},
+				{Name: "test"},
+				{Name: "notify", RunPolicy: v1.RunOnFailure},
+				{Name: "cleanup", RunPolicy: v1.RunAlways},
+			},
+			exitCodes: map[string]int{"build": 1},
+			want: map[string]v1.Phase{
+				"build":   v1.PhaseFailed,
+				"test":    v1.PhaseSkipped,
+				"notify":  v1.PhaseSucceeded,
+				"cleanup": v1.PhaseSucceeded,
+			},
+		},
+		{
+			name: "branches",
+			steps: []v1.Flow{
+				{Name: "lint"},
+				{Name: "unit"},
+				{Name: "lint-report", DependsOn: []string{"lint"}},
+				{Name: "lint-notify", DependsOn: []string{"lint-report"}, RunPolicy: v1.RunOnFailure},
+				{Name: "unit-report", DependsOn: []string{"unit"}},
+				{Name: "unit-notify", DependsOn: []string{"unit-report"}, RunPolicy: v1.RunOnFailure},
+				{Name: "publish", DependsOn: []string{"lint-report", "unit-report"}, RunPolicy: v1.RunAlways},
+			},
+			exitCodes: map[string]int{"lint": 1},
+			want: map[string]v1.Phase{
+				"lint":        v1.PhaseFailed,
+				"unit":        v1.PhaseSucceeded,
+				"lint-report": v1.PhaseSkipped,
+				"lint-notify": v1.PhaseSucceeded,
+				"unit-report": v1.PhaseSucceeded,
+				"unit-notify": v1.PhaseSkipped,
+				"publish":     v1.PhaseSucceeded,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workflow := &v1.Workflow{Spec: v1.WorkflowSpec{Steps: tt.steps}}
+			status := &v1.Stage{ID: 1}
+			for i, step := range tt.steps {
+				status.Steps = append(status.Steps, &v1.Step{ID: uint64(i + 2), Name: step.Name})
+			}
+
+			client := &fakeClient{phases: make(map[string]v1.Phase)}
+			hook := &fakeHook{exitCodes: tt.exitCodes}
+			if err := execute(context.Background(), client, hook, workflow, status, nil, nil); err != nil {
+				t.Fatalf("execute() error = %v", err)
+			}
+			for name, want := range tt.want {
+				if got := client.phases[name]; got != want {
+					t.Errorf("step(%s) phase got %s, want %s", name, got, want)
+				}
+			}
+			if status.Phase != v1.PhaseFailed {
+				t.Errorf("stage phase got %s, want %s", status.Phase, v1.PhaseFailed)
+			}
+		})
+	}
+}
+
+func TestExecuteInterrupted(t *testing.T) {
+	steps := []v1.Flow{
+		{Name: "build"},
+		{Name: "test"},
+		{Name: "notify", RunPolicy: v1.RunOnFailure},
+		{Name: "cleanup", RunPolicy: v1.RunAlways},
+	}
+	tests := []struct {
+		name    string
+		timeout v1.Duration
+		cancel  bool
+		want    v1.Phase
+	}{
+		{name: "canceled", cancel: true, want: v1.PhaseCanceled},
+		{name: "timed out", timeout: v1.Duration(50 * time.Millisecond), want: v1.PhaseTimedOut},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workflow := &v1.Workflow{Spec: v1.WorkflowSpec{Steps: steps, Timeout: tt.timeout}}
+			status := &v1.Stage{ID: 1}
+			for i, step := range steps {
+				status.Steps = append(status.Steps, &v1.Step{ID: uint64(i + 2), Name: step.Name})
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			client := &fakeClient{phases: make(map[string]v1.Phase)}
+			hook := &fakeHook{block: "build"}
+			if tt.cancel {
+				hook.cancel = cancel
+			}
+			if err := execute(ctx, client, hook, workflow, status, nil, nil); err != nil {
+				t.Fatalf("execute() error = %v", err)
+			}
+			// the steps after the interruption are not skipped by their run policies.
+			for _, step := range steps {
+				if got := client.phases[step.Name]; got != tt.want {
+					t.Errorf("step(%s) phase got %s, want %s", step.Name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestConvertServices(t *testing.T) {
+	workflow := &v1.Workflow{Spec: v1.WorkflowSpec{
+		Steps:    []v1.Flow{{Name: "ping", Image: "redis"}},
+		Services: []v1.Flow{{Name: "redis", Image: "redis"}},
+	}}
+	status := &v1.Stage{ID: 1, Steps: []*v1.Step{{ID: 2, Name: "ping"}}}
+
+	tests := []struct {
+		kind    v1.WorkerKind
+		wantErr bool
+	}{
+		{kind: v1.WorkerKindDocker},
+		{kind: v1.WorkerKindHost, wantErr: true},
+		{kind: v1.WorkerKindSSH, wantErr: true},
+		{kind: v1.WorkerKindKubernetes, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			status.Worker = v1.Worker{Kind: tt.kind}
+			_, err := Convert(workflow, status, nil, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Convert() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConvertSource(t *testing.T) {
+	newSecret := func(data map[string]string) []*v1.Secret {
+		secret := &v1.Secret{Metadata: v1.Metadata{Name: "git"}, Data: data}
+		secret.Encrypt()
+		secret.Data = nil
+		return []*v1.Secret{secret}
+	}
+	sshSecret := newSecret(map[string]string{v1.SSHPrivateKeyKey: "key"})
+	knownHostsSecret := newSecret(map[string]string{
+		v1.SSHPrivateKeyKey: "key",
+		v1.SSHKnownHostsKey: "github.com ssh-ed25519 AAAA",
+	})
+
+	tests := []struct {
+		name     string
+		insecure bool
+		secrets  []*v1.Secret
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:    "known hosts",
+			secrets: knownHostsSecret,
+			want:    "-o StrictHostKeyChecking=yes -o UserKnownHostsFile=$DYNASTY_SOURCE_KNOWN_HOSTS_FILE",
+		},
+		{
+			name:     "known hosts over insecure",
+			insecure: true,
+			secrets:  knownHostsSecret,
+			want:     "-o StrictHostKeyChecking=yes",
+		},
+		{
+			name:     "insecure",
+			insecure: true,
+			secrets:  sshSecret,
+			want:     "-o StrictHostKeyChecking=no",
+		},
+		{name: "missing known hosts", secrets: sshSecret, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &v1.Source{URL: "git@github.com:zc2638/ink.git", Secret: "git", InsecureIgnoreHostKey: tt.insecure}
+			step, err := convertSource(source, "1", tt.secrets, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("convertSource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !slices.ContainsFunc(step.Command, func(command string) bool {
+				return strings.HasPrefix(command, "export GIT_SSH_COMMAND=") && strings.Contains(command, tt.want)
+			}) {
+				t.Fatalf("convertSource() command = %v, want ssh options %s", step.Command, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertKubernetesRetry(t *testing.T) {
+	workflow := &v1.Workflow{Spec: v1.WorkflowSpec{
+		Steps: []v1.Flow{{Name: "test", Image: "alpine", Retry: &v1.Retry{Count: 1}}},
+	}}
+	status := &v1.Stage{ID: 1, Steps: []*v1.Step{{ID: 2, Name: "test"}}}
+
+	status.Worker = v1.Worker{Kind: v1.WorkerKindDocker}
+	if _, err := Convert(workflow, status, nil, nil); err != nil {
+		t.Fatalf("Convert() docker error = %v", err)
+	}
+	status.Worker = v1.Worker{Kind: v1.WorkerKindKubernetes}
+	if _, err := Convert(workflow, status, nil, nil); err == nil {
+		t.Fatal("Convert() kubernetes expected the retry to be rejected")
+	}
+}
+
+func TestArtifactRoots(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		want     []string
+	}{
+		{name: "dir glob", patterns: []string{"dist/*.tar.gz"}, want: []string{"dist"}},
+		{name: "root glob", patterns: []string{"*.txt", "dist/*"}, want: []string{"."}},
+		{name: "file", patterns: []string{"./bin/app"}, want: []string{"bin/app"}},
+		{name: "nested", patterns: []string{"dist/*", "dist/linux/*", "out/?/*.bin"}, want: []string{"dist", "out"}},
+		{name: "duplicate", patterns: []string{"dist/*.zip", "dist/*.tar"}, want: []string{"dist"}},
+		{name: "sibling prefix", patterns: []string{"dist/*", "dist2/*"}, want: []string{"dist", "dist2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ArtifactRoots(tt.patterns); !slices.Equal(got, tt.want) {
+				t.Errorf("ArtifactRoots() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
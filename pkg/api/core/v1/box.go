@@ -17,24 +17,248 @@ package v1
 import (
 	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/99nil/gopkg/cycle"
 	"github.com/99nil/gopkg/sets"
+	"github.com/robfig/cron/v3"
 
 	"github.com/zc2638/ink/pkg/selector"
 )
 
 type BoxStatus struct {
-	Builds int64 `json:"builds"`
+	Builds    int64            `json:"builds"`
+	Schedules []ScheduleStatus `json:"schedules,omitempty" yaml:"schedules,omitempty"`
+}
+
+// ScheduleStatus describes the runs of the schedule.
+type ScheduleStatus struct {
+	Name string `json:"name" yaml:"name"`
+	// LastFired is the time the schedule was last fired, or first found by inkd.
+	LastFired time.Time   `json:"lastFired,omitempty" yaml:"lastFired,omitempty"`
+	Upcoming  []time.Time `json:"upcoming,omitempty" yaml:"upcoming,omitempty"`
 }
 
 // Box defines a collection of stage executions.
 type Box struct {
 	Metadata `yaml:",inline"`
 
-	Resources []BoxResource     `json:"resources" yaml:"resources"`
-	Settings  map[string]string `json:"settings,omitempty" yaml:"settings,omitempty"`
-	Status    BoxStatus         `json:"status,omitempty" yaml:"status,omitempty"`
+	Resources    []BoxResource     `json:"resources" yaml:"resources"`
+	Settings     map[string]string `json:"settings,omitempty" yaml:"settings,omitempty"`
+	Parameters   []BoxParameter    `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	Webhook      *BoxWebhook       `json:"webhook,omitempty" yaml:"webhook,omitempty"`
+	Schedules    []BoxSchedule     `json:"schedules,omitempty" yaml:"schedules,omitempty"`
+	CommitStatus *BoxCommitStatus  `json:"commitStatus,omitempty" yaml:"commitStatus,omitempty"`
+	Status       BoxStatus         `json:"status,omitempty" yaml:"status,omitempty"`
+}
+
+type ParameterType string
+
+func (s ParameterType) String() string { return string(s) }
+
+const (
+	ParameterString ParameterType = "string"
+	ParameterInt    ParameterType = "int"
+	ParameterBool   ParameterType = "bool"
+	// ParameterEnum means that the value must be one of the values of the parameter.
+	ParameterEnum ParameterType = "enum"
+)
+
+// BoxParameter declares the typed setting of the builds of the box.
+type BoxParameter struct {
+	Name string `json:"name" yaml:"name"`
+	// Type is one of string, int, bool and enum, it defaults to string.
+	Type     ParameterType `json:"type,omitempty" yaml:"type,omitempty"`
+	Required bool          `json:"required,omitempty" yaml:"required,omitempty"`
+	// Default is used if the setting is not provided by the build.
+	Default string `json:"default,omitempty" yaml:"default,omitempty"`
+	// Values are the allowed values of the enum.
+	Values      []string `json:"values,omitempty" yaml:"values,omitempty"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+func (p *BoxParameter) Validate() error {
+	switch p.Type {
+	case "", ParameterString, ParameterInt, ParameterBool:
+	case ParameterEnum:
+		if len(p.Values) == 0 {
+			return errors.New("values are required for enum")
+		}
+	default:
+		return fmt.Errorf("unsupported type: %s", p.Type)
+	}
+	if p.Default != "" {
+		return p.Check(p.Default)
+	}
+	return nil
+}
+
+// Check returns an error if the value does not match the type of the parameter.
+func (p *BoxParameter) Check(value string) error {
+	var err error
+	switch p.Type {
+	case ParameterInt:
+		_, err = strconv.Atoi(value)
+	case ParameterBool:
+		_, err = strconv.ParseBool(value)
+	case ParameterEnum:
+		if !slices.Contains(p.Values, value) {
+			return fmt.Errorf("invalid value %q, must be one of %v", value, p.Values)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s value %q", p.Type, value)
+	}
+	return nil
+}
+
+// ParameterError is returned if the settings of the build do not match the parameters of the box.
+type ParameterError struct {
+	Name    string
+	Message string
+}
+
+func (e *ParameterError) Error() string {
+	return fmt.Sprintf("parameter(%s): %s", e.Name, e.Message)
+}
+
+// CheckSettings validates the settings of the build by the parameters of the box,
+// and fills the defaults of the parameters not provided. If the box declares any parameter,
+// the settings not declared are rejected, except the settings of the box and the DYNASTY_ ones.
+func (b *Box) CheckSettings(settings map[string]string) error {
+	if len(b.Parameters) == 0 {
+		return nil
+	}
+
+	names := sets.New[string]()
+	for _, p := range b.Parameters {
+		names.Add(p.Name)
+
+		value, ok := settings[p.Name]
+		if !ok && p.Default != "" {
+			value, ok = p.Default, true
+			settings[p.Name] = value
+		}
+		if !ok || value == "" {
+			if p.Required {
+				return &ParameterError{Name: p.Name, Message: "is required"}
+			}
+			continue
+		}
+		if err := p.Check(value); err != nil {
+			return &ParameterError{Name: p.Name, Message: err.Error()}
+		}
+	}
+
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		if names.Has(k) || strings.HasPrefix(k, "DYNASTY_") {
+			continue
+		}
+		if _, ok := b.Settings[k]; ok {
+			continue
+		}
+		return &ParameterError{Name: k, Message: "is not declared"}
+	}
+	return nil
+}
+
+// BoxSchedule defines the periodic builds of the box.
+type BoxSchedule struct {
+	Name string `json:"name" yaml:"name"`
+	// Cron is the standard cron expression with five fields, or the descriptors like @daily.
+	Cron string `json:"cron" yaml:"cron"`
+	// Timezone is the IANA name of the location to evaluate the cron, e.g. Asia/Shanghai.
+	// It defaults to the local timezone of inkd.
+	Timezone string `json:"timezone,omitempty" yaml:"timezone,omitempty"`
+	// Settings overrides the settings of the box for the builds of the schedule.
+	Settings map[string]string `json:"settings,omitempty" yaml:"settings,omitempty"`
+}
+
+// Next returns the first fire time of the schedule after the time.
+func (s *BoxSchedule) Next(t time.Time) (time.Time, error) {
+	schedule, err := cron.ParseStandard(s.Cron)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid cron: %v", err)
+	}
+	loc := time.Local
+	if s.Timezone != "" {
+		if loc, err = time.LoadLocation(s.Timezone); err != nil {
+			return time.Time{}, fmt.Errorf("invalid timezone: %v", err)
+		}
+	}
+	return schedule.Next(t.In(loc)), nil
+}
+
+// Upcoming returns the next fire times of the schedule after the time.
+func (s *BoxSchedule) Upcoming(t time.Time, count int) ([]time.Time, error) {
+	result := make([]time.Time, 0, count)
+	for i := 0; i < count; i++ {
+		next, err := s.Next(t)
+		if err != nil {
+			return nil, err
+		}
+		if next.IsZero() {
+			break
+		}
+		result = append(result, next)
+		t = next
+	}
+	return result, nil
+}
+
+// WebhookSecretKey is the key of the secret holds the token to verify the webhooks.
+const WebhookSecretKey = "token"
+
+// BoxWebhook enables the webhooks of the git providers to trigger the builds of the box.
+type BoxWebhook struct {
+	// Secret is the name of the secret in the namespace of the box,
+	// the value of its token key is used to verify the signature of the webhooks.
+	Secret string `json:"secret" yaml:"secret"`
+}
+
+// CommitStatusTokenKey is the key of the secret holds the access token to report the commit statuses.
+const CommitStatusTokenKey = "token"
+
+// BoxCommitStatus reports the phases of the builds and stages as the statuses of the commits,
+// the commit is the DYNASTY_COMMIT_SHA of the build settings.
+type BoxCommitStatus struct {
+	// Provider is one of github, gitlab and gitea.
+	Provider string `json:"provider" yaml:"provider"`
+	// URL is the address of the api, it defaults to https://api.github.com for github
+	// and https://gitlab.com for gitlab, and is required for gitea.
+	URL string `json:"url,omitempty" yaml:"url,omitempty"`
+	// Repository is the full name of the repository, e.g. owner/repo.
+	Repository string `json:"repository" yaml:"repository"`
+	// Secret is the name of the secret in the namespace of the box,
+	// the value of its token key is the access token of the provider.
+	Secret string `json:"secret" yaml:"secret"`
+	// Context is the name of the build status, and the prefix of the stage statuses.
+	// It defaults to ink.
+	Context string `json:"context,omitempty" yaml:"context,omitempty"`
+}
+
+func (s *BoxCommitStatus) Validate() error {
+	if !slices.Contains([]string{"github", "gitlab", "gitea"}, s.Provider) {
+		return fmt.Errorf("commitStatus: unsupported provider: %s", s.Provider)
+	}
+	if s.Provider == "gitea" && s.URL == "" {
+		return errors.New("commitStatus: url is required for gitea")
+	}
+	if s.Repository == "" {
+		return errors.New("commitStatus: repository is required")
+	}
+	if s.Secret == "" {
+		return errors.New("commitStatus: secret is required")
+	}
+	return nil
 }
 
 func (b *Box) GetSelectors(kind string, settings map[string]string) (names []string, selectors []*selector.Selector) {
@@ -67,6 +291,44 @@ func (b *Box) Validate(workflows []*Workflow) error {
 		return errors.New("dependency cycle detected in workflows")
 	}
 
+	if b.Webhook != nil && b.Webhook.Secret == "" {
+		return errors.New("webhook: secret is required")
+	}
+
+	if b.CommitStatus != nil {
+		if err := b.CommitStatus.Validate(); err != nil {
+			return err
+		}
+	}
+
+	parameterNames := sets.New[string]()
+	for index, parameter := range b.Parameters {
+		if parameter.Name == "" {
+			return fmt.Errorf("invalid parameter name at index: %d", index)
+		}
+		if parameterNames.Has(parameter.Name) {
+			return fmt.Errorf("duplicate parameter name: %s", parameter.Name)
+		}
+		parameterNames.Add(parameter.Name)
+		if err := parameter.Validate(); err != nil {
+			return fmt.Errorf("parameter(%s): %v", parameter.Name, err)
+		}
+	}
+
+	names := sets.New[string]()
+	for index, schedule := range b.Schedules {
+		if schedule.Name == "" {
+			return fmt.Errorf("invalid schedule name at index: %d", index)
+		}
+		if names.Has(schedule.Name) {
+			return fmt.Errorf("duplicate schedule name: %s", schedule.Name)
+		}
+		names.Add(schedule.Name)
+		if _, err := schedule.Next(time.Now()); err != nil {
+			return fmt.Errorf("schedule(%s): %v", schedule.Name, err)
+		}
+	}
+
 	for index, rv := range b.Resources {
 		if rv.Name == "" && rv.Selector == nil && rv.LabelSelector == nil {
 			return fmt.Errorf("invalid resource at index: %d", index)
@@ -0,0 +1,124 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"errors"
+	"maps"
+	"testing"
+)
+
+func TestBoxCheckSettings(t *testing.T) {
+	parameters := []BoxParameter{
+		{Name: "TARGET", Required: true},
+		{Name: "REPLICAS", Type: ParameterInt, Default: "1"},
+		{Name: "DEBUG", Type: ParameterBool},
+		{Name: "ENV", Type: ParameterEnum, Values: []string{"dev", "prod"}, Default: "dev"},
+	}
+	tests := []struct {
+		name        string
+		parameters  []BoxParameter
+		boxSettings map[string]string
+		settings    map[string]string
+		want        map[string]string
+		wantErr     string
+	}{
+		{
+			name:     "no parameter",
+			settings: map[string]string{"ANY": "value"},
+			want:     map[string]string{"ANY": "value"},
+		},
+		{
+			name:       "defaults",
+			parameters: parameters,
+			settings:   map[string]string{"TARGET": "app"},
+			want:       map[string]string{"TARGET": "app", "REPLICAS": "1", "ENV": "dev"},
+		},
+		{
+			name:       "provided",
+			parameters: parameters,
+			settings:   map[string]string{"TARGET": "app", "REPLICAS": "3", "DEBUG": "true", "ENV": "prod"},
+			want:       map[string]string{"TARGET": "app", "REPLICAS": "3", "DEBUG": "true", "ENV": "prod"},
+		},
+		{
+			name:       "required",
+			parameters: parameters,
+			settings:   map[string]string{},
+			wantErr:    "TARGET",
+		},
+		{
+			name:       "required empty",
+			parameters: parameters,
+			settings:   map[string]string{"TARGET": ""},
+			wantErr:    "TARGET",
+		},
+		{
+			name:       "invalid int",
+			parameters: parameters,
+			settings:   map[string]string{"TARGET": "app", "REPLICAS": "three"},
+			wantErr:    "REPLICAS",
+		},
+		{
+			name:       "invalid bool",
+			parameters: parameters,
+			settings:   map[string]string{"TARGET": "app", "DEBUG": "maybe"},
+			wantErr:    "DEBUG",
+		},
+		{
+			name:       "invalid enum",
+			parameters: parameters,
+			settings:   map[string]string{"TARGET": "app", "ENV": "test"},
+			wantErr:    "ENV",
+		},
+		{
+			name:       "undeclared",
+			parameters: parameters,
+			settings:   map[string]string{"TARGET": "app", "OTHER": "value"},
+			wantErr:    "OTHER",
+		},
+		{
+			name:        "box settings and dynasty",
+			parameters:  parameters,
+			boxSettings: map[string]string{"REGISTRY": "docker.io"},
+			settings:    map[string]string{"TARGET": "app", "REGISTRY": "ghcr.io", "DYNASTY_BUILD": "1"},
+			want: map[string]string{
+				"TARGET":        "app",
+				"REPLICAS":      "1",
+				"ENV":           "dev",
+				"REGISTRY":      "ghcr.io",
+				"DYNASTY_BUILD": "1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box := &Box{Parameters: tt.parameters, Settings: tt.boxSettings}
+			err := box.CheckSettings(tt.settings)
+			if tt.wantErr != "" {
+				var pe *ParameterError
+				if !errors.As(err, &pe) || pe.Name != tt.wantErr {
+					t.Fatalf("CheckSettings() error = %v, want parameter %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CheckSettings() error = %v", err)
+			}
+			if !maps.Equal(tt.settings, tt.want) {
+				t.Fatalf("CheckSettings() settings = %v, want %v", tt.settings, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration stores a human-readable duration
+// (eg. "30s", "10m", "1h30m").
+type Duration time.Duration
+
+// UnmarshalYAML implements yaml unmarshalling.
+func (d *Duration) UnmarshalYAML(unmarshal func(any) error) error {
+	var intType int64
+	if err := unmarshal(&intType); err == nil {
+		return d.fromInt(intType)
+	}
+
+	var stringType string
+	if err := unmarshal(&stringType); err != nil {
+		return err
+	}
+	return d.parse(stringType)
+}
+
+// UnmarshalJSON implements json unmarshalling.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var intType int64
+	if err := json.Unmarshal(b, &intType); err == nil {
+		return d.fromInt(intType)
+	}
+
+	var stringType string
+	if err := json.Unmarshal(b, &stringType); err != nil {
+		return err
+	}
+	return d.parse(stringType)
+}
+
+// MarshalJSON implements json marshalling.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// MarshalYAML implements yaml marshalling.
+func (d Duration) MarshalYAML() (any, error) {
+	return d.String(), nil
+}
+
+// fromInt only accepts the zero value,
+// since the unit of a bare integer is ambiguous.
+func (d *Duration) fromInt(v int64) error {
+	if v != 0 {
+		return fmt.Errorf("invalid duration %d, use a duration string like 10m", v)
+	}
+	*d = 0
+	return nil
+}
+
+func (d *Duration) parse(s string) error {
+	if s == "" {
+		*d = 0
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err == nil {
+		*d = Duration(v)
+	}
+	return err
+}
+
+// Std returns the duration as a time.Duration.
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+// String returns a human-readable duration (eg. "1h30m0s").
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
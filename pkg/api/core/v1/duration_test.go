@@ -0,0 +1,77 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestDurationUnmarshal(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		yaml    string
+		want    Duration
+		wantErr bool
+	}{
+		{name: "string", json: `"10m"`, yaml: `10m`, want: Duration(10 * time.Minute)},
+		{name: "compound", json: `"1h30m"`, yaml: `1h30m`, want: Duration(90 * time.Minute)},
+		{name: "empty", json: `""`, yaml: `""`, want: 0},
+		{name: "zero", json: `0`, yaml: `0`, want: 0},
+		{name: "bare integer", json: `600`, yaml: `600`, wantErr: true},
+		{name: "negative integer", json: `-1`, yaml: `-1`, wantErr: true},
+		{name: "quoted integer", json: `"600"`, yaml: `"600"`, wantErr: true},
+		{name: "float", json: `1.5`, yaml: `1.5`, wantErr: true},
+		{name: "invalid", json: `"ten minutes"`, yaml: `ten minutes`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+"/json", func(t *testing.T) {
+			var got Duration
+			err := json.Unmarshal([]byte(tt.json), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Fatalf("UnmarshalJSON() got %s, want %s", got, tt.want)
+			}
+		})
+		t.Run(tt.name+"/yaml", func(t *testing.T) {
+			var got Duration
+			err := yaml.Unmarshal([]byte(tt.yaml), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalYAML() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Fatalf("UnmarshalYAML() got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDurationMarshal(t *testing.T) {
+	d := Duration(90 * time.Second)
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("MarshalJSON() error = %v", err)
+	}
+	var got Duration
+	if err := json.Unmarshal(b, &got); err != nil || got != d {
+		t.Fatalf("MarshalJSON() round trip got %s, want %s, error = %v", got, d, err)
+	}
+}
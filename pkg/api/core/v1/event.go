@@ -0,0 +1,41 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import "time"
+
+// The kinds of the phase events.
+const (
+	PhaseEventBuild = "build"
+	PhaseEventStage = "stage"
+	PhaseEventStep  = "step"
+)
+
+// PhaseEvent is the phase transition of the build, stage or step.
+type PhaseEvent struct {
+	Kind      string `json:"kind" yaml:"kind"`
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Box       string `json:"box" yaml:"box"`
+	// Build is the number of the build.
+	Build uint64 `json:"build" yaml:"build"`
+	// Stage is the number of the stage, it is empty for the build events.
+	Stage uint64 `json:"stage,omitempty" yaml:"stage,omitempty"`
+	// Step is the number of the step, it is empty for the build and stage events.
+	Step  uint64    `json:"step,omitempty" yaml:"step,omitempty"`
+	Name  string    `json:"name,omitempty" yaml:"name,omitempty"`
+	Phase Phase     `json:"phase" yaml:"phase"`
+	Error string    `json:"error,omitempty" yaml:"error,omitempty"`
+	Time  time.Time `json:"time" yaml:"time"`
+}
@@ -0,0 +1,163 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var matrixAxisNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Matrix expands the workflow into a stage for each combination of the values of the axes,
+// the values of the combination are injected into the env of the steps.
+// The axes named os and arch also select the platform of the worker.
+type Matrix struct {
+	Axes []MatrixAxis `json:"axes" yaml:"axes"`
+	// Include adds the combinations besides the ones of the axes.
+	Include []map[string]string `json:"include,omitempty" yaml:"include,omitempty"`
+	// Exclude removes the combinations of the axes matching all values of any item.
+	Exclude []map[string]string `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+}
+
+type MatrixAxis struct {
+	Name   string   `json:"name" yaml:"name"`
+	Values []string `json:"values" yaml:"values"`
+}
+
+func (m *Matrix) Validate() error {
+	names := make(map[string]struct{}, len(m.Axes))
+	for index, axis := range m.Axes {
+		if !matrixAxisNameRegexp.MatchString(axis.Name) {
+			return fmt.Errorf("matrix: invalid axis name at index: %d", index)
+		}
+		if _, ok := names[axis.Name]; ok {
+			return fmt.Errorf("matrix: duplicate axis name: %s", axis.Name)
+		}
+		names[axis.Name] = struct{}{}
+		if len(axis.Values) == 0 {
+			return fmt.Errorf("matrix: values are required for axis: %s", axis.Name)
+		}
+	}
+	for _, item := range m.Include {
+		for k := range item {
+			if !matrixAxisNameRegexp.MatchString(k) {
+				return fmt.Errorf("matrix: invalid include name: %s", k)
+			}
+		}
+	}
+	for _, item := range m.Exclude {
+		for k := range item {
+			if _, ok := names[k]; !ok {
+				return fmt.Errorf("matrix: exclude axis not found: %s", k)
+			}
+		}
+	}
+	if len(m.Cells()) == 0 {
+		return errors.New("matrix: no combination")
+	}
+	return nil
+}
+
+// Cells returns the combinations of the matrix in order.
+func (m *Matrix) Cells() []map[string]string {
+	var cells []map[string]string
+	if len(m.Axes) > 0 {
+		cells = []map[string]string{{}}
+	}
+	for _, axis := range m.Axes {
+		next := make([]map[string]string, 0, len(cells)*len(axis.Values))
+		for _, cell := range cells {
+			for _, value := range axis.Values {
+				item := maps.Clone(cell)
+				item[axis.Name] = value
+				next = append(next, item)
+			}
+		}
+		cells = next
+	}
+
+	cells = slices.DeleteFunc(cells, func(cell map[string]string) bool {
+		return slices.ContainsFunc(m.Exclude, func(item map[string]string) bool {
+			for k, v := range item {
+				if cell[k] != v {
+					return false
+				}
+			}
+			return true
+		})
+	})
+
+	for _, item := range m.Include {
+		if len(item) == 0 {
+			continue
+		}
+		if !slices.ContainsFunc(cells, func(cell map[string]string) bool { return maps.Equal(cell, item) }) {
+			cells = append(cells, maps.Clone(item))
+		}
+	}
+	return cells
+}
+
+// CellName returns the name of the stage of the combination, e.g. test (go=1.21, arch=arm64).
+// The values are ordered by the axes, and then by the names of the included ones.
+func (m *Matrix) CellName(name string, cell map[string]string) string {
+	keys := make([]string, 0, len(cell))
+	for _, axis := range m.Axes {
+		if _, ok := cell[axis.Name]; ok {
+			keys = append(keys, axis.Name)
+		}
+	}
+	included := make([]string, 0, len(cell))
+	for k := range cell {
+		if !slices.Contains(keys, k) {
+			included = append(included, k)
+		}
+	}
+	slices.Sort(included)
+	keys = append(keys, included...)
+
+	values := make([]string, 0, len(keys))
+	for _, k := range keys {
+		values = append(values, k+"="+cell[k])
+	}
+	return fmt.Sprintf("%s (%s)", name, strings.Join(values, ", "))
+}
+
+// Worker returns the worker of the combination, the os and arch axes select the platform.
+func (m *Matrix) Worker(worker Worker, cell map[string]string) Worker {
+	osName, hasOS := cell["os"]
+	arch, hasArch := cell["arch"]
+	if !hasOS && !hasArch {
+		return worker
+	}
+
+	platform := new(Platform)
+	if worker.Platform != nil {
+		*platform = *worker.Platform
+	}
+	if hasOS {
+		platform.OS = osName
+	}
+	if hasArch {
+		platform.Arch = arch
+	}
+	worker.Platform = platform
+	return worker
+}
@@ -0,0 +1,185 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatrixValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		matrix  Matrix
+		wantErr bool
+	}{
+		{name: "valid", matrix: Matrix{Axes: []MatrixAxis{{Name: "go", Values: []string{"1.21"}}}}},
+		{name: "include only", matrix: Matrix{Include: []map[string]string{{"go": "1.21"}}}},
+		{name: "invalid axis name", matrix: Matrix{Axes: []MatrixAxis{{Name: "go-version", Values: []string{"1.21"}}}}, wantErr: true},
+		{
+			name:    "duplicate axis",
+			matrix:  Matrix{Axes: []MatrixAxis{{Name: "go", Values: []string{"1.21"}}, {Name: "go", Values: []string{"1.22"}}}},
+			wantErr: true,
+		},
+		{name: "no values", matrix: Matrix{Axes: []MatrixAxis{{Name: "go"}}}, wantErr: true},
+		{
+			name:    "invalid include name",
+			matrix:  Matrix{Axes: []MatrixAxis{{Name: "go", Values: []string{"1.21"}}}, Include: []map[string]string{{"1go": "1.22"}}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown exclude axis",
+			matrix:  Matrix{Axes: []MatrixAxis{{Name: "go", Values: []string{"1.21"}}}, Exclude: []map[string]string{{"os": "linux"}}},
+			wantErr: true,
+		},
+		{
+			name:    "all excluded",
+			matrix:  Matrix{Axes: []MatrixAxis{{Name: "go", Values: []string{"1.21"}}}, Exclude: []map[string]string{{"go": "1.21"}}},
+			wantErr: true,
+		},
+		{name: "empty", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.matrix.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMatrixCells(t *testing.T) {
+	axes := []MatrixAxis{
+		{Name: "go", Values: []string{"1.21", "1.22"}},
+		{Name: "arch", Values: []string{"amd64", "arm64"}},
+	}
+	tests := []struct {
+		name   string
+		matrix Matrix
+		want   []map[string]string
+	}{
+		{
+			name:   "axes",
+			matrix: Matrix{Axes: axes},
+			want: []map[string]string{
+				{"go": "1.21", "arch": "amd64"},
+				{"go": "1.21", "arch": "arm64"},
+				{"go": "1.22", "arch": "amd64"},
+				{"go": "1.22", "arch": "arm64"},
+			},
+		},
+		{
+			name:   "exclude",
+			matrix: Matrix{Axes: axes, Exclude: []map[string]string{{"go": "1.21", "arch": "arm64"}, {"go": "1.22"}}},
+			want:   []map[string]string{{"go": "1.21", "arch": "amd64"}},
+		},
+		{
+			name: "include",
+			matrix: Matrix{
+				Axes:    axes[:1],
+				Include: []map[string]string{{"go": "1.21"}, {"go": "1.23", "experimental": "true"}, {}},
+			},
+			want: []map[string]string{{"go": "1.21"}, {"go": "1.22"}, {"go": "1.23", "experimental": "true"}},
+		},
+		{
+			name:   "include excluded",
+			matrix: Matrix{Axes: axes[:1], Exclude: []map[string]string{{"go": "1.21"}}, Include: []map[string]string{{"go": "1.21"}}},
+			want:   []map[string]string{{"go": "1.22"}, {"go": "1.21"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.matrix.Cells(); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Cells() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatrixCellName(t *testing.T) {
+	matrix := Matrix{Axes: []MatrixAxis{
+		{Name: "go", Values: []string{"1.21"}},
+		{Name: "arch", Values: []string{"amd64"}},
+	}}
+	tests := []struct {
+		name string
+		cell map[string]string
+		want string
+	}{
+		{name: "axes order", cell: map[string]string{"arch": "amd64", "go": "1.21"}, want: "test (go=1.21, arch=amd64)"},
+		{
+			name: "included names sorted",
+			cell: map[string]string{"go": "1.23", "tags": "race", "experimental": "true"},
+			want: "test (go=1.23, experimental=true, tags=race)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matrix.CellName("test", tt.cell); got != tt.want {
+				t.Fatalf("CellName() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatrixWorker(t *testing.T) {
+	tests := []struct {
+		name   string
+		worker Worker
+		cell   map[string]string
+		want   Worker
+	}{
+		{
+			name:   "no platform axis",
+			worker: Worker{Kind: WorkerKindDocker},
+			cell:   map[string]string{"go": "1.21"},
+			want:   Worker{Kind: WorkerKindDocker},
+		},
+		{
+			name:   "arch",
+			worker: Worker{Kind: WorkerKindDocker},
+			cell:   map[string]string{"arch": "arm64"},
+			want:   Worker{Kind: WorkerKindDocker, Platform: &Platform{Arch: "arm64"}},
+		},
+		{
+			name:   "override",
+			worker: Worker{Kind: WorkerKindDocker, Platform: &Platform{OS: "linux", Arch: "amd64"}},
+			cell:   map[string]string{"arch": "arm64"},
+			want:   Worker{Kind: WorkerKindDocker, Platform: &Platform{OS: "linux", Arch: "arm64"}},
+		},
+		{
+			name:   "os and arch",
+			worker: Worker{Kind: WorkerKindHost},
+			cell:   map[string]string{"os": "darwin", "arch": "arm64"},
+			want:   Worker{Kind: WorkerKindHost, Platform: &Platform{OS: "darwin", Arch: "arm64"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var platform Platform
+			if tt.worker.Platform != nil {
+				platform = *tt.worker.Platform
+			}
+			matrix := &Matrix{}
+			if got := matrix.Worker(tt.worker, tt.cell); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Worker() = %+v, want %+v", got, tt.want)
+			}
+			// the platform of the workflow is shared by the cells.
+			if tt.worker.Platform != nil && *tt.worker.Platform != platform {
+				t.Fatalf("Worker() modified the platform of the workflow: %+v", tt.worker.Platform)
+			}
+		})
+	}
+}
@@ -28,6 +28,7 @@ const (
 	KindBox      = "Box"
 	KindWorkflow = "Workflow"
 	KindSecret   = "Secret"
+	KindNotifier = "Notifier"
 )
 
 const LabelStatus = "ink.io/status"
@@ -0,0 +1,163 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"text/template"
+	"time"
+)
+
+// The events of the build phase transitions sent by the notifiers.
+const (
+	NotifyStarted   = "started"
+	NotifySucceeded = "succeeded"
+	NotifyFailed    = "failed"
+	NotifyCanceled  = "canceled"
+)
+
+// NotifyEvent returns the event of the build phase, it is empty if the phase is not notified.
+func NotifyEvent(phase Phase) string {
+	switch {
+	case phase == PhaseRunning:
+		return NotifyStarted
+	case phase.IsSucceeded():
+		return NotifySucceeded
+	case phase.IsFailed():
+		return NotifyFailed
+	case phase == PhaseCanceled:
+		return NotifyCanceled
+	}
+	return ""
+}
+
+// The keys of the secret used by the notifiers.
+const (
+	NotifierURLKey      = "url"
+	NotifierPasswordKey = "password"
+)
+
+// Notifier sends the messages of the builds to the outside.
+type Notifier struct {
+	Metadata `yaml:",inline"`
+
+	Spec NotifierSpec `json:"spec" yaml:"spec"`
+}
+
+type NotifierSpec struct {
+	// AllBoxes applies the notifier to all boxes in the namespace,
+	// otherwise only to the boxes that select it in the resources.
+	AllBoxes bool `json:"allBoxes,omitempty" yaml:"allBoxes,omitempty"`
+	// Events defines the events to send, all events are sent if it is empty.
+	Events []string `json:"events,omitempty" yaml:"events,omitempty"`
+	// Template is the text/template of the message, which is rendered with
+	// the Event, Box, Build and Stages of the build.
+	Template string `json:"template,omitempty" yaml:"template,omitempty"`
+	// Secret is the name of the secret in the same namespace, the value of the url key
+	// is used if the url is not defined, and the password key is used for smtp.
+	Secret string `json:"secret,omitempty" yaml:"secret,omitempty"`
+	// Retry defines the policy to retry the failed delivery.
+	Retry *Retry `json:"retry,omitempty" yaml:"retry,omitempty"`
+
+	// Only one of the backends can be defined.
+	Webhook *WebhookNotifier `json:"webhook,omitempty" yaml:"webhook,omitempty"`
+	Slack   *SlackNotifier   `json:"slack,omitempty" yaml:"slack,omitempty"`
+	SMTP    *SMTPNotifier    `json:"smtp,omitempty" yaml:"smtp,omitempty"`
+}
+
+// WebhookNotifier posts the build and the message as json to the url.
+type WebhookNotifier struct {
+	URL     string            `json:"url,omitempty" yaml:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+}
+
+// SlackNotifier posts the message to the slack compatible incoming webhook.
+type SlackNotifier struct {
+	URL      string `json:"url,omitempty" yaml:"url,omitempty"`
+	Channel  string `json:"channel,omitempty" yaml:"channel,omitempty"`
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+}
+
+// SMTPNotifier sends the message by email, the first line of the message is the subject.
+type SMTPNotifier struct {
+	Host     string   `json:"host" yaml:"host"`
+	Port     int      `json:"port,omitempty" yaml:"port,omitempty"`
+	Username string   `json:"username,omitempty" yaml:"username,omitempty"`
+	From     string   `json:"from" yaml:"from"`
+	To       []string `json:"to" yaml:"to"`
+}
+
+func (n *Notifier) Validate() error {
+	var count int
+	if n.Spec.Webhook != nil {
+		count++
+	}
+	if n.Spec.Slack != nil {
+		count++
+	}
+	if n.Spec.SMTP != nil {
+		count++
+		if n.Spec.SMTP.Host == "" || n.Spec.SMTP.From == "" || len(n.Spec.SMTP.To) == 0 {
+			return errors.New("smtp: host, from and to are required")
+		}
+	}
+	if count != 1 {
+		return errors.New("only one of webhook, slack and smtp must be defined")
+	}
+
+	for _, event := range n.Spec.Events {
+		if !slices.Contains([]string{NotifyStarted, NotifySucceeded, NotifyFailed, NotifyCanceled}, event) {
+			return fmt.Errorf("unsupported event: %s", event)
+		}
+	}
+	if n.Spec.Template != "" {
+		if _, err := template.New(n.Name).Parse(n.Spec.Template); err != nil {
+			return fmt.Errorf("invalid template: %v", err)
+		}
+	}
+	return n.Spec.Retry.Validate()
+}
+
+// Type returns the name of the backend defined in the spec.
+func (s *NotifierSpec) Type() string {
+	switch {
+	case s.Webhook != nil:
+		return "webhook"
+	case s.Slack != nil:
+		return "slack"
+	case s.SMTP != nil:
+		return "smtp"
+	}
+	return ""
+}
+
+// Match returns true if the notifier sends the event.
+func (n *Notifier) Match(event string) bool {
+	return len(n.Spec.Events) == 0 || slices.Contains(n.Spec.Events, event)
+}
+
+// Notification records the delivery of the event by the notifier.
+type Notification struct {
+	ID       uint64    `json:"id" yaml:"id"`
+	BuildID  uint64    `json:"buildID" yaml:"buildID"`
+	Notifier string    `json:"notifier" yaml:"notifier"`
+	Event    string    `json:"event" yaml:"event"`
+	Phase    Phase     `json:"phase" yaml:"phase"`
+	Attempts int       `json:"attempts" yaml:"attempts"`
+	Error    string    `json:"error,omitempty" yaml:"error,omitempty"`
+	Creation time.Time `json:"creation" yaml:"creation"`
+}
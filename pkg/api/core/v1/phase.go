@@ -28,12 +28,14 @@ func ToPhase(s string) Phase {
 	phase := Phase(s)
 	switch phase {
 	case PhaseWaiting,
+		PhaseWaitingApproval,
 		PhasePending,
 		PhaseRunning,
 		PhaseSucceeded,
 		PhaseFailed,
 		PhaseCanceled,
-		PhaseSkipped:
+		PhaseSkipped,
+		PhaseTimedOut:
 	default:
 		phase = PhaseUnknown
 	}
@@ -44,18 +46,24 @@ const (
 	PhaseUnknown Phase = "Unknown"
 	// PhaseWaiting used for dependencies,
 	// the status description that waits for the dependency to finish executing.
-	PhaseWaiting   Phase = "Waiting"
-	PhasePending   Phase = "Pending"
-	PhaseRunning   Phase = "Running"
-	PhaseSucceeded Phase = "Succeeded"
-	PhaseFailed    Phase = "Failed"
-	PhaseCanceled  Phase = "Canceled"
-	PhaseSkipped   Phase = "Skipped"
+	PhaseWaiting Phase = "Waiting"
+	// PhaseWaitingApproval used for the stage requiring the approval,
+	// the status description that waits for the approval after the dependencies finish.
+	PhaseWaitingApproval Phase = "WaitingApproval"
+	PhasePending         Phase = "Pending"
+	PhaseRunning         Phase = "Running"
+	PhaseSucceeded       Phase = "Succeeded"
+	PhaseFailed          Phase = "Failed"
+	PhaseCanceled        Phase = "Canceled"
+	PhaseSkipped         Phase = "Skipped"
+	// PhaseTimedOut used for the step or stage
+	// that is terminated because it exceeds the timeout.
+	PhaseTimedOut Phase = "TimedOut"
 )
 
 func (s Phase) IsDone() bool {
 	switch s {
-	case PhaseUnknown, PhaseWaiting, PhasePending, PhaseRunning:
+	case PhaseUnknown, PhaseWaiting, PhaseWaitingApproval, PhasePending, PhaseRunning:
 		return false
 	default:
 		return true
@@ -67,5 +75,5 @@ func (s Phase) IsSucceeded() bool {
 }
 
 func (s Phase) IsFailed() bool {
-	return s == PhaseFailed
+	return s == PhaseFailed || s == PhaseTimedOut
 }
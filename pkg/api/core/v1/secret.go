@@ -28,10 +28,13 @@ import (
 type Secret struct {
 	Metadata `yaml:",inline"`
 
-	Data        map[string]string `json:"data,omitempty" yaml:"data,omitempty"`
+	Data map[string]string `json:"data,omitempty" yaml:"data,omitempty"`
+	// EncryptData holds the base64 encoded values, it is only an encoding for transport,
+	// the secrets are encrypted at rest by the keys of the server.
 	EncryptData map[string]string `json:"encryptData,omitempty" yaml:"encryptData,omitempty"`
 }
 
+// Encrypt encodes the values of Data into EncryptData.
 func (s *Secret) Encrypt() {
 	if s.EncryptData == nil {
 		s.EncryptData = make(map[string]string)
@@ -42,6 +45,7 @@ func (s *Secret) Encrypt() {
 	}
 }
 
+// Decrypt decodes the values of EncryptData into Data.
 func (s *Secret) Decrypt() error {
 	if s.Data == nil {
 		s.Data = make(map[string]string)
@@ -59,6 +63,16 @@ func (s *Secret) Decrypt() error {
 
 const DockerConfigJSONKey = ".dockerconfigjson"
 
+// The keys of the secret used for SSH authentication.
+const (
+	SSHUsernameKey   = "username"
+	SSHPasswordKey   = "password"
+	SSHPrivateKeyKey = "privateKey"
+	SSHPassphraseKey = "passphrase"
+	// SSHKnownHostsKey holds the known_hosts lines to verify the host key of the ssh remote.
+	SSHKnownHostsKey = "knownHosts"
+)
+
 type DockerAuths struct {
 	Auths map[string]DockerAuth `json:"auths" yaml:"auths"`
 }
@@ -16,6 +16,7 @@ package v1
 
 import (
 	"maps"
+	"slices"
 	"strconv"
 )
 
@@ -27,8 +28,11 @@ type Build struct {
 	Title    string            `json:"title" yaml:"title"`
 	Message  string            `json:"message,omitempty" yaml:"message,omitempty"`
 	Settings map[string]string `json:"settings,omitempty" yaml:"settings,omitempty"`
+	Commit   *Commit           `json:"commit,omitempty" yaml:"commit,omitempty"`
 	Started  int64             `json:"started,omitempty" yaml:"started,omitempty"`
 	Stopped  int64             `json:"stopped,omitempty" yaml:"stopped,omitempty"`
+	// RerunOf is the number of the build which is re-run by this build.
+	RerunOf uint64 `json:"rerunOf,omitempty" yaml:"rerunOf,omitempty"`
 
 	Stages []*Stage `json:"stages,omitempty" yaml:"stages,omitempty"`
 }
@@ -43,9 +47,54 @@ func (b *Build) CompleteSettings(box *Box) map[string]string {
 	}
 	settings["DYNASTY_BUILD_NUMBER"] = strconv.FormatUint(b.Number, 10)
 
+	if b.Commit != nil {
+		commitSettings := map[string]string{
+			"DYNASTY_COMMIT_SHA":     b.Commit.SHA,
+			"DYNASTY_COMMIT_REF":     b.Commit.Ref,
+			"DYNASTY_COMMIT_BRANCH":  b.Commit.Branch,
+			"DYNASTY_COMMIT_TAG":     b.Commit.Tag,
+			"DYNASTY_COMMIT_MESSAGE": b.Commit.Message,
+			"DYNASTY_COMMIT_AUTHOR":  b.Commit.Author,
+			"DYNASTY_COMMIT_LINK":    b.Commit.Link,
+			"DYNASTY_EVENT":          b.Commit.Event,
+			"DYNASTY_TARGET_BRANCH":  b.Commit.TargetBranch,
+		}
+		if b.Commit.PullRequest > 0 {
+			commitSettings["DYNASTY_PULL_REQUEST"] = strconv.Itoa(b.Commit.PullRequest)
+		}
+		for k, v := range commitSettings {
+			if v != "" {
+				settings[k] = v
+			}
+		}
+	}
 	return settings
 }
 
+// Commit describes the revision of the source code that the build runs for.
+type Commit struct {
+	SHA string `json:"sha,omitempty" yaml:"sha,omitempty"`
+	// Ref is the full reference, e.g. refs/heads/main.
+	Ref     string `json:"ref,omitempty" yaml:"ref,omitempty"`
+	Branch  string `json:"branch,omitempty" yaml:"branch,omitempty"`
+	Tag     string `json:"tag,omitempty" yaml:"tag,omitempty"`
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+	Author  string `json:"author,omitempty" yaml:"author,omitempty"`
+	Link    string `json:"link,omitempty" yaml:"link,omitempty"`
+	// Event is the kind of the event triggers the build, e.g. push, tag or pull_request.
+	Event string `json:"event,omitempty" yaml:"event,omitempty"`
+	// PullRequest is the number of the pull request, and TargetBranch is the branch it merges into.
+	PullRequest  int    `json:"pullRequest,omitempty" yaml:"pullRequest,omitempty"`
+	TargetBranch string `json:"targetBranch,omitempty" yaml:"targetBranch,omitempty"`
+}
+
+// The events of the source code trigger the builds.
+const (
+	EventPush        = "push"
+	EventTag         = "tag"
+	EventPullRequest = "pull_request"
+)
+
 type Stage struct {
 	ID      uint64 `json:"id" yaml:"id"`
 	BoxID   uint64 `json:"boxID" yaml:"boxID"`
@@ -57,14 +106,157 @@ type Stage struct {
 	Started int64  `json:"started,omitempty" yaml:"started,omitempty"`
 	Stopped int64  `json:"stopped,omitempty" yaml:"stopped,omitempty"`
 	Error   string `json:"error,omitempty" yaml:"error,omitempty"`
+	// Attempt is the number of times the stage has been retried.
+	Attempt int `json:"attempt,omitempty" yaml:"attempt,omitempty"`
+	// NotBefore is the unix time before which the retried stage is not scheduled.
+	NotBefore int64 `json:"notBefore,omitempty" yaml:"notBefore,omitempty"`
 
 	WorkerName string   `json:"workerName,omitempty" yaml:"workerName,omitempty"`
 	Worker     Worker   `json:"worker,omitempty" yaml:"worker,omitempty"`
 	DependsOn  []string `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty"`
 
+	// Workflow is the name of the workflow expanded by the matrix,
+	// and Matrix is the combination of the stage.
+	Workflow string            `json:"workflow,omitempty" yaml:"workflow,omitempty"`
+	Matrix   map[string]string `json:"matrix,omitempty" yaml:"matrix,omitempty"`
+
+	// Approval is true if the stage waits for the approval before running.
+	Approval bool `json:"approval,omitempty" yaml:"approval,omitempty"`
+	// Approver is the one who approved or rejected the stage.
+	Approver string `json:"approver,omitempty" yaml:"approver,omitempty"`
+	// Comment is left by the approver.
+	Comment string `json:"comment,omitempty" yaml:"comment,omitempty"`
+
 	Steps []*Step `json:"steps,omitempty" yaml:"steps,omitempty"`
 }
 
+// StageApproval is the decision on the stage waiting for the approval.
+type StageApproval struct {
+	// Approver is replaced by the name of the token if the authentication is enabled.
+	Approver string `json:"approver,omitempty" yaml:"approver,omitempty"`
+	Comment  string `json:"comment,omitempty" yaml:"comment,omitempty"`
+}
+
+// NewStages creates the stages of the build by the workflows in order.
+// The stages depend on others are waiting, the stages requiring the approval
+// wait for it, and the stages whose workflows do not match the settings are skipped.
+func NewStages(workflows []*Workflow, settings map[string]string) []*Stage {
+	stages := make([]*Stage, 0, len(workflows))
+	for _, workflow := range workflows {
+		cells := []map[string]string{nil}
+		if workflow.Spec.Matrix != nil {
+			cells = workflow.Spec.Matrix.Cells()
+		}
+
+		for _, cell := range cells {
+			stage := &Stage{
+				Number:    uint64(len(stages)) + 1,
+				Name:      workflow.Name,
+				Limit:     workflow.Spec.Concurrency,
+				Worker:    *workflow.Worker(),
+				DependsOn: workflow.Spec.DependsOn,
+				Approval:  workflow.Spec.Approval == ApprovalRequired,
+			}
+			if cell != nil {
+				stage.Name = workflow.Spec.Matrix.CellName(workflow.Name, cell)
+				stage.Workflow = workflow.Name
+				stage.Matrix = cell
+				stage.Worker = workflow.Spec.Matrix.Worker(stage.Worker, cell)
+			}
+			stage.Phase = stage.ReadyPhase()
+			if len(stage.DependsOn) > 0 {
+				stage.Phase = PhaseWaiting
+			}
+			if !workflow.Spec.When.Match(settings) {
+				stage.Phase = PhaseSkipped
+			}
+
+			for sk, stepName := range workflow.Spec.StepNames() {
+				step := &Step{
+					Number: uint64(sk) + 1,
+					Phase:  PhasePending,
+					Name:   stepName,
+				}
+				if stage.Phase == PhaseSkipped {
+					step.Phase = PhaseSkipped
+				}
+				stage.Steps = append(stage.Steps, step)
+			}
+			stages = append(stages, stage)
+		}
+	}
+
+	// the dependencies may be skipped already.
+	ReleaseStages(stages)
+	return stages
+}
+
+// ReleaseStages moves the waiting stages whose dependencies are done to the ready phase.
+func ReleaseStages(stages []*Stage) {
+	for _, stage := range stages {
+		if stage.Phase == PhaseWaiting && stage.IsDepsDone(stages) {
+			stage.Phase = stage.ReadyPhase()
+		}
+	}
+}
+
+// ReadyPhase returns the phase of the stage once its dependencies are done.
+func (s *Stage) ReadyPhase() Phase {
+	if s.Approval {
+		return PhaseWaitingApproval
+	}
+	return PhasePending
+}
+
+// WorkflowName returns the name of the workflow of the stage.
+func (s *Stage) WorkflowName() string {
+	if s.Workflow != "" {
+		return s.Workflow
+	}
+	return s.Name
+}
+
+// ServiceLogID returns the id of the live log stream which receives the output of the stage services.
+func ServiceLogID(stageID uint64) string {
+	return "stage-" + strconv.FormatUint(stageID, 10) + "-services"
+}
+
+// isDep returns true if the stage depends on the other stage,
+// the stages expanded by the matrix are depended on by the workflow name or their own names.
+func (s *Stage) isDep(other *Stage) bool {
+	return slices.Contains(s.DependsOn, other.Name) ||
+		(other.Workflow != "" && slices.Contains(s.DependsOn, other.Workflow))
+}
+
+// IsDepsDone returns true if all dependencies of the stage are done.
+func (s *Stage) IsDepsDone(stages []*Stage) bool {
+	for _, sv := range stages {
+		if s.isDep(sv) && !sv.Phase.IsDone() {
+			return false
+		}
+	}
+	return true
+}
+
+// IsDepsFailed returns true if any dependency of the stage failed or was canceled,
+// then the stage is canceled instead of running.
+func (s *Stage) IsDepsFailed(stages []*Stage) bool {
+	for _, sv := range stages {
+		if s.isDep(sv) && (sv.Phase.IsFailed() || sv.Phase == PhaseCanceled) {
+			return true
+		}
+	}
+	return false
+}
+
+// Artifact describes a file uploaded by the step of the build.
+type Artifact struct {
+	Stage uint64 `json:"stage" yaml:"stage"`
+	Step  uint64 `json:"step" yaml:"step"`
+	Name  string `json:"name" yaml:"name"`
+	Size  int64  `json:"size" yaml:"size"`
+}
+
 type Step struct {
 	ID       uint64 `json:"id" yaml:"id"`
 	StageID  uint64 `json:"stageID" yaml:"stageID"`
@@ -0,0 +1,108 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AnyNamespace matches all namespaces in the role binding.
+const AnyNamespace = "*"
+
+// Role defines the permissions granted in the namespace.
+type Role string
+
+const (
+	// RoleViewer is allowed to read the resources except the secrets.
+	RoleViewer Role = "viewer"
+	// RoleEditor is additionally allowed to change the workflows and boxes, and to run the builds.
+	RoleEditor Role = "editor"
+	// RoleAdmin is additionally allowed to manage the secrets.
+	RoleAdmin Role = "admin"
+)
+
+func (r Role) level() int {
+	switch r {
+	case RoleViewer:
+		return 1
+	case RoleEditor:
+		return 2
+	case RoleAdmin:
+		return 3
+	}
+	return 0
+}
+
+func (r Role) Validate() error {
+	if r.level() == 0 {
+		return fmt.Errorf("unsupported role: %s", r)
+	}
+	return nil
+}
+
+// Includes returns true if the role has all permissions of the other role.
+func (r Role) Includes(other Role) bool {
+	return other.level() > 0 && r.level() >= other.level()
+}
+
+type RoleBinding struct {
+	// Namespace is the namespace the role is granted in, `*` means all namespaces.
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Role      Role   `json:"role" yaml:"role"`
+}
+
+// Allows returns true if the binding grants the role in the namespace,
+// the all namespace is only granted by the binding of any namespace.
+func (b *RoleBinding) Allows(namespace string, role Role) bool {
+	if b.Namespace != AnyNamespace && (namespace == AllNamespace || b.Namespace != namespace) {
+		return false
+	}
+	return b.Role.Includes(role)
+}
+
+type Token struct {
+	Name  string        `json:"name" yaml:"name"`
+	Roles []RoleBinding `json:"roles,omitempty" yaml:"roles,omitempty"`
+	// Token is the secret value of the token, it is only returned on creation.
+	Token    string    `json:"token,omitempty" yaml:"token,omitempty"`
+	Creation time.Time `json:"creation,omitempty" yaml:"creation,omitempty"`
+}
+
+func (t *Token) Validate() error {
+	if len(t.Name) == 0 {
+		return errors.New("token name must be defined")
+	}
+	for _, v := range t.Roles {
+		if len(v.Namespace) == 0 {
+			return errors.New("the namespace of the role must be defined")
+		}
+		if err := v.Role.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Allows returns true if any role of the token is granted in the namespace.
+func (t *Token) Allows(namespace string, role Role) bool {
+	for _, v := range t.Roles {
+		if v.Allows(namespace, role) {
+			return true
+		}
+	}
+	return false
+}
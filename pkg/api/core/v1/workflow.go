@@ -14,7 +14,17 @@
 
 package v1
 
-import "github.com/zc2638/ink/pkg/selector"
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/99nil/gopkg/cycle"
+
+	"github.com/zc2638/ink/pkg/selector"
+)
 
 type Workflow struct {
 	Metadata `yaml:",inline"`
@@ -31,8 +41,99 @@ func (w *Workflow) Worker() *Worker {
 	}
 }
 
+func (w *Workflow) Validate() error {
+	if w.Spec.When != nil {
+		if err := w.Spec.When.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if w.Spec.Timeout < 0 {
+		return fmt.Errorf("invalid timeout: %s", w.Spec.Timeout)
+	}
+	if err := w.Spec.Retry.Validate(); err != nil {
+		return err
+	}
+	switch w.Spec.Approval {
+	case "", ApprovalRequired:
+	default:
+		return fmt.Errorf("unsupported approval: %s", w.Spec.Approval)
+	}
+	if w.Spec.Matrix != nil {
+		if err := w.Spec.Matrix.Validate(); err != nil {
+			return err
+		}
+	}
+
+	names := make(map[string]struct{}, len(w.Spec.Steps)+1)
+	if w.Spec.Source != nil {
+		if err := w.Spec.Source.Validate(); err != nil {
+			return err
+		}
+		names[SourceStepName] = struct{}{}
+	}
+	for index, step := range w.Spec.Steps {
+		if step.Name == "" {
+			return fmt.Errorf("invalid step name at index: %d", index)
+		}
+		if _, ok := names[step.Name]; ok {
+			return fmt.Errorf("duplicate step name: %s", step.Name)
+		}
+		names[step.Name] = struct{}{}
+
+		switch step.RunPolicy {
+		case "", RunOnSuccess, RunOnFailure, RunAlways:
+		default:
+			return fmt.Errorf("step(%s): unsupported run policy: %s", step.Name, step.RunPolicy)
+		}
+		if step.When != nil {
+			if err := step.When.Validate(); err != nil {
+				return fmt.Errorf("step(%s): %v", step.Name, err)
+			}
+		}
+		if step.Timeout < 0 {
+			return fmt.Errorf("step(%s): invalid timeout: %s", step.Name, step.Timeout)
+		}
+		if err := step.Retry.Validate(); err != nil {
+			return fmt.Errorf("step(%s): %v", step.Name, err)
+		}
+		for _, pattern := range step.Artifacts {
+			if _, err := path.Match(pattern, ""); err != nil || path.IsAbs(pattern) {
+				return fmt.Errorf("step(%s): invalid artifact pattern: %s", step.Name, pattern)
+			}
+		}
+	}
+
+	graph := cycle.New()
+	for _, step := range w.Spec.Steps {
+		for _, dep := range step.DependsOn {
+			if _, ok := names[dep]; !ok {
+				return fmt.Errorf("step(%s): dependency not found: %s", step.Name, dep)
+			}
+		}
+		graph.Add(step.Name, step.DependsOn...)
+	}
+	if graph.DetectCycles() {
+		return errors.New("dependency cycle detected in steps")
+	}
+
+	// the services are reachable by name on the workflow network,
+	// so the names must not conflict with the steps.
+	for index, service := range w.Spec.Services {
+		if service.Name == "" {
+			return fmt.Errorf("invalid service name at index: %d", index)
+		}
+		if _, ok := names[service.Name]; ok {
+			return fmt.Errorf("duplicate service name: %s", service.Name)
+		}
+		names[service.Name] = struct{}{}
+	}
+	return nil
+}
+
 type WorkflowSpec struct {
 	Steps            []Flow             `json:"steps" yaml:"steps"`
+	Services         []Flow             `json:"services,omitempty" yaml:"services,omitempty"`
 	WorkingDir       string             `json:"workingDir,omitempty" yaml:"workingDir,omitempty"`
 	Concurrency      int                `json:"concurrency,omitempty" yaml:"concurrency,omitempty"`
 	Volumes          []Volume           `json:"volumes,omitempty" yaml:"volumes,omitempty"`
@@ -40,6 +141,29 @@ type WorkflowSpec struct {
 	ImagePullSecrets []string           `json:"imagePullSecrets,omitempty" yaml:"imagePullSecrets,omitempty"`
 	Worker           *Worker            `json:"worker,omitempty" yaml:"worker,omitempty"`
 	When             *selector.Selector `json:"when,omitempty" yaml:"when,omitempty"`
+	// Timeout defines the maximum duration of the workflow, no limit if it is zero.
+	Timeout Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// Retry defines the policy to re-queue the failed stage.
+	Retry *Retry `json:"retry,omitempty" yaml:"retry,omitempty"`
+	// Source defines the git repository cloned into the workspace before the steps.
+	Source *Source `json:"source,omitempty" yaml:"source,omitempty"`
+	// Approval defines whether the stage waits for the approval before running.
+	Approval ApprovalPolicy `json:"approval,omitempty" yaml:"approval,omitempty"`
+	// Matrix expands the workflow into the stages of the combinations.
+	Matrix *Matrix `json:"matrix,omitempty" yaml:"matrix,omitempty"`
+}
+
+// StepNames returns the names of the steps run by the workflow,
+// including the clone step of the source.
+func (s *WorkflowSpec) StepNames() []string {
+	names := make([]string, 0, len(s.Steps)+1)
+	if s.Source != nil {
+		names = append(names, SourceStepName)
+	}
+	for _, step := range s.Steps {
+		names = append(names, step.Name)
+	}
+	return names
 }
 
 type Flow struct {
@@ -58,8 +182,137 @@ type Flow struct {
 	DNS             []string       `json:"dns,omitempty" yaml:"dns,omitempty"`
 	DNSSearch       []string       `json:"dnsSearch,omitempty" yaml:"dnsSearch,omitempty"`
 	ExtraHosts      []string       `json:"extraHosts,omitempty" yaml:"extraHosts,omitempty"`
+
+	// When defines the condition evaluated against the build settings,
+	// the step is skipped if it is not matched.
+	When *selector.Selector `json:"when,omitempty" yaml:"when,omitempty"`
+	// RunPolicy defines whether the step runs according to the result of the previous steps.
+	RunPolicy RunPolicy `json:"runPolicy,omitempty" yaml:"runPolicy,omitempty"`
+	// DependsOn defines the names of the steps that must be completed before the step runs.
+	// If none of the steps defines it, the steps run serially in the defined order.
+	DependsOn []string `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty"`
+	// Timeout defines the maximum duration of the step, no limit if it is zero.
+	Timeout Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// Retry defines the policy to re-run the failed step.
+	Retry *Retry `json:"retry,omitempty" yaml:"retry,omitempty"`
+	// Artifacts defines the glob paths of the files to upload after the step,
+	// the relative paths are based on the working directory of the step.
+	Artifacts []string `json:"artifacts,omitempty" yaml:"artifacts,omitempty"`
+}
+
+// SourceStepName is the name of the step that clones the source.
+const SourceStepName = "clone"
+
+// Source defines the git repository of the workflow.
+type Source struct {
+	// URL is the address of the repository, over http(s) or ssh.
+	URL string `json:"url" yaml:"url"`
+	// Ref is the branch, tag, commit or full ref to checkout, the build settings
+	// can be referenced in it. It defaults to the commit of the build,
+	// and the default branch of the repository if the build has none.
+	Ref string `json:"ref,omitempty" yaml:"ref,omitempty"`
+	// Depth limits the fetched history to the number of commits, all if it is zero.
+	Depth int `json:"depth,omitempty" yaml:"depth,omitempty"`
+	// Submodules defines whether to checkout the submodules.
+	Submodules bool `json:"submodules,omitempty" yaml:"submodules,omitempty"`
+	// Secret is the name of the secret holds the credentials, the keys
+	// username and password are used over http(s), and privateKey over ssh.
+	// The host key of the ssh remote is verified by the knownHosts of the secret.
+	Secret string `json:"secret,omitempty" yaml:"secret,omitempty"`
+	// InsecureIgnoreHostKey skips the host key verification of the ssh remote,
+	// it only takes effect when the secret has no knownHosts.
+	InsecureIgnoreHostKey bool `json:"insecureIgnoreHostKey,omitempty" yaml:"insecureIgnoreHostKey,omitempty"`
+	// Image is the image of the clone container, only used by the docker worker.
+	Image string `json:"image,omitempty" yaml:"image,omitempty"`
+}
+
+func (s *Source) Validate() error {
+	if s.URL == "" {
+		return errors.New("source: url is required")
+	}
+	if s.Depth < 0 {
+		return fmt.Errorf("source: invalid depth: %d", s.Depth)
+	}
+	return nil
+}
+
+// Retry defines the policy to retry on failure.
+type Retry struct {
+	// Count is the maximum number of retries.
+	Count int `json:"count" yaml:"count"`
+	// Backoff is the duration to wait before each retry,
+	// it is doubled after every attempt.
+	Backoff Duration `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+	// ExitCodes limits the exit codes to retry on, any failure is retried if it is empty.
+	ExitCodes []int `json:"exitCodes,omitempty" yaml:"exitCodes,omitempty"`
+}
+
+func (r *Retry) Validate() error {
+	if r == nil {
+		return nil
+	}
+	if r.Count < 0 {
+		return fmt.Errorf("invalid retry count: %d", r.Count)
+	}
+	if r.Backoff < 0 {
+		return fmt.Errorf("invalid retry backoff: %s", r.Backoff)
+	}
+	return nil
+}
+
+// Match returns true if the attempt is allowed to be retried.
+// The exit code is ignored if it is less than zero, e.g. the hook failed.
+func (r *Retry) Match(attempt, exitCode int) bool {
+	if r == nil || attempt >= r.Count {
+		return false
+	}
+	if exitCode < 0 || len(r.ExitCodes) == 0 {
+		return true
+	}
+	return slices.Contains(r.ExitCodes, exitCode)
+}
+
+// Delay returns the duration to wait before the attempt.
+func (r *Retry) Delay(attempt int) time.Duration {
+	if r == nil || attempt < 1 {
+		return 0
+	}
+	// avoid overflowing with too many attempts.
+	return r.Backoff.Std() << min(attempt-1, 10)
+}
+
+type RunPolicy string
+
+func (s RunPolicy) String() string { return string(s) }
+
+const (
+	// RunOnSuccess means that the step only runs if all previous steps succeeded.
+	RunOnSuccess RunPolicy = "OnSuccess"
+	// RunOnFailure means that the step only runs if any previous step failed.
+	RunOnFailure RunPolicy = "OnFailure"
+	// RunAlways means that the step runs regardless of the result of the previous steps.
+	RunAlways RunPolicy = "Always"
+)
+
+// Match returns true if the step should run according to the result of the previous steps.
+func (s RunPolicy) Match(failed bool) bool {
+	switch s {
+	case RunAlways:
+		return true
+	case RunOnFailure:
+		return failed
+	default:
+		return !failed
+	}
 }
 
+type ApprovalPolicy string
+
+func (s ApprovalPolicy) String() string { return string(s) }
+
+// ApprovalRequired means that the stage runs only after it is approved.
+const ApprovalRequired ApprovalPolicy = "required"
+
 type PullPolicy string
 
 func (s PullPolicy) String() string { return string(s) }
@@ -81,6 +334,8 @@ type Volume struct {
 
 	HostPath *HostPathVolume `json:"hostPath,omitempty" `
 	EmptyDir *EmptyDirVolume `json:"emptyDir,omitempty" `
+	// Cache defines a directory kept across the builds of the same box by the worker.
+	Cache *CacheVolume `json:"cache,omitempty" `
 }
 
 type HostPathVolume struct {
@@ -101,6 +356,14 @@ type EmptyDirVolume struct {
 	SizeLimit BytesSize     `json:"sizeLimit,omitempty"`
 }
 
+// CacheVolume represents a directory shared by the builds of the same box,
+// the cache falls back to an empty dir on the workers that do not support it.
+type CacheVolume struct {
+	// Key distinguishes the caches of the same volume, the build settings
+	// can be referenced in it, e.g. go-${GO_VERSION}.
+	Key string `json:"key,omitempty"`
+}
+
 // StorageMedium defines ways that storage can be allocated to a volume.
 type StorageMedium string
 
@@ -0,0 +1,90 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestWorkflowValidateTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			data: `
+spec:
+  timeout: 10m
+  steps:
+    - name: test
+      timeout: 30s
+`,
+		},
+		{
+			name: "no limit",
+			data: `
+spec:
+  steps:
+    - name: test
+`,
+		},
+		{
+			name: "negative workflow timeout",
+			data: `
+spec:
+  timeout: -10m
+  steps:
+    - name: test
+`,
+			wantErr: true,
+		},
+		{
+			name: "negative step timeout",
+			data: `
+spec:
+  steps:
+    - name: test
+      timeout: -30s
+`,
+			wantErr: true,
+		},
+		{
+			name: "bare integer timeout",
+			data: `
+spec:
+  timeout: 600
+  steps:
+    - name: test
+`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w Workflow
+			err := yaml.Unmarshal([]byte(tt.data), &w)
+			if err == nil {
+				err = w.Validate()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
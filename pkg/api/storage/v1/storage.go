@@ -15,10 +15,15 @@
 package v1
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
 	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+	"github.com/zc2638/ink/pkg/keyring"
 )
 
 type Model struct {
@@ -56,6 +61,9 @@ type Secret struct {
 	Namespace string
 	Name      string
 	Data      string
+	// KeyID is the id of the key that encrypts the data,
+	// the data is stored in plaintext if it is empty.
+	KeyID string
 }
 
 func (s *Secret) TableName() string {
@@ -70,9 +78,48 @@ func (s *Secret) FromAPI(in *v1.Secret) error {
 		return err
 	}
 	s.Data = string(b)
+	s.KeyID = ""
 	return nil
 }
 
+// Encrypt encrypts the data by the primary key of the keyring,
+// the data is kept in plaintext if the keyring is nil.
+func (s *Secret) Encrypt(kr *keyring.Keyring) error {
+	if kr == nil || len(s.KeyID) > 0 {
+		return nil
+	}
+	keyID, data, err := kr.Encrypt([]byte(s.Data), s.additionalData())
+	if err != nil {
+		return fmt.Errorf("encrypt secret failed: %v", err)
+	}
+	s.KeyID = keyID
+	s.Data = data
+	return nil
+}
+
+// Decrypt restores the plaintext data if it is encrypted.
+func (s *Secret) Decrypt(kr *keyring.Keyring) error {
+	if len(s.KeyID) == 0 {
+		return nil
+	}
+	if kr == nil {
+		return errors.New("the secret is encrypted but no key is configured")
+	}
+	data, err := kr.Decrypt(s.KeyID, s.Data, s.additionalData())
+	if err != nil {
+		return fmt.Errorf("decrypt secret failed: %v", err)
+	}
+	s.KeyID = ""
+	s.Data = string(data)
+	return nil
+}
+
+// additionalData binds the encrypted data to the secret,
+// so that it cannot be moved to another secret.
+func (s *Secret) additionalData() []byte {
+	return []byte(s.Namespace + "/" + s.Name)
+}
+
 func (s *Secret) ToAPI() (*v1.Secret, error) {
 	var out v1.Secret
 	if err := json.Unmarshal([]byte(s.Data), &out); err != nil {
@@ -182,8 +229,10 @@ type Build struct {
 	Phase    string
 	Title    string
 	Settings string
+	Commit   string `gorm:"column:commit_info"`
 	Started  int64
 	Stopped  int64
+	RerunOf  uint64
 }
 
 func (s *Build) TableName() string {
@@ -197,6 +246,15 @@ func (s *Build) FromAPI(in *v1.Build) error {
 	}
 	s.Settings = string(settings)
 
+	s.Commit = ""
+	if in.Commit != nil {
+		commit, err := json.Marshal(in.Commit)
+		if err != nil {
+			return err
+		}
+		s.Commit = string(commit)
+	}
+
 	s.ID = in.ID
 	s.BoxID = in.BoxID
 	s.Number = in.Number
@@ -204,6 +262,7 @@ func (s *Build) FromAPI(in *v1.Build) error {
 	s.Started = in.Started
 	s.Stopped = in.Stopped
 	s.Title = in.Title
+	s.RerunOf = in.RerunOf
 	return nil
 }
 
@@ -221,6 +280,13 @@ func (s *Build) ToAPI() (*v1.Build, error) {
 		Settings: settings,
 		Started:  s.Started,
 		Stopped:  s.Stopped,
+		RerunOf:  s.RerunOf,
+	}
+	if s.Commit != "" {
+		result.Commit = new(v1.Commit)
+		if err := json.Unmarshal([]byte(s.Commit), result.Commit); err != nil {
+			return nil, err
+		}
 	}
 	return result, nil
 }
@@ -238,6 +304,14 @@ type Stage struct {
 	Started    int64
 	Stopped    int64
 	Error      string
+	Attempt    int
+	NotBefore  int64
+	DependsOn  string
+	Approval   bool
+	Approver   string
+	Comment    string
+	Workflow   string
+	Matrix     string
 }
 
 func (s *Stage) TableName() string {
@@ -260,24 +334,62 @@ func (s *Stage) FromAPI(in *v1.Stage) error {
 	s.Started = in.Started
 	s.Stopped = in.Stopped
 	s.Error = in.Error
+	s.Attempt = in.Attempt
+	s.NotBefore = in.NotBefore
+	s.DependsOn = ""
+	if len(in.DependsOn) > 0 {
+		dependsOn, err := json.Marshal(in.DependsOn)
+		if err != nil {
+			return err
+		}
+		s.DependsOn = string(dependsOn)
+	}
+	s.Approval = in.Approval
+	s.Approver = in.Approver
+	s.Comment = in.Comment
+	s.Workflow = in.Workflow
+	s.Matrix = ""
+	if len(in.Matrix) > 0 {
+		matrix, err := json.Marshal(in.Matrix)
+		if err != nil {
+			return err
+		}
+		s.Matrix = string(matrix)
+	}
 	return nil
 }
 
 func (s *Stage) ToAPI() (*v1.Stage, error) {
 	result := &v1.Stage{
-		ID:      s.ID,
-		BoxID:   s.BoxID,
-		BuildID: s.BuildID,
-		Number:  s.Number,
-		Phase:   v1.Phase(s.Phase),
-		Name:    s.Name,
-		Started: s.Started,
-		Stopped: s.Stopped,
-		Error:   s.Error,
+		ID:        s.ID,
+		BoxID:     s.BoxID,
+		BuildID:   s.BuildID,
+		Number:    s.Number,
+		Phase:     v1.Phase(s.Phase),
+		Name:      s.Name,
+		Started:   s.Started,
+		Stopped:   s.Stopped,
+		Error:     s.Error,
+		Attempt:   s.Attempt,
+		NotBefore: s.NotBefore,
+		Approval:  s.Approval,
+		Approver:  s.Approver,
+		Comment:   s.Comment,
+		Workflow:  s.Workflow,
 	}
 	if err := json.Unmarshal([]byte(s.Worker), &result.Worker); err != nil {
 		return nil, err
 	}
+	if s.DependsOn != "" {
+		if err := json.Unmarshal([]byte(s.DependsOn), &result.DependsOn); err != nil {
+			return nil, err
+		}
+	}
+	if s.Matrix != "" {
+		if err := json.Unmarshal([]byte(s.Matrix), &result.Matrix); err != nil {
+			return nil, err
+		}
+	}
 	return result, nil
 }
 
@@ -333,3 +445,134 @@ type Log struct {
 func (s *Log) TableName() string {
 	return "logs"
 }
+
+type Token struct {
+	Model
+
+	Name string
+	// Hash is the sha256 hash of the token value, the value itself is never stored.
+	Hash  string
+	Roles string
+}
+
+func (s *Token) TableName() string {
+	return "tokens"
+}
+
+// HashToken returns the hash of the token value stored in the database.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Token) FromAPI(in *v1.Token) error {
+	s.Name = in.Name
+	b, err := json.Marshal(in.Roles)
+	if err != nil {
+		return err
+	}
+	s.Roles = string(b)
+	return nil
+}
+
+func (s *Token) ToAPI() (*v1.Token, error) {
+	out := &v1.Token{
+		Name:     s.Name,
+		Creation: s.CreatedAt,
+	}
+	if len(s.Roles) > 0 {
+		if err := json.Unmarshal([]byte(s.Roles), &out.Roles); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// Schedule records the state of the schedule of the box.
+type Schedule struct {
+	Model
+
+	BoxID uint64 `gorm:"column:box_id"`
+	Name  string
+	// LastFired is the unix time the schedule was last fired.
+	LastFired int64
+}
+
+func (s *Schedule) TableName() string {
+	return "schedules"
+}
+
+type Notifier struct {
+	Model
+
+	Namespace string
+	Name      string
+	AllBoxes  bool
+	Data      string
+}
+
+func (s *Notifier) TableName() string {
+	return "notifiers"
+}
+
+func (s *Notifier) FromAPI(in *v1.Notifier) error {
+	s.Namespace = in.GetNamespace()
+	s.Name = in.GetName()
+	s.AllBoxes = in.Spec.AllBoxes
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	s.Data = string(b)
+	return nil
+}
+
+func (s *Notifier) ToAPI() (*v1.Notifier, error) {
+	var out v1.Notifier
+	if err := json.Unmarshal([]byte(s.Data), &out); err != nil {
+		return nil, err
+	}
+	out.Creation = s.CreatedAt
+	out.SetName(s.Name)
+	out.SetNamespace(s.Namespace)
+	out.SetKind(v1.KindNotifier)
+	return &out, nil
+}
+
+type Notification struct {
+	Model
+
+	BuildID  uint64 `gorm:"column:build_id"`
+	Notifier string
+	Event    string
+	Phase    string
+	Attempts int
+	Error    string
+}
+
+func (s *Notification) TableName() string {
+	return "notifications"
+}
+
+func (s *Notification) FromAPI(in *v1.Notification) {
+	s.ID = in.ID
+	s.BuildID = in.BuildID
+	s.Notifier = in.Notifier
+	s.Event = in.Event
+	s.Phase = in.Phase.String()
+	s.Attempts = in.Attempts
+	s.Error = in.Error
+}
+
+func (s *Notification) ToAPI() *v1.Notification {
+	return &v1.Notification{
+		ID:       s.ID,
+		BuildID:  s.BuildID,
+		Notifier: s.Notifier,
+		Event:    s.Event,
+		Phase:    v1.Phase(s.Phase),
+		Attempts: s.Attempts,
+		Error:    s.Error,
+		Creation: s.CreatedAt,
+	}
+}
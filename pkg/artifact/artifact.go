@@ -0,0 +1,68 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifact
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+// Artifact describes a file uploaded by the step.
+type Artifact struct {
+	// Name is the slash separated path relative to the working directory of the step.
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// Interface stores the artifacts of the steps.
+type Interface interface {
+	// List returns the artifacts of the step.
+	// If no artifact is found, an empty list is returned.
+	List(ctx context.Context, id uint64) ([]*Artifact, error)
+	// Open returns the content of the artifact.
+	Open(ctx context.Context, id uint64, name string) (io.ReadCloser, error)
+	// Save stores the artifact of the step, replacing the existing one with the same name.
+	Save(ctx context.Context, id uint64, name string, r io.Reader) error
+	// Delete removes all artifacts of the step.
+	Delete(ctx context.Context, id uint64) error
+}
+
+type Config struct {
+	File *ConfigFile `json:"file,omitempty"`
+}
+
+// New returns the artifact store defined in config.
+func New(cfg Config) (Interface, error) {
+	if cfg.File != nil {
+		return NewFile(*cfg.File)
+	}
+	return nil, errors.New("artifact store must be defined")
+}
+
+// ErrNotFound is returned when the artifact does not exist.
+var ErrNotFound = errors.New("artifact not found")
+
+// CleanName returns the cleaned slash separated name of the artifact,
+// the name must be relative and must not refer to the parent directory.
+func CleanName(name string) (string, error) {
+	name = path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return "", errors.New("invalid artifact name")
+	}
+	return name, nil
+}
@@ -0,0 +1,123 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifact
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+type ConfigFile struct {
+	Dir string `json:"dir"`
+}
+
+// NewFile returns an artifact store that keeps the artifacts of each step in a directory.
+func NewFile(cfg ConfigFile) (Interface, error) {
+	if len(cfg.Dir) == 0 {
+		return nil, errors.New("artifact dir must be defined")
+	}
+	if err := os.MkdirAll(cfg.Dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &file{dir: cfg.Dir}, nil
+}
+
+type file struct {
+	dir string
+}
+
+func (s *file) path(id uint64) string {
+	return filepath.Join(s.dir, strconv.FormatUint(id, 10))
+}
+
+func (s *file) List(_ context.Context, id uint64) ([]*Artifact, error) {
+	root := s.path(id)
+	result := make([]*Artifact, 0)
+	err := filepath.WalkDir(root, func(fp string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && fp == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(root, fp)
+		if err != nil {
+			return err
+		}
+		result = append(result, &Artifact{
+			Name: filepath.ToSlash(name),
+			Size: info.Size(),
+		})
+		return nil
+	})
+	return result, err
+}
+
+func (s *file) Open(_ context.Context, id uint64, name string) (io.ReadCloser, error) {
+	name, err := CleanName(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(s.path(id), filepath.FromSlash(name)))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (s *file) Save(_ context.Context, id uint64, name string, r io.Reader) error {
+	name, err := CleanName(name)
+	if err != nil {
+		return err
+	}
+	fp := filepath.Join(s.path(id), filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(fp), os.ModePerm); err != nil {
+		return err
+	}
+
+	// write to a temporary file outside the step directories first,
+	// to avoid reading a partial artifact.
+	f, err := os.CreateTemp(s.dir, "*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, fp)
+}
+
+func (s *file) Delete(_ context.Context, id uint64) error {
+	return os.RemoveAll(s.path(id))
+}
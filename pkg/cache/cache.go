@@ -0,0 +1,316 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	metaFile = "meta.json"
+	lockFile = "lock"
+	dataDir  = "data"
+)
+
+// Cache describes a directory kept across the builds of the same box.
+type Cache struct {
+	ID        string `json:"id"`
+	Namespace string `json:"namespace"`
+	Box       string `json:"box"`
+	Volume    string `json:"volume"`
+	Key       string `json:"key,omitempty"`
+
+	Size     int64     `json:"-"`
+	LastUsed time.Time `json:"-"`
+}
+
+type Config struct {
+	// Dir is the directory to store the caches.
+	Dir string `json:"dir,omitempty"`
+	// MaxSize is the maximum total size of the caches in bytes, no limit if it is zero.
+	MaxSize int64 `json:"maxSize,omitempty"`
+	// MaxAge is the maximum duration since the cache was last used, no limit if it is zero.
+	MaxAge time.Duration `json:"maxAge,omitempty"`
+}
+
+// ErrInUse is returned when removing the cache used by the builds.
+var ErrInUse = errors.New("cache is in use")
+
+// DefaultDir returns the default directory to store the caches.
+func DefaultDir() string {
+	return filepath.Join(os.TempDir(), "ink", "caches")
+}
+
+// ID returns the identity of the cache.
+func ID(namespace, box, volume, key string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{namespace, box, volume, key}, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+func New(cfg Config) (*Manager, error) {
+	if len(cfg.Dir) == 0 {
+		cfg.Dir = DefaultDir()
+	}
+	if err := os.MkdirAll(cfg.Dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &Manager{cfg: cfg, locks: make(map[string][]*os.File)}, nil
+}
+
+// Manager manages the caches stored in the local directory.
+// The cache in use holds the shared lock of its lock file,
+// so that it is not removed by the managers of the other processes.
+type Manager struct {
+	cfg Config
+
+	mux   sync.Mutex
+	locks map[string][]*os.File
+}
+
+// Acquire returns the data directory of the cache and creates it if not exist,
+// the cache is not evicted or removed until it is released.
+func (m *Manager) Acquire(c *Cache) (string, error) {
+	c.ID = ID(c.Namespace, c.Box, c.Volume, c.Key)
+
+	dir := filepath.Join(m.cfg.Dir, c.ID)
+	f, err := acquireLock(dir)
+	if err != nil {
+		return "", err
+	}
+
+	dataPath := filepath.Join(dir, dataDir)
+	if err := os.MkdirAll(dataPath, os.ModePerm); err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	// the modification time of the meta file records the last usage.
+	if err := os.WriteFile(filepath.Join(dir, metaFile), b, 0o644); err != nil {
+		_ = f.Close()
+		return "", err
+	}
+
+	m.mux.Lock()
+	m.locks[c.ID] = append(m.locks[c.ID], f)
+	m.mux.Unlock()
+	return dataPath, nil
+}
+
+// Release marks the cache as no longer used by the build.
+func (m *Manager) Release(id string) error {
+	m.mux.Lock()
+	if locks := m.locks[id]; len(locks) > 0 {
+		// closing the file releases the lock.
+		_ = locks[len(locks)-1].Close()
+		if len(locks) > 1 {
+			m.locks[id] = locks[:len(locks)-1]
+		} else {
+			delete(m.locks, id)
+		}
+	}
+	m.mux.Unlock()
+
+	now := time.Now()
+	return os.Chtimes(filepath.Join(m.cfg.Dir, id, metaFile), now, now)
+}
+
+// List returns the caches ordered by the last usage, the most recently used first.
+func (m *Manager) List() ([]*Cache, error) {
+	entries, err := os.ReadDir(m.cfg.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	caches := make([]*Cache, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(m.cfg.Dir, entry.Name())
+		c, err := readCache(dir)
+		if err != nil {
+			// skip the directories that are not created by the manager.
+			continue
+		}
+		c.Size = dirSize(filepath.Join(dir, dataDir))
+		caches = append(caches, c)
+	}
+	slices.SortFunc(caches, func(a, b *Cache) int {
+		return b.LastUsed.Compare(a.LastUsed)
+	})
+	return caches, nil
+}
+
+// Remove deletes the cache, the cache in use cannot be removed.
+func (m *Manager) Remove(id string) error {
+	ok, err := removeUnused(filepath.Join(m.cfg.Dir, id))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrInUse, id)
+	}
+	return nil
+}
+
+// Evict removes the caches unused for longer than the max age,
+// and then removes the least recently used caches until the total size
+// is within the max size. The caches in use are never evicted.
+func (m *Manager) Evict() ([]*Cache, error) {
+	if m.cfg.MaxAge <= 0 && m.cfg.MaxSize <= 0 {
+		return nil, nil
+	}
+	caches, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, c := range caches {
+		total += c.Size
+	}
+
+	var evicted []*Cache
+	for i := len(caches) - 1; i >= 0; i-- {
+		c := caches[i]
+		expired := m.cfg.MaxAge > 0 && time.Since(c.LastUsed) > m.cfg.MaxAge
+		oversize := m.cfg.MaxSize > 0 && total > m.cfg.MaxSize
+		if !expired && !oversize {
+			continue
+		}
+		ok, err := removeUnused(filepath.Join(m.cfg.Dir, c.ID))
+		if err != nil {
+			return evicted, err
+		}
+		if !ok {
+			continue
+		}
+		total -= c.Size
+		evicted = append(evicted, c)
+	}
+	return evicted, nil
+}
+
+// acquireLock holds the shared lock of the cache directory and creates it if not exist.
+func acquireLock(dir string) (*os.File, error) {
+	fp := filepath.Join(dir, lockFile)
+	for {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(fp, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		if err := lockShared(f); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		// retry if the lock file is removed while waiting for the lock.
+		fi, err := f.Stat()
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		if info, err := os.Stat(fp); err == nil && os.SameFile(fi, info) {
+			return f, nil
+		}
+		_ = f.Close()
+	}
+}
+
+// removeUnused deletes the cache directory if its lock is not held by the others,
+// it returns false if the cache is in use.
+func removeUnused(dir string) (bool, error) {
+	f, err := os.OpenFile(filepath.Join(dir, lockFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+
+	ok, err := tryLockExclusive(f)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err := os.RemoveAll(filepath.Join(dir, dataDir)); err != nil {
+		return false, err
+	}
+	if err := os.Remove(filepath.Join(dir, metaFile)); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	// the lock file cannot be removed while it is open on windows,
+	// it is kept and reused by the next acquisition.
+	_ = os.Remove(filepath.Join(dir, lockFile))
+	_ = os.Remove(dir)
+	return true, nil
+}
+
+func readCache(dir string) (*Cache, error) {
+	fp := filepath.Join(dir, metaFile)
+	info, err := os.Stat(fp)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(fp)
+	if err != nil {
+		return nil, err
+	}
+	var c Cache
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	if c.ID != filepath.Base(dir) {
+		return nil, fmt.Errorf("mismatched cache id: %s", c.ID)
+	}
+	c.LastUsed = info.ModTime()
+	return &c, nil
+}
+
+// dirSize returns the total size of the regular files in the directory,
+// the files that cannot be read are ignored.
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
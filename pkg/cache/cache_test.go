@@ -0,0 +1,148 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func acquire(t *testing.T, m *Manager, volume string, size int, lastUsed time.Time) *Cache {
+	t.Helper()
+	c := &Cache{Namespace: "default", Box: "test", Volume: volume}
+	dataPath, err := m.Acquire(c)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dataPath, "file"), make([]byte, size), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Release(c.ID); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if err := os.Chtimes(filepath.Join(m.cfg.Dir, c.ID, metaFile), lastUsed, lastUsed); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestManagerAcquire(t *testing.T) {
+	dir := t.TempDir()
+	m, err := New(Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the other manager shares the directory like another process.
+	other, err := New(Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Cache{Namespace: "default", Box: "test", Volume: "cache"}
+	for i := 0; i < 2; i++ {
+		if _, err := m.Acquire(c); err != nil {
+			t.Fatalf("Acquire() error = %v", err)
+		}
+	}
+	if err := other.Remove(c.ID); !errors.Is(err, ErrInUse) {
+		t.Fatal("Remove() expected the cache in use to be kept")
+	}
+	if err := m.Release(c.ID); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if err := other.Remove(c.ID); !errors.Is(err, ErrInUse) {
+		t.Fatal("Remove() expected the cache acquired twice to be kept after one release")
+	}
+	if err := m.Release(c.ID); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if err := other.Remove(c.ID); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	caches, err := m.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(caches) != 0 {
+		t.Fatalf("List() = %d caches, want 0", len(caches))
+	}
+
+	// the removed cache can be acquired again.
+	if _, err := m.Acquire(c); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if err := m.Release(c.ID); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+}
+
+func TestManagerEvict(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		maxSize int64
+		maxAge  time.Duration
+		using   string
+		want    []string
+	}{
+		{name: "no limit"},
+		{name: "size", maxSize: 15, want: []string{"old", "middle"}},
+		{name: "size in use", maxSize: 15, using: "old", want: []string{"middle", "new"}},
+		{name: "age", maxAge: 90 * time.Minute, want: []string{"old"}},
+		{name: "age in use", maxAge: 90 * time.Minute, using: "old"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(Config{Dir: t.TempDir(), MaxSize: tt.maxSize, MaxAge: tt.maxAge})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.using != "" {
+				c := &Cache{Namespace: "default", Box: "test", Volume: tt.using}
+				if _, err := m.Acquire(c); err != nil {
+					t.Fatalf("Acquire() error = %v", err)
+				}
+				defer m.Release(c.ID)
+			}
+			acquire(t, m, "old", 10, now.Add(-2*time.Hour))
+			acquire(t, m, "middle", 10, now.Add(-time.Hour))
+			acquire(t, m, "new", 10, now)
+
+			evicted, err := m.Evict()
+			if err != nil {
+				t.Fatalf("Evict() error = %v", err)
+			}
+			var got []string
+			for _, c := range evicted {
+				got = append(got, c.Volume)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Evict() = %v, want %v", got, tt.want)
+			}
+
+			caches, err := m.List()
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if len(caches)+len(evicted) != 3 {
+				t.Fatalf("List() = %d caches, want %d", len(caches), 3-len(evicted))
+			}
+		})
+	}
+}
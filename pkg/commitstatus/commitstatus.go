@@ -0,0 +1,147 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commitstatus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+)
+
+// DefaultContext is the name of the build status if the context is not defined.
+const DefaultContext = "ink"
+
+// maxDescriptionLength is the limit of the description in github.
+const maxDescriptionLength = 140
+
+// State is the generic state of the commit status,
+// it is converted to the state supported by each provider.
+type State string
+
+const (
+	StatePending  State = "pending"
+	StateRunning  State = "running"
+	StateSuccess  State = "success"
+	StateFailure  State = "failure"
+	StateCanceled State = "canceled"
+	StateError    State = "error"
+)
+
+// StateOf returns the state of the phase.
+func StateOf(phase v1.Phase) State {
+	switch {
+	case phase == v1.PhaseRunning:
+		return StateRunning
+	case phase.IsSucceeded(), phase == v1.PhaseSkipped:
+		return StateSuccess
+	case phase.IsFailed():
+		return StateFailure
+	case phase == v1.PhaseCanceled:
+		return StateCanceled
+	case phase == v1.PhaseWaiting, phase == v1.PhaseWaitingApproval, phase == v1.PhasePending:
+		return StatePending
+	}
+	return StateError
+}
+
+// Status is the status of the commit.
+type Status struct {
+	SHA         string
+	State       State
+	Context     string
+	Description string
+	TargetURL   string
+}
+
+// Reporter posts the statuses to the commits of the repository.
+type Reporter interface {
+	Report(ctx context.Context, status *Status) error
+}
+
+// New returns the reporter of the provider, the token is the access token of the provider.
+func New(cfg *v1.BoxCommitStatus, token string) (Reporter, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	c := &client{
+		url:        strings.TrimSuffix(cfg.URL, "/"),
+		repository: cfg.Repository,
+		token:      token,
+	}
+	switch cfg.Provider {
+	case "github":
+		if c.url == "" {
+			c.url = "https://api.github.com"
+		}
+		return &github{client: c}, nil
+	case "gitlab":
+		if c.url == "" {
+			c.url = "https://gitlab.com"
+		}
+		return &gitlab{client: c}, nil
+	case "gitea":
+		return &gitea{client: c}, nil
+	}
+	return nil, fmt.Errorf("unsupported provider: %s", cfg.Provider)
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+type client struct {
+	url        string
+	repository string
+	token      string
+}
+
+func (c *client) post(ctx context.Context, uri string, headers map[string]string, payload any) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+uri, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+func truncate(s string) string {
+	if len(s) <= maxDescriptionLength {
+		return s
+	}
+	return s[:maxDescriptionLength-3] + "..."
+}
@@ -0,0 +1,183 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commitstatus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+)
+
+const (
+	testToken = "t0ken"
+	testSHA   = "6113728f27ae82c7b1a177c8d03f9e96e0adf246"
+)
+
+type request struct {
+	Path   string
+	Header string
+	Body   map[string]string
+}
+
+// newServer stands in for the api of the provider, and records the requests.
+func newServer(t *testing.T, header string, requests *[]request) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		*requests = append(*requests, request{Path: r.URL.EscapedPath(), Header: r.Header.Get(header), Body: body})
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestReport(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		header   string
+		want     []request
+	}{
+		{
+			name:     "github",
+			provider: "github",
+			header:   "Authorization",
+			want: []request{
+				{
+					Path:   "/repos/octo/hello/statuses/" + testSHA,
+					Header: "Bearer " + testToken,
+					Body:   map[string]string{"state": "pending", "context": "ink", "description": "Build #1 Running"},
+				},
+				{
+					Path:   "/repos/octo/hello/statuses/" + testSHA,
+					Header: "Bearer " + testToken,
+					Body:   map[string]string{"state": "failure", "context": "ink/test", "description": "Stage test Failed"},
+				},
+				{
+					Path:   "/repos/octo/hello/statuses/" + testSHA,
+					Header: "Bearer " + testToken,
+					Body:   map[string]string{"state": "error", "context": "ink", "description": "Build #1 Canceled"},
+				},
+			},
+		},
+		{
+			name:     "gitea",
+			provider: "gitea",
+			header:   "Authorization",
+			want: []request{
+				{
+					Path:   "/api/v1/repos/octo/hello/statuses/" + testSHA,
+					Header: "token " + testToken,
+					Body:   map[string]string{"state": "pending", "context": "ink", "description": "Build #1 Running"},
+				},
+				{
+					Path:   "/api/v1/repos/octo/hello/statuses/" + testSHA,
+					Header: "token " + testToken,
+					Body:   map[string]string{"state": "failure", "context": "ink/test", "description": "Stage test Failed"},
+				},
+				{
+					Path:   "/api/v1/repos/octo/hello/statuses/" + testSHA,
+					Header: "token " + testToken,
+					Body:   map[string]string{"state": "error", "context": "ink", "description": "Build #1 Canceled"},
+				},
+			},
+		},
+		{
+			name:     "gitlab",
+			provider: "gitlab",
+			header:   "PRIVATE-TOKEN",
+			want: []request{
+				{
+					Path:   "/api/v4/projects/octo%2Fhello/statuses/" + testSHA,
+					Header: testToken,
+					Body:   map[string]string{"state": "running", "name": "ink", "description": "Build #1 Running"},
+				},
+				{
+					Path:   "/api/v4/projects/octo%2Fhello/statuses/" + testSHA,
+					Header: testToken,
+					Body:   map[string]string{"state": "failed", "name": "ink/test", "description": "Stage test Failed"},
+				},
+				{
+					Path:   "/api/v4/projects/octo%2Fhello/statuses/" + testSHA,
+					Header: testToken,
+					Body:   map[string]string{"state": "canceled", "name": "ink", "description": "Build #1 Canceled"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []request
+			srv := newServer(t, tt.header, &got)
+
+			reporter, err := New(&v1.BoxCommitStatus{
+				Provider:   tt.provider,
+				URL:        srv.URL + "/",
+				Repository: "octo/hello",
+				Secret:     "test",
+			}, testToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			statuses := []*Status{
+				{SHA: testSHA, State: StateOf(v1.PhaseRunning), Context: "ink", Description: "Build #1 Running"},
+				{SHA: testSHA, State: StateOf(v1.PhaseFailed), Context: "ink/test", Description: "Stage test Failed"},
+				{SHA: testSHA, State: StateOf(v1.PhaseCanceled), Context: "ink", Description: "Build #1 Canceled"},
+			}
+			for _, status := range statuses {
+				if err := reporter.Report(context.Background(), status); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Report() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReportError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"message":"Bad credentials"}`))
+	}))
+	defer srv.Close()
+
+	reporter, err := New(&v1.BoxCommitStatus{
+		Provider:   "github",
+		URL:        srv.URL,
+		Repository: "octo/hello",
+		Secret:     "test",
+	}, "invalid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = reporter.Report(context.Background(), &Status{SHA: testSHA, State: StatePending, Context: "ink"})
+	if err == nil {
+		t.Fatal("Report() expected the error of the unauthorized response")
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commitstatus
+
+import (
+	"context"
+	"net/url"
+)
+
+// githubState converts the state to the state of github and gitea,
+// which support pending, success, failure and error.
+func githubState(state State) string {
+	switch state {
+	case StatePending, StateRunning:
+		return "pending"
+	case StateSuccess:
+		return "success"
+	case StateFailure:
+		return "failure"
+	}
+	return "error"
+}
+
+type githubStatus struct {
+	State       string `json:"state"`
+	TargetURL   string `json:"target_url,omitempty"`
+	Description string `json:"description,omitempty"`
+	Context     string `json:"context"`
+}
+
+func newGitHubStatus(status *Status) *githubStatus {
+	return &githubStatus{
+		State:       githubState(status.State),
+		TargetURL:   status.TargetURL,
+		Description: truncate(status.Description),
+		Context:     status.Context,
+	}
+}
+
+type github struct {
+	*client
+}
+
+// Report creates the status by https://docs.github.com/en/rest/commits/statuses
+func (c *github) Report(ctx context.Context, status *Status) error {
+	uri := "/repos/" + c.repository + "/statuses/" + url.PathEscape(status.SHA)
+	headers := map[string]string{
+		"Accept":        "application/vnd.github+json",
+		"Authorization": "Bearer " + c.token,
+	}
+	return c.post(ctx, uri, headers, newGitHubStatus(status))
+}
+
+type gitea struct {
+	*client
+}
+
+// Report creates the status by the api of gitea compatible with github.
+func (c *gitea) Report(ctx context.Context, status *Status) error {
+	uri := "/api/v1/repos/" + c.repository + "/statuses/" + url.PathEscape(status.SHA)
+	headers := map[string]string{"Authorization": "token " + c.token}
+	return c.post(ctx, uri, headers, newGitHubStatus(status))
+}
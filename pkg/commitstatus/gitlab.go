@@ -0,0 +1,55 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commitstatus
+
+import (
+	"context"
+	"net/url"
+)
+
+// gitlabState converts the state to the state of gitlab.
+func gitlabState(state State) string {
+	switch state {
+	case StatePending:
+		return "pending"
+	case StateRunning:
+		return "running"
+	case StateSuccess:
+		return "success"
+	case StateCanceled:
+		return "canceled"
+	}
+	return "failed"
+}
+
+type gitlab struct {
+	*client
+}
+
+// Report creates the status by https://docs.gitlab.com/ee/api/commits.html#set-the-pipeline-status-of-a-commit
+// The repository is the path with namespace or the id of the project.
+func (c *gitlab) Report(ctx context.Context, status *Status) error {
+	uri := "/api/v4/projects/" + url.PathEscape(c.repository) + "/statuses/" + url.PathEscape(status.SHA)
+	headers := map[string]string{"PRIVATE-TOKEN": c.token}
+	payload := map[string]string{
+		"state":       gitlabState(status.State),
+		"name":        status.Context,
+		"description": truncate(status.Description),
+	}
+	if status.TargetURL != "" {
+		payload["target_url"] = status.TargetURL
+	}
+	return c.post(ctx, uri, headers, payload)
+}
@@ -0,0 +1,40 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyring
+
+import (
+	"context"
+	"net/http"
+)
+
+type key struct{}
+
+// WithContext returns a new context with the provided keyring.
+func WithContext(ctx context.Context, k *Keyring) context.Context {
+	return context.WithValue(ctx, key{}, k)
+}
+
+// FromContext retrieves the current keyring from the context. If no
+// keyring is available, the nil value is returned.
+func FromContext(ctx context.Context) *Keyring {
+	v, _ := ctx.Value(key{}).(*Keyring)
+	return v
+}
+
+// FromRequest retrieves the current keyring from the request. If no
+// keyring is available, the nil value is returned.
+func FromRequest(r *http.Request) *Keyring {
+	return FromContext(r.Context())
+}
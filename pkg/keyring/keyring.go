@@ -0,0 +1,189 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyring
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// dataKeySize is the size of the data key generated for each encryption.
+const dataKeySize = 32
+
+type Config struct {
+	// Primary is the id of the key to encrypt the data, defaults to the last key.
+	Primary string `json:"primary,omitempty"`
+	// Keys are the master keys, the previous keys are kept
+	// to decrypt the data that has not been rotated yet.
+	Keys []Key `json:"keys,omitempty"`
+}
+
+type Key struct {
+	ID string `json:"id"`
+	// Secret is the base64 encoded AES key of 16, 24 or 32 bytes.
+	Secret string `json:"secret"`
+}
+
+// New returns the keyring defined in config.
+// If no key is defined, the nil value is returned.
+func New(cfg Config) (*Keyring, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, nil
+	}
+
+	k := &Keyring{keys: make(map[string]cipher.AEAD, len(cfg.Keys))}
+	for _, v := range cfg.Keys {
+		if len(v.ID) == 0 || strings.Contains(v.ID, ".") {
+			return nil, fmt.Errorf("invalid key id: %s", v.ID)
+		}
+		if _, ok := k.keys[v.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id: %s", v.ID)
+		}
+		secret, err := base64.StdEncoding.DecodeString(v.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("decode key(%s) failed: %v", v.ID, err)
+		}
+		aead, err := newAEAD(secret)
+		if err != nil {
+			return nil, fmt.Errorf("init key(%s) failed: %v", v.ID, err)
+		}
+		k.keys[v.ID] = aead
+		k.primary = v.ID
+	}
+	if len(cfg.Primary) > 0 {
+		if _, ok := k.keys[cfg.Primary]; !ok {
+			return nil, fmt.Errorf("primary key not found: %s", cfg.Primary)
+		}
+		k.primary = cfg.Primary
+	}
+	return k, nil
+}
+
+// Keyring encrypts the data with AES-GCM envelope encryption,
+// each data is encrypted by a random data key which is encrypted by the master key.
+type Keyring struct {
+	primary string
+	keys    map[string]cipher.AEAD
+}
+
+// Primary returns the id of the key to encrypt the data.
+func (k *Keyring) Primary() string {
+	return k.primary
+}
+
+// Encrypt encrypts the data by the primary key,
+// and returns the key id and the ciphertext which must be stored together.
+// The additional data, e.g. the identity of the record, must be the same to decrypt.
+func (k *Keyring) Encrypt(data, additional []byte) (string, string, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", "", err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	// the key id is bound to the ciphertext with the additional data,
+	// so that the ciphertext cannot be attributed to another key or record.
+	aad := buildAAD(k.primary, additional)
+	encryptedKey, err := seal(k.keys[k.primary], dataKey, aad)
+	if err != nil {
+		return "", "", err
+	}
+	encryptedData, err := seal(aead, data, aad)
+	if err != nil {
+		return "", "", err
+	}
+	ciphertext := base64.RawStdEncoding.EncodeToString(encryptedKey) + "." +
+		base64.RawStdEncoding.EncodeToString(encryptedData)
+	return k.primary, ciphertext, nil
+}
+
+// Decrypt decrypts the ciphertext encrypted by the key of the id with the additional data.
+func (k *Keyring) Decrypt(id string, ciphertext string, additional []byte) ([]byte, error) {
+	kek, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("key not found: %s", id)
+	}
+
+	keyPart, dataPart, ok := strings.Cut(ciphertext, ".")
+	if !ok {
+		return nil, errors.New("invalid ciphertext")
+	}
+	encryptedKey, err := base64.RawStdEncoding.DecodeString(keyPart)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext: %v", err)
+	}
+	encryptedData, err := base64.RawStdEncoding.DecodeString(dataPart)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext: %v", err)
+	}
+
+	aad := buildAAD(id, additional)
+	dataKey, err := open(kek, encryptedKey, aad)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt data key failed: %v", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := open(aead, encryptedData, aad)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt data failed: %v", err)
+	}
+	return data, nil
+}
+
+// buildAAD joins the key id and the additional data,
+// the key id never contains the separator.
+func buildAAD(id string, additional []byte) []byte {
+	aad := make([]byte, 0, len(id)+1+len(additional))
+	aad = append(aad, id...)
+	aad = append(aad, '.')
+	return append(aad, additional...)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns the nonce followed by the encrypted data.
+func seal(aead cipher.AEAD, data, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, aad), nil
+}
+
+func open(aead cipher.AEAD, data, aad []byte) ([]byte, error) {
+	size := aead.NonceSize()
+	if len(data) < size {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, data[:size], data[size:], aad)
+}
@@ -0,0 +1,133 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyring_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/zc2638/ink/pkg/keyring"
+)
+
+func secret(size int) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", size)))
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         keyring.Config
+		wantNil     bool
+		wantPrimary string
+		wantErr     bool
+	}{
+		{name: "no key", wantNil: true},
+		{
+			name:        "last key is primary",
+			cfg:         keyring.Config{Keys: []keyring.Key{{ID: "v1", Secret: secret(16)}, {ID: "v2", Secret: secret(32)}}},
+			wantPrimary: "v2",
+		},
+		{
+			name:        "primary",
+			cfg:         keyring.Config{Primary: "v1", Keys: []keyring.Key{{ID: "v1", Secret: secret(24)}, {ID: "v2", Secret: secret(32)}}},
+			wantPrimary: "v1",
+		},
+		{
+			name:    "primary not found",
+			cfg:     keyring.Config{Primary: "v3", Keys: []keyring.Key{{ID: "v1", Secret: secret(32)}}},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate id",
+			cfg:     keyring.Config{Keys: []keyring.Key{{ID: "v1", Secret: secret(32)}, {ID: "v1", Secret: secret(32)}}},
+			wantErr: true,
+		},
+		{name: "empty id", cfg: keyring.Config{Keys: []keyring.Key{{Secret: secret(32)}}}, wantErr: true},
+		{name: "dotted id", cfg: keyring.Config{Keys: []keyring.Key{{ID: "v.1", Secret: secret(32)}}}, wantErr: true},
+		{name: "bad key size", cfg: keyring.Config{Keys: []keyring.Key{{ID: "v1", Secret: secret(20)}}}, wantErr: true},
+		{name: "bad base64", cfg: keyring.Config{Keys: []keyring.Key{{ID: "v1", Secret: "!"}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := keyring.New(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (got == nil) != tt.wantNil {
+				t.Fatalf("New() = %v, wantNil %v", got, tt.wantNil)
+			}
+			if got != nil && got.Primary() != tt.wantPrimary {
+				t.Fatalf("New() primary = %s, want %s", got.Primary(), tt.wantPrimary)
+			}
+		})
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	kr, err := keyring.New(keyring.Config{Keys: []keyring.Key{{ID: "v1", Secret: secret(32)}, {ID: "v2", Secret: secret(16)}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	aad := []byte("test/secret")
+	id, ciphertext, err := kr.Encrypt([]byte("data"), aad)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if id != "v2" {
+		t.Fatalf("Encrypt() id = %s, want v2", id)
+	}
+	if strings.Contains(ciphertext, "data") {
+		t.Fatalf("Encrypt() ciphertext contains the plaintext: %s", ciphertext)
+	}
+	data, err := kr.Decrypt(id, ciphertext, aad)
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if string(data) != "data" {
+		t.Fatalf("Decrypt() = %s, want data", data)
+	}
+
+	keyPart, dataPart, _ := strings.Cut(ciphertext, ".")
+	tampered, err := base64.RawStdEncoding.DecodeString(dataPart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered[len(tampered)-1] ^= 1
+	tests := []struct {
+		name       string
+		id         string
+		ciphertext string
+		aad        []byte
+	}{
+		{name: "wrong key id", id: "v1", ciphertext: ciphertext, aad: aad},
+		{name: "unknown key id", id: "v3", ciphertext: ciphertext, aad: aad},
+		{name: "other record", id: id, ciphertext: ciphertext, aad: []byte("test/other")},
+		{name: "tampered data", id: id, ciphertext: keyPart + "." + base64.RawStdEncoding.EncodeToString(tampered), aad: aad},
+		{name: "swapped parts", id: id, ciphertext: dataPart + "." + keyPart, aad: aad},
+		{name: "no separator", id: id, ciphertext: keyPart, aad: aad},
+		{name: "bad base64", id: id, ciphertext: keyPart + ".!", aad: aad},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := kr.Decrypt(tt.id, tt.ciphertext, tt.aad); err == nil {
+				t.Fatal("Decrypt() expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logstore
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	storageV1 "github.com/zc2638/ink/pkg/api/storage/v1"
+	"github.com/zc2638/ink/pkg/livelog"
+)
+
+// NewDatabase returns a log store backed by the `logs` table.
+func NewDatabase(db *gorm.DB, maxSize int64) Interface {
+	return &database{db: db, maxSize: maxSize}
+}
+
+type database struct {
+	db      *gorm.DB
+	maxSize int64
+}
+
+func (s *database) Find(ctx context.Context, id uint64) ([]*livelog.Line, error) {
+	logS := new(storageV1.Log)
+	logS.SetID(id)
+	err := s.db.WithContext(ctx).Where(logS).First(logS).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return make([]*livelog.Line, 0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Decode(logS.Data)
+}
+
+func (s *database) Save(ctx context.Context, id uint64, lines []*livelog.Line) error {
+	data, err := Encode(Truncate(lines, s.maxSize))
+	if err != nil {
+		return err
+	}
+
+	logS := new(storageV1.Log)
+	logS.SetID(id)
+	logS.Data = data
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		where := new(storageV1.Log)
+		where.SetID(id)
+		if err := tx.Where(where).Delete(where).Error; err != nil {
+			return err
+		}
+		return tx.Create(logS).Error
+	})
+}
+
+func (s *database) Delete(ctx context.Context, id uint64) error {
+	where := new(storageV1.Log)
+	where.SetID(id)
+	return s.db.WithContext(ctx).Where(where).Delete(where).Error
+}
@@ -0,0 +1,83 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/zc2638/ink/pkg/livelog"
+)
+
+type ConfigFile struct {
+	Dir string `json:"dir"`
+}
+
+// NewFile returns a log store that keeps each archive as a file in the directory.
+func NewFile(cfg ConfigFile, maxSize int64) (Interface, error) {
+	if len(cfg.Dir) == 0 {
+		return nil, errors.New("archive dir must be defined")
+	}
+	if err := os.MkdirAll(cfg.Dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &file{dir: cfg.Dir, maxSize: maxSize}, nil
+}
+
+type file struct {
+	dir     string
+	maxSize int64
+}
+
+func (s *file) path(id uint64) string {
+	return filepath.Join(s.dir, strconv.FormatUint(id, 10)+".json.gz")
+}
+
+func (s *file) Find(_ context.Context, id uint64) ([]*livelog.Line, error) {
+	data, err := os.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return make([]*livelog.Line, 0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Decode(data)
+}
+
+func (s *file) Save(_ context.Context, id uint64, lines []*livelog.Line) error {
+	data, err := Encode(Truncate(lines, s.maxSize))
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first to avoid reading a partial archive.
+	fp := s.path(id)
+	tmp := fp + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fp)
+}
+
+func (s *file) Delete(_ context.Context, id uint64) error {
+	err := os.Remove(s.path(id))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logstore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+
+	"gorm.io/gorm"
+
+	"github.com/zc2638/ink/pkg/livelog"
+)
+
+// DefaultMaxSize defines the default maximum size(byte) of the archived log content.
+const DefaultMaxSize = 8 << 20
+
+// Interface archives the finished step logs.
+type Interface interface {
+	// Find returns the archived log lines of the step.
+	// If the log is not found, an empty list is returned.
+	Find(ctx context.Context, id uint64) ([]*livelog.Line, error)
+	// Save archives the log lines of the step, replacing any existing archive.
+	Save(ctx context.Context, id uint64, lines []*livelog.Line) error
+	// Delete removes the archived log of the step.
+	Delete(ctx context.Context, id uint64) error
+}
+
+type Config struct {
+	// MaxSize limits the size(byte) of the archived log content,
+	// only the final lines are kept when it is exceeded.
+	MaxSize int64       `json:"maxSize,omitempty"`
+	File    *ConfigFile `json:"file,omitempty"`
+}
+
+// New returns the log store defined in config,
+// the database store is used by default.
+func New(cfg Config, db *gorm.DB) (Interface, error) {
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = DefaultMaxSize
+	}
+	if cfg.File != nil {
+		return NewFile(*cfg.File, cfg.MaxSize)
+	}
+	return NewDatabase(db, cfg.MaxSize), nil
+}
+
+// Truncate keeps the final lines whose total content size does not exceed maxSize.
+func Truncate(lines []*livelog.Line, maxSize int64) []*livelog.Line {
+	if maxSize <= 0 {
+		return lines
+	}
+
+	var size int64
+	for i := len(lines) - 1; i >= 0; i-- {
+		size += int64(len(lines[i].Content))
+		if size > maxSize {
+			return lines[i+1:]
+		}
+	}
+	return lines
+}
+
+// Encode converts the log lines to compressed data.
+func Encode(lines []*livelog.Line) ([]byte, error) {
+	if lines == nil {
+		lines = make([]*livelog.Line, 0)
+	}
+
+	buf := new(bytes.Buffer)
+	zw := gzip.NewWriter(buf)
+	if err := json.NewEncoder(zw).Encode(lines); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode converts the archived data to log lines.
+// The uncompressed data is also supported for compatibility.
+func Decode(data []byte) ([]*livelog.Line, error) {
+	lines := make([]*livelog.Line, 0)
+	if len(data) == 0 {
+		return lines, nil
+	}
+
+	var r io.Reader = bytes.NewReader(data)
+	if isGzip(data) {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	if err := json.NewDecoder(r).Decode(&lines); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+func isGzip(data []byte) bool {
+	return len(data) > 1 && data[0] == 0x1f && data[1] == 0x8b
+}
@@ -0,0 +1,162 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logstore_test
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zc2638/ink/pkg/database"
+	"github.com/zc2638/ink/pkg/livelog"
+	"github.com/zc2638/ink/pkg/logstore"
+	"github.com/zc2638/ink/resource"
+)
+
+func TestEncode(t *testing.T) {
+	lines := []*livelog.Line{
+		{Number: 0, Since: 1, Content: "hello\n"},
+		{Number: 1, Since: 2, Content: "world\n"},
+	}
+	data, err := logstore.Encode(lines)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		t.Fatalf("Encode() expected the gzip data, got %q", data)
+	}
+	got, err := logstore.Decode(data)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, lines) {
+		t.Fatalf("Decode() got %v, want %v", got, lines)
+	}
+
+	// the uncompressed data is still supported.
+	got, err = logstore.Decode([]byte(`[{"number":0,"since":1,"content":"hello\n"}]`))
+	if err != nil {
+		t.Fatalf("Decode() plain error = %v", err)
+	}
+	if !reflect.DeepEqual(got, lines[:1]) {
+		t.Fatalf("Decode() plain got %v, want %v", got, lines[:1])
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	lines := []*livelog.Line{
+		{Number: 0, Content: "aaaa"},
+		{Number: 1, Content: "bbbb"},
+		{Number: 2, Content: "cccc"},
+	}
+	tests := []struct {
+		name    string
+		maxSize int64
+		want    []*livelog.Line
+	}{
+		{name: "unlimited", maxSize: 0, want: lines},
+		{name: "not exceeded", maxSize: 12, want: lines},
+		{name: "keep final lines", maxSize: 10, want: lines[1:]},
+		{name: "keep final line", maxSize: 4, want: lines[2:]},
+		{name: "nothing fits", maxSize: 3, want: lines[3:]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := logstore.Truncate(lines, tt.maxSize); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Truncate() got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	tests := []struct {
+		name string
+		new  func(t *testing.T, maxSize int64) logstore.Interface
+	}{
+		{name: "database", new: newDatabase},
+		{name: "file", new: newFile},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testStore(t, tt.new(t, 10))
+		})
+	}
+}
+
+func testStore(t *testing.T, s logstore.Interface) {
+	ctx := context.Background()
+
+	got, err := s.Find(ctx, 1)
+	if err != nil {
+		t.Fatalf("Find() not found error = %v", err)
+	}
+	if got == nil || len(got) != 0 {
+		t.Fatalf("Find() not found got %v, want an empty list", got)
+	}
+
+	first := []*livelog.Line{{Number: 0, Since: 1, Content: "first"}}
+	if err := s.Save(ctx, 1, first); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if got, _ = s.Find(ctx, 1); !reflect.DeepEqual(got, first) {
+		t.Fatalf("Find() got %v, want %v", got, first)
+	}
+
+	// the existing archive is replaced and the content is capped by the max size.
+	second := []*livelog.Line{
+		{Number: 0, Since: 1, Content: strings.Repeat("a", 6)},
+		{Number: 1, Since: 2, Content: "second"},
+	}
+	if err := s.Save(ctx, 1, second); err != nil {
+		t.Fatalf("Save() replace error = %v", err)
+	}
+	if got, _ = s.Find(ctx, 1); !reflect.DeepEqual(got, second[1:]) {
+		t.Fatalf("Find() replaced got %v, want %v", got, second[1:])
+	}
+
+	if err := s.Delete(ctx, 1); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if got, _ = s.Find(ctx, 1); len(got) != 0 {
+		t.Fatalf("Find() deleted got %v, want an empty list", got)
+	}
+	// deleting a missing archive is not an error.
+	if err := s.Delete(ctx, 1); err != nil {
+		t.Fatalf("Delete() missing error = %v", err)
+	}
+}
+
+func newDatabase(t *testing.T, maxSize int64) logstore.Interface {
+	dsn := filepath.Join(t.TempDir(), "ink.db")
+	if err := resource.MigrateDatabase("sqlite3", dsn); err != nil {
+		t.Fatalf("migrate database failed: %v", err)
+	}
+	db, err := database.New(database.Config{Driver: "sqlite3", DSN: dsn})
+	if err != nil {
+		t.Fatalf("init database failed: %v", err)
+	}
+	return logstore.NewDatabase(db, maxSize)
+}
+
+func newFile(t *testing.T, maxSize int64) logstore.Interface {
+	s, err := logstore.NewFile(logstore.ConfigFile{Dir: t.TempDir()}, maxSize)
+	if err != nil {
+		t.Fatalf("init file store failed: %v", err)
+	}
+	return s
+}
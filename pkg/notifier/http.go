@@ -0,0 +1,83 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+type webhook struct {
+	cfg v1.WebhookNotifier
+}
+
+func (s *webhook) Send(ctx context.Context, data *Data, message string) error {
+	payload := struct {
+		*Data
+		Message string `json:"message"`
+	}{Data: data, Message: message}
+	return postJSON(ctx, s.cfg.URL, s.cfg.Headers, payload)
+}
+
+type slack struct {
+	cfg v1.SlackNotifier
+}
+
+func (s *slack) Send(ctx context.Context, _ *Data, message string) error {
+	payload := map[string]string{"text": message}
+	if s.cfg.Channel != "" {
+		payload["channel"] = s.cfg.Channel
+	}
+	if s.cfg.Username != "" {
+		payload["username"] = s.cfg.Username
+	}
+	return postJSON(ctx, s.cfg.URL, nil, payload)
+}
+
+func postJSON(ctx context.Context, url string, headers map[string]string, payload any) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"text/template"
+
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+)
+
+// DefaultTemplate is the template of the message if the notifier does not define it.
+const DefaultTemplate = `[{{ .Box.Namespace }}/{{ .Box.Name }}] Build #{{ .Build.Number }} {{ .Event }}
+{{- with .Build.Commit }}
+Commit: {{ .SHA }}{{ with .Branch }} ({{ . }}){{ end }}{{ with .Tag }} ({{ . }}){{ end }}{{ with .Author }} by {{ . }}{{ end }}
+{{- end }}
+{{- range .Stages }}
+- {{ .Name }}: {{ .Phase }}{{ with .Error }} ({{ . }}){{ end }}
+{{- end }}`
+
+// Data is the data of the event to render the message.
+type Data struct {
+	Event  string      `json:"event"`
+	Box    *v1.Box     `json:"box"`
+	Build  *v1.Build   `json:"build"`
+	Stages []*v1.Stage `json:"stages,omitempty"`
+}
+
+// Sender delivers the message to the backend.
+type Sender interface {
+	Send(ctx context.Context, data *Data, message string) error
+}
+
+// New returns the sender of the backend defined in the spec,
+// the secret provides the values not defined in the spec.
+func New(spec *v1.NotifierSpec, secret map[string]string) (Sender, error) {
+	switch {
+	case spec.Webhook != nil:
+		cfg := *spec.Webhook
+		if cfg.URL == "" {
+			cfg.URL = secret[v1.NotifierURLKey]
+		}
+		if cfg.URL == "" {
+			return nil, errors.New("webhook: url is required")
+		}
+		return &webhook{cfg: cfg}, nil
+	case spec.Slack != nil:
+		cfg := *spec.Slack
+		if cfg.URL == "" {
+			cfg.URL = secret[v1.NotifierURLKey]
+		}
+		if cfg.URL == "" {
+			return nil, errors.New("slack: url is required")
+		}
+		return &slack{cfg: cfg}, nil
+	case spec.SMTP != nil:
+		return &smtpSender{cfg: *spec.SMTP, password: secret[v1.NotifierPasswordKey]}, nil
+	}
+	return nil, errors.New("no backend is defined")
+}
+
+// Render renders the message by the template, the default template is used if it is empty.
+func Render(text string, data *Data) (string, error) {
+	if text == "" {
+		text = DefaultTemplate
+	}
+	tmpl, err := template.New("message").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	buf := new(bytes.Buffer)
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
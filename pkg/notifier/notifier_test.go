@@ -0,0 +1,210 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+)
+
+func TestRender(t *testing.T) {
+	box := &v1.Box{}
+	box.SetNamespace("default")
+	box.SetName("test")
+	data := &Data{
+		Event: v1.NotifyFailed,
+		Box:   box,
+		Build: &v1.Build{Number: 3, Commit: &v1.Commit{SHA: "abc", Branch: "main"}},
+		Stages: []*v1.Stage{
+			{Name: "build", Phase: v1.PhaseSucceeded},
+			{Name: "test", Phase: v1.PhaseFailed, Error: "exit code 1"},
+		},
+	}
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "default",
+			want: "[default/test] Build #3 failed\nCommit: abc (main)\n- build: Succeeded\n- test: Failed (exit code 1)",
+		},
+		{name: "custom", text: "{{ .Box.Name }} {{ .Event }}", want: "test failed"},
+		{name: "invalid template", text: "{{ .Box.Name ", wantErr: true},
+		{name: "unknown field", text: "{{ .Unknown }}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.text, data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    v1.NotifierSpec
+		secret  map[string]string
+		want    Sender
+		wantErr bool
+	}{
+		{
+			name: "webhook",
+			spec: v1.NotifierSpec{Webhook: &v1.WebhookNotifier{URL: "http://example.com"}},
+			want: &webhook{cfg: v1.WebhookNotifier{URL: "http://example.com"}},
+		},
+		{
+			name:   "webhook url from secret",
+			spec:   v1.NotifierSpec{Webhook: &v1.WebhookNotifier{}},
+			secret: map[string]string{v1.NotifierURLKey: "http://example.com"},
+			want:   &webhook{cfg: v1.WebhookNotifier{URL: "http://example.com"}},
+		},
+		{name: "webhook without url", spec: v1.NotifierSpec{Webhook: &v1.WebhookNotifier{}}, wantErr: true},
+		{
+			name:   "slack url from secret",
+			spec:   v1.NotifierSpec{Slack: &v1.SlackNotifier{Channel: "ci"}},
+			secret: map[string]string{v1.NotifierURLKey: "http://example.com"},
+			want:   &slack{cfg: v1.SlackNotifier{URL: "http://example.com", Channel: "ci"}},
+		},
+		{name: "slack without url", spec: v1.NotifierSpec{Slack: &v1.SlackNotifier{}}, wantErr: true},
+		{
+			name:   "smtp password from secret",
+			spec:   v1.NotifierSpec{SMTP: &v1.SMTPNotifier{Host: "localhost"}},
+			secret: map[string]string{v1.NotifierPasswordKey: "password"},
+			want:   &smtpSender{cfg: v1.SMTPNotifier{Host: "localhost"}, password: "password"},
+		},
+		{name: "no backend", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(&tt.spec, tt.secret)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("New() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+// serveSMTP accepts a connection and answers the commands of the client,
+// the message is sent to the channel when it is received.
+func serveSMTP(t *testing.T, ln net.Listener, messages chan<- string) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, _, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(cmd) {
+		case "EHLO", "HELO", "MAIL", "RCPT":
+			_ = tp.PrintfLine("250 OK")
+		case "DATA":
+			_ = tp.PrintfLine("354 Go ahead")
+			b, err := tp.ReadDotBytes()
+			if err != nil {
+				t.Errorf("read data failed: %v", err)
+				return
+			}
+			messages <- string(b)
+			_ = tp.PrintfLine("250 OK")
+		case "QUIT":
+			_ = tp.PrintfLine("221 Bye")
+			return
+		default:
+			_ = tp.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+func TestSMTP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	messages := make(chan string, 1)
+	go serveSMTP(t, ln, messages)
+
+	addr := ln.Addr().(*net.TCPAddr)
+	sender := &smtpSender{cfg: v1.SMTPNotifier{
+		Host: "127.0.0.1",
+		Port: addr.Port,
+		From: "ink@example.com",
+		To:   []string{"dev@example.com"},
+	}}
+	if err := sender.Send(context.Background(), nil, "subject\nline1\nline2"); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	msg := <-messages
+	if !strings.Contains(msg, "Subject: subject\n") || !strings.HasSuffix(msg, "\nline1\nline2\n") {
+		t.Fatalf("Send() message = %q", msg)
+	}
+}
+
+func TestSMTPTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// the server accepts the connection but never greets.
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = bufio.NewReader(conn).ReadByte()
+	}()
+
+	sender := &smtpSender{cfg: v1.SMTPNotifier{Host: "127.0.0.1", From: "ink@example.com", To: []string{"dev@example.com"}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- sender.sendMail(ctx, ln.Addr().String(), nil, []byte("message")) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("sendMail() expected the timeout error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("sendMail() is not bound to the deadline")
+	}
+}
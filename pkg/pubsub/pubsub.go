@@ -0,0 +1,73 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsub
+
+import (
+	"context"
+	"sync"
+
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+)
+
+// DefaultBufferSize is the number of the events buffered for each subscriber,
+// the events are dropped if the subscriber is too slow to receive them.
+const DefaultBufferSize = 100
+
+// Interface delivers the phase events in process.
+type Interface interface {
+	// Publish sends the event to all subscribers without blocking.
+	Publish(ctx context.Context, event *v1.PhaseEvent)
+	// Subscribe returns the channel of the events published after it,
+	// the channel is closed when the context is done.
+	Subscribe(ctx context.Context) <-chan *v1.PhaseEvent
+}
+
+// New returns the in-process hub of the events.
+func New() Interface {
+	return &hub{subscribers: make(map[chan *v1.PhaseEvent]struct{})}
+}
+
+type hub struct {
+	mux         sync.RWMutex
+	subscribers map[chan *v1.PhaseEvent]struct{}
+}
+
+func (h *hub) Publish(_ context.Context, event *v1.PhaseEvent) {
+	h.mux.RLock()
+	defer h.mux.RUnlock()
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+func (h *hub) Subscribe(ctx context.Context) <-chan *v1.PhaseEvent {
+	ch := make(chan *v1.PhaseEvent, DefaultBufferSize)
+	h.mux.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mux.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.mux.Lock()
+		delete(h.subscribers, ch)
+		close(ch)
+		h.mux.Unlock()
+	}()
+	return ch
+}
@@ -0,0 +1,56 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+)
+
+func TestHub(t *testing.T) {
+	h := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	ch1 := h.Subscribe(ctx)
+	ch2 := h.Subscribe(context.Background())
+
+	event := &v1.PhaseEvent{Kind: v1.PhaseEventBuild, Namespace: "default", Box: "test", Build: 1, Phase: v1.PhaseRunning}
+	h.Publish(context.Background(), event)
+	for _, ch := range []<-chan *v1.PhaseEvent{ch1, ch2} {
+		if got := <-ch; got != event {
+			t.Fatalf("Subscribe() got %v, want %v", got, event)
+		}
+	}
+
+	cancel()
+	select {
+	case _, ok := <-ch1:
+		if ok {
+			t.Fatal("Subscribe() expected the channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Subscribe() the channel is not closed after the context is done")
+	}
+
+	// the slow subscriber does not block the publisher.
+	for i := 0; i <= DefaultBufferSize; i++ {
+		h.Publish(context.Background(), event)
+	}
+	if len(ch2) != DefaultBufferSize {
+		t.Fatalf("Publish() buffered %d events, want %d", len(ch2), DefaultBufferSize)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+)
+
+// githubPush is the payload of the push event, which is shared by gitea.
+type githubPush struct {
+	Ref        string `json:"ref"`
+	After      string `json:"after"`
+	Compare    string `json:"compare"`
+	CompareURL string `json:"compare_url"`
+	HeadCommit *struct {
+		ID      string `json:"id"`
+		Message string `json:"message"`
+		URL     string `json:"url"`
+		Author  struct {
+			Name     string `json:"name"`
+			Username string `json:"username"`
+		} `json:"author"`
+	} `json:"head_commit"`
+	Pusher struct {
+		Name     string `json:"name"`
+		Login    string `json:"login"`
+		Username string `json:"username"`
+	} `json:"pusher"`
+}
+
+// githubPullRequest is the payload of the pull request event, which is shared by gitea.
+type githubPullRequest struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Title   string `json:"title"`
+		HTMLURL string `json:"html_url"`
+		Head    struct {
+			Ref string `json:"ref"`
+			SHA string `json:"sha"`
+		} `json:"head"`
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+		User struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	} `json:"pull_request"`
+}
+
+func verifyGitHub(header http.Header, body []byte, secret string) error {
+	signature, ok := strings.CutPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+	if !ok {
+		return ErrInvalidSignature
+	}
+	return verifyHMAC(signature, body, secret)
+}
+
+func parseGitHub(header http.Header, body []byte) (*v1.Commit, error) {
+	return parseGitHubEvent(header.Get("X-GitHub-Event"), body, "opened", "reopened", "synchronize")
+}
+
+func verifyGitea(header http.Header, body []byte, secret string) error {
+	return verifyHMAC(header.Get("X-Gitea-Signature"), body, secret)
+}
+
+func parseGitea(header http.Header, body []byte) (*v1.Commit, error) {
+	return parseGitHubEvent(header.Get("X-Gitea-Event"), body, "opened", "reopened", "synchronized")
+}
+
+func parseGitHubEvent(event string, body []byte, actions ...string) (*v1.Commit, error) {
+	switch event {
+	case "push":
+		var payload githubPush
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("parse push payload failed: %v", err)
+		}
+		if isZeroSHA(payload.After) || payload.HeadCommit == nil {
+			return nil, ErrIgnored
+		}
+
+		commit := &v1.Commit{
+			SHA:     payload.HeadCommit.ID,
+			Message: payload.HeadCommit.Message,
+			Author:  payload.HeadCommit.Author.Username,
+			Link:    payload.Compare,
+		}
+		parseRef(commit, payload.Ref)
+		if commit.Author == "" {
+			commit.Author = payload.HeadCommit.Author.Name
+		}
+		if commit.Link == "" {
+			commit.Link = payload.CompareURL
+		}
+		if commit.Link == "" || commit.Event == v1.EventTag {
+			commit.Link = payload.HeadCommit.URL
+		}
+		return commit, nil
+	case "pull_request":
+		var payload githubPullRequest
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("parse pull request payload failed: %v", err)
+		}
+		if !slices.Contains(actions, payload.Action) {
+			return nil, ErrIgnored
+		}
+
+		pr := payload.PullRequest
+		return &v1.Commit{
+			SHA:          pr.Head.SHA,
+			Ref:          "refs/pull/" + strconv.Itoa(payload.Number) + "/head",
+			Branch:       pr.Head.Ref,
+			Message:      pr.Title,
+			Author:       pr.User.Login,
+			Link:         pr.HTMLURL,
+			Event:        v1.EventPullRequest,
+			PullRequest:  payload.Number,
+			TargetBranch: pr.Base.Ref,
+		}, nil
+	}
+	return nil, ErrIgnored
+}
@@ -0,0 +1,129 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+)
+
+type gitlabCommit struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+	URL     string `json:"url"`
+	Author  struct {
+		Name string `json:"name"`
+	} `json:"author"`
+}
+
+type gitlabPush struct {
+	Ref          string         `json:"ref"`
+	After        string         `json:"after"`
+	CheckoutSHA  string         `json:"checkout_sha"`
+	UserName     string         `json:"user_name"`
+	UserUsername string         `json:"user_username"`
+	Commits      []gitlabCommit `json:"commits"`
+	Project      struct {
+		WebURL string `json:"web_url"`
+	} `json:"project"`
+}
+
+type gitlabMergeRequest struct {
+	User struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	ObjectAttributes struct {
+		IID          int          `json:"iid"`
+		Title        string       `json:"title"`
+		URL          string       `json:"url"`
+		Action       string       `json:"action"`
+		OldRev       string       `json:"oldrev"`
+		SourceBranch string       `json:"source_branch"`
+		TargetBranch string       `json:"target_branch"`
+		LastCommit   gitlabCommit `json:"last_commit"`
+	} `json:"object_attributes"`
+}
+
+// verifyGitLab compares the token, gitlab does not sign the payload.
+func verifyGitLab(header http.Header, _ []byte, secret string) error {
+	token := header.Get("X-Gitlab-Token")
+	if token == "" || secret == "" || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func parseGitLab(header http.Header, body []byte) (*v1.Commit, error) {
+	switch header.Get("X-Gitlab-Event") {
+	case "Push Hook", "Tag Push Hook":
+		var payload gitlabPush
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("parse push payload failed: %v", err)
+		}
+		if isZeroSHA(payload.After) || payload.CheckoutSHA == "" {
+			return nil, ErrIgnored
+		}
+
+		commit := &v1.Commit{
+			SHA:    payload.CheckoutSHA,
+			Author: payload.UserUsername,
+			Link:   payload.Project.WebURL + "/-/commit/" + payload.CheckoutSHA,
+		}
+		parseRef(commit, payload.Ref)
+		for _, v := range payload.Commits {
+			if v.ID == payload.CheckoutSHA {
+				commit.Message = v.Message
+				commit.Link = v.URL
+				break
+			}
+		}
+		if commit.Author == "" {
+			commit.Author = payload.UserName
+		}
+		return commit, nil
+	case "Merge Request Hook":
+		var payload gitlabMergeRequest
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("parse merge request payload failed: %v", err)
+		}
+		attrs := payload.ObjectAttributes
+		if !slices.Contains([]string{"open", "reopen", "update"}, attrs.Action) {
+			return nil, ErrIgnored
+		}
+		// the update action is also sent for the changes of the title, labels, etc.
+		if attrs.Action == "update" && attrs.OldRev == "" {
+			return nil, ErrIgnored
+		}
+
+		return &v1.Commit{
+			SHA:          attrs.LastCommit.ID,
+			Ref:          "refs/merge-requests/" + strconv.Itoa(attrs.IID) + "/head",
+			Branch:       attrs.SourceBranch,
+			Message:      attrs.Title,
+			Author:       payload.User.Username,
+			Link:         attrs.URL,
+			Event:        v1.EventPullRequest,
+			PullRequest:  attrs.IID,
+			TargetBranch: attrs.TargetBranch,
+		}, nil
+	}
+	return nil, ErrIgnored
+}
@@ -0,0 +1,107 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+)
+
+// The supported providers.
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+	ProviderGitea  = "gitea"
+)
+
+// maxBodySize limits the size of the payload.
+const maxBodySize = 10 << 20
+
+var (
+	ErrUnsupportedProvider = errors.New("unsupported provider")
+	ErrInvalidSignature    = errors.New("invalid signature")
+	// ErrIgnored means the event does not trigger the build, e.g. ping or branch deletion.
+	ErrIgnored = errors.New("event ignored")
+)
+
+type provider struct {
+	verify func(header http.Header, body []byte, secret string) error
+	parse  func(header http.Header, body []byte) (*v1.Commit, error)
+}
+
+var providers = map[string]provider{
+	ProviderGitHub: {verify: verifyGitHub, parse: parseGitHub},
+	ProviderGitLab: {verify: verifyGitLab, parse: parseGitLab},
+	ProviderGitea:  {verify: verifyGitea, parse: parseGitea},
+}
+
+// Parse verifies the webhook request of the provider with the secret,
+// and parses the payload into the commit of the build.
+func Parse(providerName string, r *http.Request, secret string) (*v1.Commit, error) {
+	p, ok := providers[providerName]
+	if !ok {
+		return nil, ErrUnsupportedProvider
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("read payload failed: %v", err)
+	}
+	if err := p.verify(r.Header, body, secret); err != nil {
+		return nil, err
+	}
+	return p.parse(r.Header, body)
+}
+
+// verifyHMAC checks the hex encoded HMAC-SHA256 signature of the body.
+func verifyHMAC(signature string, body []byte, secret string) error {
+	if signature == "" || secret == "" {
+		return ErrInvalidSignature
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// parseRef fills the branch or tag of the commit by the full ref.
+func parseRef(commit *v1.Commit, ref string) {
+	commit.Ref = ref
+	if tag, ok := strings.CutPrefix(ref, "refs/tags/"); ok {
+		commit.Event = v1.EventTag
+		commit.Tag = tag
+		return
+	}
+	commit.Event = v1.EventPush
+	commit.Branch = strings.TrimPrefix(ref, "refs/heads/")
+}
+
+// isZeroSHA reports whether the sha means the ref is deleted.
+func isZeroSHA(sha string) bool {
+	return strings.Trim(sha, "0") == ""
+}
@@ -0,0 +1,230 @@
+// Copyright © 2024 zc2638 <zc2638@qq.com>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	v1 "github.com/zc2638/ink/pkg/api/core/v1"
+)
+
+const testSecret = "s3cr3t"
+
+// newRequest replays the recorded payload with the headers sent by the provider.
+func newRequest(t *testing.T, provider, event, file, secret string) *http.Request {
+	body, err := os.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	switch provider {
+	case ProviderGitHub:
+		r.Header.Set("X-GitHub-Event", event)
+		r.Header.Set("X-Hub-Signature-256", "sha256="+signature)
+	case ProviderGitLab:
+		r.Header.Set("X-Gitlab-Event", event)
+		r.Header.Set("X-Gitlab-Token", secret)
+	case ProviderGitea:
+		r.Header.Set("X-Gitea-Event", event)
+		r.Header.Set("X-Gitea-Signature", signature)
+	}
+	return r
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		provider string
+		event    string
+		file     string
+		want     *v1.Commit
+	}{
+		{
+			provider: ProviderGitHub,
+			event:    "push",
+			file:     "github_push.json",
+			want: &v1.Commit{
+				SHA:     "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c",
+				Ref:     "refs/heads/main",
+				Branch:  "main",
+				Message: "Update README.md\n\nAdd the usage of inkctl.",
+				Author:  "zc2638",
+				Link:    "https://github.com/zc2638/ink/compare/6113728f27ae...0d1a26e67d8f",
+				Event:   v1.EventPush,
+			},
+		},
+		{
+			provider: ProviderGitHub,
+			event:    "push",
+			file:     "github_tag.json",
+			want: &v1.Commit{
+				SHA:     "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c",
+				Ref:     "refs/tags/v0.1.0",
+				Tag:     "v0.1.0",
+				Message: "Update README.md\n\nAdd the usage of inkctl.",
+				Author:  "zc2638",
+				Link:    "https://github.com/zc2638/ink/commit/0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c",
+				Event:   v1.EventTag,
+			},
+		},
+		{
+			provider: ProviderGitHub,
+			event:    "pull_request",
+			file:     "github_pull_request.json",
+			want: &v1.Commit{
+				SHA:          "9c8e6a1b2d3f4e5a6b7c8d9e0f1a2b3c4d5e6f7a",
+				Ref:          "refs/pull/12/head",
+				Branch:       "windows",
+				Message:      "Support the host worker on windows",
+				Author:       "octocat",
+				Link:         "https://github.com/zc2638/ink/pull/12",
+				Event:        v1.EventPullRequest,
+				PullRequest:  12,
+				TargetBranch: "main",
+			},
+		},
+		{
+			provider: ProviderGitLab,
+			event:    "Push Hook",
+			file:     "gitlab_push.json",
+			want: &v1.Commit{
+				SHA:     "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+				Ref:     "refs/heads/master",
+				Branch:  "master",
+				Message: "fixed readme",
+				Author:  "jsmith",
+				Link:    "http://example.com/mike/diaspora/commit/da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+				Event:   v1.EventPush,
+			},
+		},
+		{
+			provider: ProviderGitLab,
+			event:    "Tag Push Hook",
+			file:     "gitlab_tag.json",
+			want: &v1.Commit{
+				SHA:    "82b3d5ae55f7080f1e6022629cdb57bfae7cccc7",
+				Ref:    "refs/tags/v1.0.0",
+				Tag:    "v1.0.0",
+				Author: "jsmith",
+				Link:   "http://example.com/jsmith/example/-/commit/82b3d5ae55f7080f1e6022629cdb57bfae7cccc7",
+				Event:  v1.EventTag,
+			},
+		},
+		{
+			provider: ProviderGitLab,
+			event:    "Merge Request Hook",
+			file:     "gitlab_merge_request.json",
+			want: &v1.Commit{
+				SHA:          "da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+				Ref:          "refs/merge-requests/1/head",
+				Branch:       "ms-viewport",
+				Message:      "MS-Viewport",
+				Author:       "root",
+				Link:         "http://example.com/diaspora/merge_requests/1",
+				Event:        v1.EventPullRequest,
+				PullRequest:  1,
+				TargetBranch: "master",
+			},
+		},
+		{
+			provider: ProviderGitea,
+			event:    "push",
+			file:     "gitea_push.json",
+			want: &v1.Commit{
+				SHA:     "bffeb74224043ba2feb48d137756c8a9331c449a",
+				Ref:     "refs/heads/develop",
+				Branch:  "develop",
+				Message: "Webhooks Yay!",
+				Author:  "gitea",
+				Link:    "http://localhost:3000/gitea/webhooks/compare/28e1879d029cb852e4844d9c718537df08844e03...bffeb74224043ba2feb48d137756c8a9331c449a",
+				Event:   v1.EventPush,
+			},
+		},
+		{
+			provider: ProviderGitea,
+			event:    "pull_request",
+			file:     "gitea_pull_request.json",
+			want: &v1.Commit{
+				SHA:          "4f3b6c2a1d0e9f8a7b6c5d4e3f2a1b0c9d8e7f6a",
+				Ref:          "refs/pull/3/head",
+				Branch:       "docs",
+				Message:      "Add the webhook docs",
+				Author:       "alice",
+				Link:         "http://localhost:3000/gitea/webhooks/pulls/3",
+				Event:        v1.EventPullRequest,
+				PullRequest:  3,
+				TargetBranch: "master",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			r := newRequest(t, tt.provider, tt.event, tt.file, testSecret)
+			got, err := Parse(tt.provider, r, testSecret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Want commit %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseIgnored(t *testing.T) {
+	tests := []struct {
+		provider string
+		event    string
+		file     string
+	}{
+		{provider: ProviderGitHub, event: "push", file: "github_delete.json"},
+		{provider: ProviderGitHub, event: "pull_request", file: "github_pull_request_closed.json"},
+		{provider: ProviderGitHub, event: "issues", file: "github_push.json"},
+	}
+	for _, tt := range tests {
+		r := newRequest(t, tt.provider, tt.event, tt.file, testSecret)
+		if _, err := Parse(tt.provider, r, testSecret); !errors.Is(err, ErrIgnored) {
+			t.Errorf("Want the event(%s) of %s ignored, got %v", tt.event, tt.file, err)
+		}
+	}
+}
+
+func TestParseInvalidSignature(t *testing.T) {
+	for _, provider := range []string{ProviderGitHub, ProviderGitLab, ProviderGitea} {
+		r := newRequest(t, provider, "push", "github_push.json", "wrong")
+		if _, err := Parse(provider, r, testSecret); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("Want invalid signature of %s, got %v", provider, err)
+		}
+	}
+
+	r := newRequest(t, ProviderGitHub, "push", "github_push.json", testSecret)
+	if _, err := Parse("bitbucket", r, testSecret); !errors.Is(err, ErrUnsupportedProvider) {
+		t.Errorf("Want unsupported provider, got %v", err)
+	}
+}
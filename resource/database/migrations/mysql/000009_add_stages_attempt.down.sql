@@ -0,0 +1,2 @@
+ALTER TABLE `stages`
+    DROP COLUMN `attempt`;
\ No newline at end of file
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `tokens`
+(
+    `id`         INTEGER AUTO_INCREMENT,
+    `name`       VARCHAR(255) NOT NULL,
+    `hash`       VARCHAR(255) NOT NULL,
+    `roles`      TEXT,
+
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_tokens_name` (`name`),
+    UNIQUE KEY `uk_tokens_hash` (`hash`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
\ No newline at end of file
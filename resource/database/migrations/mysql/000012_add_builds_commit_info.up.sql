@@ -0,0 +1,2 @@
+ALTER TABLE `builds`
+    ADD COLUMN `commit_info` TEXT;
\ No newline at end of file
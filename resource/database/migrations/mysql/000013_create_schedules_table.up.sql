@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `schedules`
+(
+    `id`         INTEGER AUTO_INCREMENT,
+    `box_id`     INTEGER      NOT NULL,
+    `name`       VARCHAR(255) NOT NULL,
+    `last_fired` BIGINT       NOT NULL DEFAULT 0,
+
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_schedules_box_id_name` (`box_id`, `name`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
\ No newline at end of file
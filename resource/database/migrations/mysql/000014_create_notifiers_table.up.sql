@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `notifiers`
+(
+    `id`         INTEGER AUTO_INCREMENT,
+    `namespace`  VARCHAR(255) NOT NULL,
+    `name`       VARCHAR(255) NOT NULL,
+    `all_boxes`  TINYINT,
+    `data`       TEXT,
+
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
\ No newline at end of file
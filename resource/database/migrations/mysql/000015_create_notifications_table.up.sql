@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS `notifications`
+(
+    `id`         INTEGER AUTO_INCREMENT,
+    `build_id`   INTEGER      NOT NULL,
+    `notifier`   VARCHAR(255) NOT NULL,
+    `event`      VARCHAR(50)  NOT NULL,
+    `phase`      VARCHAR(50)  NOT NULL,
+    `attempts`   INTEGER      NOT NULL DEFAULT 0,
+    `error`      VARCHAR(1000),
+
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (`id`),
+    KEY `idx_notifications_build_id` (`build_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE `stages`
+    DROP COLUMN `depends_on`;
\ No newline at end of file
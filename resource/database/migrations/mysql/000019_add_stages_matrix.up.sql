@@ -0,0 +1,3 @@
+ALTER TABLE `stages`
+    ADD COLUMN `workflow` VARCHAR(255),
+    ADD COLUMN `matrix`   TEXT;
\ No newline at end of file
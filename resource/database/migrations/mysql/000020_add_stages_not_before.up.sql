@@ -0,0 +1,2 @@
+ALTER TABLE `stages`
+    ADD COLUMN `not_before` INTEGER NOT NULL DEFAULT 0;
\ No newline at end of file
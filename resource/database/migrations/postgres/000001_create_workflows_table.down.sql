@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "workflows";
\ No newline at end of file
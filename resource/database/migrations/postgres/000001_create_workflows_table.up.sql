@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS "workflows"
+(
+    "id"         BIGSERIAL PRIMARY KEY,
+    "namespace"  VARCHAR(255) NOT NULL,
+    "name"       VARCHAR(255) NOT NULL,
+    "data"       TEXT,
+
+    "created_at" TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "boxes";
\ No newline at end of file
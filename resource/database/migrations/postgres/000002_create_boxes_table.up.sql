@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS "boxes"
+(
+    "id"         BIGSERIAL PRIMARY KEY,
+    "namespace"  VARCHAR(255) NOT NULL,
+    "name"       VARCHAR(255) NOT NULL,
+    "data"       TEXT,
+    "enabled"    BOOLEAN,
+
+    "created_at" TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
\ No newline at end of file
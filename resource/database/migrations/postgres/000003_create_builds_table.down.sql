@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "builds";
\ No newline at end of file
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS "builds"
+(
+    "id"         BIGSERIAL PRIMARY KEY,
+    "box_id"     BIGINT       NOT NULL,
+    "number"     BIGINT       NOT NULL,
+    "phase"      VARCHAR(50)  NOT NULL,
+    "title"      VARCHAR(255) NOT NULL,
+    "settings"   TEXT,
+    "started"    BIGINT,
+    "stopped"    BIGINT,
+
+    "created_at" TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "stages";
\ No newline at end of file
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS "stages"
+(
+    "id"          BIGSERIAL PRIMARY KEY,
+    "box_id"      BIGINT       NOT NULL,
+    "build_id"    BIGINT       NOT NULL,
+    "number"      BIGINT       NOT NULL,
+    "phase"       VARCHAR(50)  NOT NULL,
+    "name"        VARCHAR(255) NOT NULL,
+    "worker_name" VARCHAR(255),
+    "worker"      TEXT,
+    "started"     BIGINT,
+    "stopped"     BIGINT,
+    "error"       VARCHAR(1000),
+
+    "created_at"  TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    "updated_at"  TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "steps";
\ No newline at end of file
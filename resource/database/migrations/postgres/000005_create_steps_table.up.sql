@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS "steps"
+(
+    "id"         BIGSERIAL PRIMARY KEY,
+    "stage_id"   BIGINT       NOT NULL,
+    "number"     BIGINT       NOT NULL,
+    "phase"      VARCHAR(50)  NOT NULL,
+    "name"       VARCHAR(255) NOT NULL,
+    "started"    BIGINT,
+    "stopped"    BIGINT,
+    "exit_code"  INTEGER,
+    "error"      VARCHAR(1000),
+
+    "created_at" TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
\ No newline at end of file
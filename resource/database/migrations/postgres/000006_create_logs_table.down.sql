@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "logs";
\ No newline at end of file
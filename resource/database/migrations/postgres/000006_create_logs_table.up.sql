@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS "logs"
+(
+    "id"         BIGINT PRIMARY KEY,
+    "data"       BYTEA,
+
+    "created_at" TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "secrets";
\ No newline at end of file
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS "labels"
+(
+    "id"         BIGSERIAL PRIMARY KEY,
+    "namespace"  VARCHAR(255) NOT NULL,
+    "name"       VARCHAR(255) NOT NULL,
+    "kind"       VARCHAR(255) NOT NULL,
+    "key"        VARCHAR(255) NOT NULL,
+    "value"      VARCHAR(255),
+
+    "created_at" TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE "secrets"
+    DROP COLUMN "key_id";
\ No newline at end of file
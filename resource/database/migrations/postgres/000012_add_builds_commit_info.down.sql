@@ -0,0 +1,2 @@
+ALTER TABLE "builds"
+    DROP COLUMN "commit_info";
\ No newline at end of file
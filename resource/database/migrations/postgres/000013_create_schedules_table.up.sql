@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS "schedules"
+(
+    "id"         BIGSERIAL PRIMARY KEY,
+    "box_id"     BIGINT       NOT NULL,
+    "name"       VARCHAR(255) NOT NULL,
+    "last_fired" BIGINT       NOT NULL DEFAULT 0,
+
+    "created_at" TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE ("box_id", "name")
+);
\ No newline at end of file
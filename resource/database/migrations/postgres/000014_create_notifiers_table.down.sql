@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "notifiers";
\ No newline at end of file
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS "notifications"
+(
+    "id"         BIGSERIAL PRIMARY KEY,
+    "build_id"   BIGINT       NOT NULL,
+    "notifier"   VARCHAR(255) NOT NULL,
+    "event"      VARCHAR(50)  NOT NULL,
+    "phase"      VARCHAR(50)  NOT NULL,
+    "attempts"   INTEGER      NOT NULL DEFAULT 0,
+    "error"      VARCHAR(1000),
+
+    "created_at" TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS "idx_notifications_build_id" ON "notifications" ("build_id");
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE "stages"
+    ADD COLUMN "depends_on" TEXT;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE "stages"
+    DROP COLUMN "approval",
+    DROP COLUMN "approver",
+    DROP COLUMN "comment";
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE "stages"
+    ADD COLUMN "approval" BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN "approver" VARCHAR(255),
+    ADD COLUMN "comment"  VARCHAR(1000);
\ No newline at end of file
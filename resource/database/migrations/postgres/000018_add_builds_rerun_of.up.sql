@@ -0,0 +1,2 @@
+ALTER TABLE "builds"
+    ADD COLUMN "rerun_of" BIGINT;
\ No newline at end of file
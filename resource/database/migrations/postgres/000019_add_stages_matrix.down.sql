@@ -0,0 +1,3 @@
+ALTER TABLE "stages"
+    DROP COLUMN "workflow",
+    DROP COLUMN "matrix";
\ No newline at end of file
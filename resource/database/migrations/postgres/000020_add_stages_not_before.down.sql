@@ -0,0 +1,2 @@
+ALTER TABLE "stages"
+    DROP COLUMN "not_before";
\ No newline at end of file
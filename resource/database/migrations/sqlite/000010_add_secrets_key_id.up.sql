@@ -0,0 +1,2 @@
+ALTER TABLE `secrets`
+    ADD COLUMN `key_id` VARCHAR(255) NOT NULL DEFAULT '';
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS `tokens`
+(
+    `id`         INTEGER PRIMARY KEY AUTOINCREMENT,
+    `name`       VARCHAR(255) NOT NULL UNIQUE,
+    `hash`       VARCHAR(255) NOT NULL UNIQUE,
+    `roles`      TEXT,
+
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` DATETIME DEFAULT CURRENT_TIMESTAMP
+);
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS `schedules`
+(
+    `id`         INTEGER PRIMARY KEY AUTOINCREMENT,
+    `box_id`     INTEGER      NOT NULL,
+    `name`       VARCHAR(255) NOT NULL,
+    `last_fired` BIGINT       NOT NULL DEFAULT 0,
+
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE (`box_id`, `name`)
+);
\ No newline at end of file
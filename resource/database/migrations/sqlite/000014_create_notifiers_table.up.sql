@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS `notifiers`
+(
+    `id`         INTEGER PRIMARY KEY AUTOINCREMENT,
+    `namespace`  VARCHAR(255) NOT NULL,
+    `name`       VARCHAR(255) NOT NULL,
+    `all_boxes`  TINYINT,
+    `data`       TEXT,
+
+    `created_at` DATETIME DEFAULT CURRENT_TIMESTAMP,
+    `updated_at` DATETIME DEFAULT CURRENT_TIMESTAMP
+);
\ No newline at end of file
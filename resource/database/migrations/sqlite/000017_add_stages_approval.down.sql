@@ -0,0 +1,6 @@
+ALTER TABLE `stages`
+    DROP COLUMN `approval`;
+ALTER TABLE `stages`
+    DROP COLUMN `approver`;
+ALTER TABLE `stages`
+    DROP COLUMN `comment`;
\ No newline at end of file
@@ -0,0 +1,6 @@
+ALTER TABLE `stages`
+    ADD COLUMN `approval` TINYINT NOT NULL DEFAULT 0;
+ALTER TABLE `stages`
+    ADD COLUMN `approver` VARCHAR(255);
+ALTER TABLE `stages`
+    ADD COLUMN `comment` VARCHAR(1000);
\ No newline at end of file
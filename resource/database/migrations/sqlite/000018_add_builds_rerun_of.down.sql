@@ -0,0 +1,2 @@
+ALTER TABLE `builds`
+    DROP COLUMN `rerun_of`;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE `builds`
+    ADD COLUMN `rerun_of` INTEGER;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE `stages`
+    DROP COLUMN `workflow`;
+ALTER TABLE `stages`
+    DROP COLUMN `matrix`;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE `stages`
+    ADD COLUMN `workflow` VARCHAR(255);
+ALTER TABLE `stages`
+    ADD COLUMN `matrix` TEXT;
\ No newline at end of file
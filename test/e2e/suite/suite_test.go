@@ -15,6 +15,7 @@
 package suite_test
 
 import (
+	"os"
 	"testing"
 
 	"github.com/onsi/ginkgo/v2"
@@ -28,11 +29,11 @@ import (
 
 func Test(t *testing.T) {
 	addr := "http://localhost:2678"
-	serverC, err := clients.NewServer(addr)
+	serverC, err := clients.NewServer(addr, os.Getenv("INK_TOKEN"))
 	if err != nil {
 		t.Fatal(err)
 	}
-	clientC, err := clients.NewWorker(addr, framework.Name, &v1.Worker{Kind: v1.WorkerKindHost})
+	clientC, err := clients.NewWorker(addr, framework.Name, os.Getenv("INK_WORKER_TOKEN"), &v1.Worker{Kind: v1.WorkerKindHost})
 	if err != nil {
 		t.Fatal(err)
 	}